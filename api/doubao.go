@@ -2,36 +2,172 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"sync/atomic"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/LouisLau-art/go-translator/logging"
+	"github.com/LouisLau-art/go-translator/tracing"
 )
 
 // DoubaoClient handles communication with Doubao API
 type DoubaoClient struct {
-	apiKey     string
-	apiURL     string
-	httpClient *http.Client
+	settings     atomic.Pointer[Settings]
+	httpClient   *http.Client
+	retryBackoff time.Duration
+}
+
+// DefaultModel is the translation model used when none is configured
+const DefaultModel = "doubao-seed-translation-250915"
+
+// reservedOptions are request fields that extra options may not override,
+// across every provider's request format
+var reservedOptions = map[string]bool{"model": true, "input": true, "messages": true, "stream": true}
+
+// Settings are the upstream parameters that can be swapped at runtime
+type Settings struct {
+	APIKey     string
+	APIURL     string
+	MaxRetries int
+	Model      string
+	Extra      map[string]interface{}
+}
+
+// Options are per-call overrides for a translation request
+type Options struct {
+	// Model replaces the configured default model when set
+	Model string
+	// Extra holds Responses API fields merged into the request body,
+	// on top of the configured defaults
+	Extra map[string]interface{}
+	// Context describes where the text is used, such as a gettext
+	// msgctxt. Chat providers pass it along in the prompt; the Doubao
+	// translation models take no instructions and ignore it.
+	Context string
+}
+
+// Result is a successful translation
+type Result struct {
+	Text         string
+	Model        string
+	InputTokens  int
+	OutputTokens int
+	// Provider names the provider that produced the translation
+	Provider string
+	// Fallback is set when a provider other than the primary one served it
+	Fallback bool
+}
+
+// ValidateExtraOptions rejects extra options that would override the
+// fields the client itself controls
+func ValidateExtraOptions(extra map[string]interface{}) error {
+	for key := range extra {
+		if reservedOptions[key] {
+			return fmt.Errorf("option %q cannot be overridden", key)
+		}
+	}
+	return nil
+}
+
+// Option customizes a DoubaoClient
+type Option func(*DoubaoClient)
+
+// WithMaxRetries sets how many times a retryable failure is retried
+func WithMaxRetries(n int) Option {
+	return func(c *DoubaoClient) {
+		if n >= 0 {
+			settings := c.Settings()
+			settings.MaxRetries = n
+			c.settings.Store(&settings)
+		}
+	}
+}
+
+// WithRetryBackoff sets the initial delay between retries, doubled per attempt
+func WithRetryBackoff(d time.Duration) Option {
+	return func(c *DoubaoClient) {
+		c.retryBackoff = d
+	}
+}
+
+// WithModel sets the default model and extra request options
+func WithModel(model string, extra map[string]interface{}) Option {
+	return func(c *DoubaoClient) {
+		settings := c.Settings()
+		if model != "" {
+			settings.Model = model
+		}
+		settings.Extra = extra
+		c.settings.Store(&settings)
+	}
+}
+
+// WithHTTPClient replaces the underlying HTTP client
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *DoubaoClient) {
+		c.httpClient = hc
+	}
 }
 
 // NewDoubaoClient creates a new Doubao API client
-func NewDoubaoClient(apiKey, apiURL string) *DoubaoClient {
-	return &DoubaoClient{
-		apiKey: apiKey,
-		apiURL: apiURL,
+func NewDoubaoClient(apiKey, apiURL string, opts ...Option) *DoubaoClient {
+	c := &DoubaoClient{
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		retryBackoff: 500 * time.Millisecond,
+	}
+	c.settings.Store(&Settings{APIKey: apiKey, APIURL: apiURL, Model: DefaultModel})
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
+}
+
+// Name identifies the provider
+func (c *DoubaoClient) Name() string {
+	return "doubao"
+}
+
+// Settings returns a copy of the current upstream settings
+func (c *DoubaoClient) Settings() Settings {
+	return *c.settings.Load()
+}
+
+// UpdateSettings atomically replaces the upstream settings. Calls already
+// in flight finish with the settings they started with.
+func (c *DoubaoClient) UpdateSettings(settings Settings) {
+	c.settings.Store(&settings)
 }
 
 // Request structures
 type TranslateRequest struct {
-	Text   string `json:"text" binding:"required"`
-	Source string `json:"source"`
-	Target string `json:"target" binding:"required"`
+	Text    string                 `json:"text" binding:"required"`
+	Source  string                 `json:"source"`
+	Target  string                 `json:"target" binding:"required"`
+	Model   string                 `json:"model"`
+	Options map[string]interface{} `json:"options"`
+	// Format is "text" (the default) or "html" to translate markup
+	Format string `json:"format"`
+	// Context tells apart identical texts used in different places
+	Context string `json:"context"`
+}
+
+// BatchTranslateRequest translates several texts with the same settings
+type BatchTranslateRequest struct {
+	Texts   []string               `json:"texts" binding:"required,min=1"`
+	Source  string                 `json:"source"`
+	Target  string                 `json:"target" binding:"required"`
+	Model   string                 `json:"model"`
+	Options map[string]interface{} `json:"options"`
+	Format  string                 `json:"format"`
 }
 
 type DoubaoRequest struct {
@@ -73,10 +209,46 @@ type DoubaoNewResponse struct {
 		} `json:"content"`
 	} `json:"output"`
 	Status string `json:"status"`
+	Usage  *struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage,omitempty"`
+}
+
+// Translate sends a translation request to Doubao API, retrying
+// transient failures up to the configured limit
+func (c *DoubaoClient) Translate(ctx context.Context, text, source, target string, opts Options) (*Result, error) {
+	ctx, span := tracing.Start(ctx, "DoubaoClient.Translate",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.Int("translation.chunk_size", len(text)),
+			attribute.String("translation.source_language", source),
+			attribute.String("translation.target_language", target),
+		))
+	defer span.End()
+
+	settings := c.settings.Load()
+	model := settings.Model
+	if opts.Model != "" {
+		model = opts.Model
+	}
+	span.SetAttributes(attribute.String("translation.model", model))
+
+	result, retries, err := withRetries(ctx, span, settings.MaxRetries, c.retryBackoff, func() (*Result, error) {
+		return observe(ctx, text, func() (*Result, error) {
+			return c.translate(ctx, settings, model, text, source, target, opts.Extra)
+		})
+	})
+
+	span.SetAttributes(attribute.Int("translation.retry_count", retries))
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	return result, nil
 }
 
-// Translate sends a translation request to Doubao API
-func (c *DoubaoClient) Translate(text, source, target string) (string, error) {
+func (c *DoubaoClient) translate(ctx context.Context, settings *Settings, model, text, source, target string, extra map[string]interface{}) (*Result, error) {
 	opts := &TranslationOpts{
 		TargetLanguage: target,
 	}
@@ -85,7 +257,7 @@ func (c *DoubaoClient) Translate(text, source, target string) (string, error) {
 	}
 
 	reqBody := DoubaoRequest{
-		Model: "doubao-seed-translation-250915",
+		Model: model,
 		Input: []DoubaoInputMessage{
 			{
 				Role: "user",
@@ -100,53 +272,122 @@ func (c *DoubaoClient) Translate(text, source, target string) (string, error) {
 		},
 	}
 
-	jsonData, err := json.Marshal(reqBody)
+	jsonData, err := marshalWithExtra(reqBody, settings.Extra, extra)
 	if err != nil {
-		return "", fmt.Errorf("marshal error: %w", err)
+		return nil, fmt.Errorf("marshal error: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", c.apiURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", settings.APIURL, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", fmt.Errorf("create request error: %w", err)
+		return nil, fmt.Errorf("create request error: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	req.Header.Set("Authorization", "Bearer "+settings.APIKey)
 	req.Header.Set("Content-Type", "application/json")
+	if id := logging.RequestIDFromContext(ctx); id != "" {
+		req.Header.Set(logging.RequestIDHeader, id)
+	}
+	tracing.Inject(req)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("http request error: %w", err)
+		return nil, fmt.Errorf("http request error: %w", err)
 	}
 	defer resp.Body.Close()
 
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
 	body, _ := io.ReadAll(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API error: %d - %s", resp.StatusCode, string(body))
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	// Try new format first
 	var newResult DoubaoNewResponse
 	if err := json.Unmarshal(body, &newResult); err == nil && newResult.Status == "completed" {
+		result := &Result{Model: model, Provider: c.Name()}
+		if newResult.Usage != nil {
+			result.InputTokens = newResult.Usage.InputTokens
+			result.OutputTokens = newResult.Usage.OutputTokens
+		}
 		for _, output := range newResult.Output {
 			if output.Type == "message" && output.Role == "assistant" {
 				for _, content := range output.Content {
 					if content.Type == "output_text" {
-						return content.Text, nil
+						result.Text = content.Text
+						return result, nil
 					}
 				}
 			}
 		}
-		return "", fmt.Errorf("no output_text in new format")
+		return nil, &DecodeError{Reason: "no output_text in new format"}
 	}
 
 	// Fallback to old format
 	var oldResult DoubaoResponse
 	if err := json.Unmarshal(body, &oldResult); err == nil {
 		if len(oldResult.Choices) > 0 {
-			return oldResult.Choices[0].Message.Content, nil
+			return &Result{Text: oldResult.Choices[0].Message.Content, Model: model, Provider: c.Name()}, nil
 		}
 	}
 
-	return "", fmt.Errorf("unable to parse API response")
-}
\ No newline at end of file
+	return nil, &DecodeError{Reason: "unable to parse API response"}
+}
+
+// marshalWithExtra encodes body and merges extra option maps into the
+// top-level object, later maps taking precedence. Reserved keys are skipped.
+func marshalWithExtra(body interface{}, extras ...map[string]interface{}) ([]byte, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	hasExtra := false
+	for _, extra := range extras {
+		if len(extra) > 0 {
+			hasExtra = true
+		}
+	}
+	if !hasExtra {
+		return data, nil
+	}
+
+	var merged map[string]interface{}
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return nil, err
+	}
+	for _, extra := range extras {
+		for key, value := range extra {
+			if !reservedOptions[key] {
+				merged[key] = value
+			}
+		}
+	}
+	return json.Marshal(merged)
+}
+
+// Ping checks that the upstream endpoint is reachable and accepts the API
+// key without spending tokens. It sends an empty request, which a healthy
+// endpoint rejects with 400, so only auth failures, 5xx and transport
+// errors are reported.
+func (c *DoubaoClient) Ping(ctx context.Context) error {
+	settings := c.settings.Load()
+	req, err := http.NewRequestWithContext(ctx, "POST", settings.APIURL, bytes.NewBufferString("{}"))
+	if err != nil {
+		return fmt.Errorf("create request error: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+settings.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("http request error: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden || resp.StatusCode >= 500 {
+		return &APIError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/LouisLau-art/go-translator/tracing"
+)
+
+const completedResponse = `{"status":"completed","output":[{"type":"message","role":"assistant","content":[{"type":"output_text","text":"你好"}]}]}`
+
+func TestTranslateNewFormat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer test-key" {
+			t.Errorf("Expected bearer token, got '%s'", got)
+		}
+
+		var req DoubaoRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("Failed to decode request: %v", err)
+		}
+		opts := req.Input[0].Content[0].TranslationOptions
+		if opts.TargetLanguage != "zh" || opts.SourceLanguage != "en" {
+			t.Errorf("Unexpected translation options: %+v", opts)
+		}
+
+		w.Write([]byte(completedResponse))
+	}))
+	defer server.Close()
+
+	client := NewDoubaoClient("test-key", server.URL)
+	result, err := client.Translate(context.Background(), "hello", "en", "zh", Options{})
+	if err != nil {
+		t.Fatalf("Translate failed: %v", err)
+	}
+	if result.Text != "你好" {
+		t.Errorf("Expected '你好', got '%s'", result.Text)
+	}
+	if result.Model != DefaultModel {
+		t.Errorf("Expected default model, got '%s'", result.Model)
+	}
+}
+
+func TestTranslateRetriesServerErrors(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := tracing.Install(exporter, 1.0)
+	defer tp.Shutdown(context.Background())
+
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("traceparent") == "" {
+			t.Error("Expected trace context to be propagated upstream")
+		}
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(completedResponse))
+	}))
+	defer server.Close()
+
+	client := NewDoubaoClient("test-key", server.URL, WithMaxRetries(2), WithRetryBackoff(time.Millisecond))
+	if _, err := client.Translate(context.Background(), "hello", "en", "zh", Options{}); err != nil {
+		t.Fatalf("Translate failed: %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("Expected 3 upstream calls, got %d", calls.Load())
+	}
+
+	tp.ForceFlush(context.Background())
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(spans))
+	}
+
+	attrs := map[string]interface{}{}
+	for _, kv := range spans[0].Attributes {
+		attrs[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	if attrs["translation.retry_count"] != int64(2) {
+		t.Errorf("Expected retry_count 2, got %v", attrs["translation.retry_count"])
+	}
+	if attrs["translation.chunk_size"] != int64(5) {
+		t.Errorf("Expected chunk_size 5, got %v", attrs["translation.chunk_size"])
+	}
+	if attrs["translation.target_language"] != "zh" {
+		t.Errorf("Expected target_language zh, got %v", attrs["translation.target_language"])
+	}
+}
+
+func TestTranslateDoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client := NewDoubaoClient("bad-key", server.URL, WithMaxRetries(3), WithRetryBackoff(time.Millisecond))
+	_, err := client.Translate(context.Background(), "hello", "", "zh", Options{})
+	if err == nil {
+		t.Fatal("Expected error for unauthorized response")
+	}
+	if ErrorClass(err) != ErrClassAuth {
+		t.Errorf("Expected error class %s, got %s", ErrClassAuth, ErrorClass(err))
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected 1 upstream call, got %d", calls.Load())
+	}
+}
+
+func TestPingClassifiesStatus(t *testing.T) {
+	status := http.StatusBadRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	client := NewDoubaoClient("test-key", server.URL)
+
+	// A 400 for the empty probe body means the endpoint and key are fine
+	if err := client.Ping(context.Background()); err != nil {
+		t.Errorf("Expected healthy ping for 400, got %v", err)
+	}
+
+	status = http.StatusUnauthorized
+	if err := client.Ping(context.Background()); ErrorClass(err) != ErrClassAuth {
+		t.Errorf("Expected auth error, got %v", err)
+	}
+
+	status = http.StatusServiceUnavailable
+	if err := client.Ping(context.Background()); ErrorClass(err) != ErrClassServer {
+		t.Errorf("Expected server error, got %v", err)
+	}
+}
+
+func TestTranslateModelOverrideAndExtraOptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode request: %v", err)
+		}
+		if body["model"] != "doubao-new-model" {
+			t.Errorf("Expected overridden model, got %v", body["model"])
+		}
+		if body["temperature"] != 0.2 {
+			t.Errorf("Expected per-request temperature 0.2, got %v", body["temperature"])
+		}
+		if body["max_output_tokens"] != float64(512) {
+			t.Errorf("Expected configured max_output_tokens, got %v", body["max_output_tokens"])
+		}
+		if _, ok := body["input"].([]interface{}); !ok {
+			t.Errorf("Expected input to survive extra options, got %v", body["input"])
+		}
+		w.Write([]byte(completedResponse))
+	}))
+	defer server.Close()
+
+	client := NewDoubaoClient("test-key", server.URL,
+		WithModel("", map[string]interface{}{"max_output_tokens": 512, "temperature": 0.8}))
+
+	result, err := client.Translate(context.Background(), "hello", "en", "zh", Options{
+		Model: "doubao-new-model",
+		Extra: map[string]interface{}{"temperature": 0.2, "input": "ignored"},
+	})
+	if err != nil {
+		t.Fatalf("Translate failed: %v", err)
+	}
+	if result.Model != "doubao-new-model" {
+		t.Errorf("Expected result to report the model used, got '%s'", result.Model)
+	}
+}
+
+func TestValidateExtraOptions(t *testing.T) {
+	if err := ValidateExtraOptions(map[string]interface{}{"temperature": 0.1}); err != nil {
+		t.Errorf("Expected temperature to be allowed, got %v", err)
+	}
+	if err := ValidateExtraOptions(map[string]interface{}{"stream": true}); err == nil {
+		t.Error("Expected stream to be rejected")
+	}
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// Error classes used to group upstream failures
+const (
+	ErrClassTimeout     = "timeout"
+	ErrClassNetwork     = "network"
+	ErrClassAuth        = "auth"
+	ErrClassRateLimited = "rate_limited"
+	ErrClassClient      = "client_error"
+	ErrClassServer      = "server_error"
+	ErrClassDecode      = "decode"
+	ErrClassUnavailable = "unavailable"
+	ErrClassUnknown     = "unknown"
+)
+
+// APIError is returned when the upstream API responds with a non-200 status
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API error: %d - %s", e.StatusCode, e.Body)
+}
+
+// DecodeError is returned when the upstream response cannot be parsed
+type DecodeError struct {
+	Reason string
+}
+
+func (e *DecodeError) Error() string {
+	return e.Reason
+}
+
+// ErrorClass maps an error returned by a client to a coarse error class
+func ErrorClass(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.StatusCode == 401 || apiErr.StatusCode == 403:
+			return ErrClassAuth
+		case apiErr.StatusCode == 429:
+			return ErrClassRateLimited
+		case apiErr.StatusCode >= 500:
+			return ErrClassServer
+		default:
+			return ErrClassClient
+		}
+	}
+
+	if errors.Is(err, ErrUnavailable) {
+		return ErrClassUnavailable
+	}
+
+	var decodeErr *DecodeError
+	if errors.As(err, &decodeErr) {
+		return ErrClassDecode
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrClassTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ErrClassTimeout
+		}
+		return ErrClassNetwork
+	}
+
+	return ErrClassUnknown
+}
+
+// Retryable reports whether err is a transient failure worth retrying
+func Retryable(err error) bool {
+	switch ErrorClass(err) {
+	case ErrClassTimeout, ErrClassNetwork, ErrClassRateLimited, ErrClassServer:
+		return !errors.Is(err, context.Canceled)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// OfflineModel is the model name reported by the offline provider
+const OfflineModel = "offline-phrasebook"
+
+//go:embed offline_phrases.json
+var offlinePhrasesJSON []byte
+
+// OfflineClient is a deterministic provider that needs no network or API
+// key, for local development and end-to-end tests. Lines found in a small
+// bundled phrase table are translated; any other line is returned tagged
+// with the target language, e.g. "[zh] Some text".
+type OfflineClient struct {
+	// phrases maps a normalized phrase to its translation per language
+	phrases map[string]map[string]string
+}
+
+// NewOfflineClient creates an offline provider with the bundled phrase table
+func NewOfflineClient() *OfflineClient {
+	var phrases map[string]map[string]string
+	if err := json.Unmarshal(offlinePhrasesJSON, &phrases); err != nil {
+		panic(fmt.Sprintf("offline phrase table: %v", err))
+	}
+	return &OfflineClient{phrases: phrases}
+}
+
+// Name identifies the provider
+func (c *OfflineClient) Name() string {
+	return "offline"
+}
+
+// Ping always succeeds; the provider has no dependencies
+func (c *OfflineClient) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Translate translates text line by line, keeping blank lines and
+// indentation so chunked documents keep their layout
+func (c *OfflineClient) Translate(ctx context.Context, text, source, target string, opts Options) (*Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = c.translateLine(line, target)
+	}
+
+	model := OfflineModel
+	if opts.Model != "" {
+		model = opts.Model
+	}
+	return &Result{Text: strings.Join(lines, "\n"), Model: model, Provider: c.Name()}, nil
+}
+
+func (c *OfflineClient) translateLine(line, target string) string {
+	body := strings.TrimSpace(line)
+	if body == "" {
+		return line
+	}
+	indent := line[:strings.Index(line, body)]
+
+	// Keep trailing punctuation out of the lookup and put it back after
+	phrase := strings.TrimRightFunc(body, unicode.IsPunct)
+	punct := body[len(phrase):]
+
+	if translations, ok := c.phrases[strings.ToLower(phrase)]; ok {
+		if translated, ok := translations[target]; ok {
+			return indent + translated + punct
+		}
+	}
+	return indent + "[" + target + "] " + body
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"context"
+	"testing"
+)
+
+func TestOfflineTranslate(t *testing.T) {
+	client := NewOfflineClient()
+
+	tests := []struct {
+		text   string
+		target string
+		want   string
+	}{
+		{"Hello", "zh", "你好"},
+		{"Thank you!", "ja", "ありがとうございます!"},
+		{"Hello\n\n  Unknown line", "fr", "bonjour\n\n  [fr] Unknown line"},
+		{"谢谢", "en", "thank you"},
+	}
+
+	for _, tt := range tests {
+		result, err := client.Translate(context.Background(), tt.text, "", tt.target, Options{})
+		if err != nil {
+			t.Fatalf("Translate(%q) failed: %v", tt.text, err)
+		}
+		if result.Text != tt.want {
+			t.Errorf("Translate(%q, %s) = %q, want %q", tt.text, tt.target, result.Text, tt.want)
+		}
+		if result.Provider != "offline" || result.Model != OfflineModel {
+			t.Errorf("Unexpected provider or model: %+v", result)
+		}
+	}
+}
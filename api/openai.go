@@ -0,0 +1,254 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/LouisLau-art/go-translator/logging"
+	"github.com/LouisLau-art/go-translator/tracing"
+)
+
+// OpenAIClient translates through any OpenAI-compatible chat completions
+// endpoint, such as a self-hosted model server
+type OpenAIClient struct {
+	settings     atomic.Pointer[OpenAISettings]
+	httpClient   *http.Client
+	retryBackoff time.Duration
+}
+
+// OpenAISettings are the endpoint parameters that can be swapped at runtime
+type OpenAISettings struct {
+	// APIKey is sent as a bearer token when set
+	APIKey string
+	// BaseURL is the API root, e.g. http://localhost:8000/v1
+	BaseURL    string
+	Model      string
+	MaxRetries int
+	Extra      map[string]interface{}
+}
+
+// OpenAIOption customizes an OpenAIClient
+type OpenAIOption func(*OpenAIClient)
+
+// WithOpenAIHTTPClient replaces the underlying HTTP client
+func WithOpenAIHTTPClient(hc *http.Client) OpenAIOption {
+	return func(c *OpenAIClient) {
+		c.httpClient = hc
+	}
+}
+
+// WithOpenAIRetryBackoff sets the initial delay between retries, doubled
+// per attempt
+func WithOpenAIRetryBackoff(d time.Duration) OpenAIOption {
+	return func(c *OpenAIClient) {
+		c.retryBackoff = d
+	}
+}
+
+// NewOpenAIClient creates a chat completions client
+func NewOpenAIClient(settings OpenAISettings, opts ...OpenAIOption) *OpenAIClient {
+	c := &OpenAIClient{
+		httpClient: &http.Client{
+			// Local models can be slow on long chunks
+			Timeout: 120 * time.Second,
+		},
+		retryBackoff: 500 * time.Millisecond,
+	}
+	c.settings.Store(&settings)
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Name identifies the provider
+func (c *OpenAIClient) Name() string {
+	return "openai"
+}
+
+// Settings returns a copy of the current endpoint settings
+func (c *OpenAIClient) Settings() OpenAISettings {
+	return *c.settings.Load()
+}
+
+// UpdateSettings atomically replaces the endpoint settings. Calls already
+// in flight finish with the settings they started with.
+func (c *OpenAIClient) UpdateSettings(settings OpenAISettings) {
+	c.settings.Store(&settings)
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatCompletionRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+	Stream   bool          `json:"stream"`
+}
+
+type chatCompletionResponse struct {
+	Choices []struct {
+		Message      chatMessage `json:"message"`
+		FinishReason string      `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage,omitempty"`
+}
+
+// translationPrompt is the system prompt sent with every chunk, naming the
+// context the text is used in when there is one
+func translationPrompt(source, target, context string) string {
+	from := "the source language (detect it)"
+	if source != "" {
+		from = fmt.Sprintf("the language with code %q", source)
+	}
+	prompt := fmt.Sprintf("You are a professional translator. Translate the user's text from %s "+
+		"into the language with code %q. Reply with the translation only, without explanations "+
+		"or quotes. Preserve line breaks, Markdown, code, URLs and placeholders such as {name} or %%s.",
+		from, target)
+	if context != "" {
+		prompt += fmt.Sprintf(" The text is used in this context, which picks its meaning; do not translate the context itself: %q.", context)
+	}
+	return prompt
+}
+
+// Translate sends a chat completion request, retrying transient failures
+// up to the configured limit
+func (c *OpenAIClient) Translate(ctx context.Context, text, source, target string, opts Options) (*Result, error) {
+	ctx, span := tracing.Start(ctx, "OpenAIClient.Translate",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.Int("translation.chunk_size", len(text)),
+			attribute.String("translation.source_language", source),
+			attribute.String("translation.target_language", target),
+		))
+	defer span.End()
+
+	settings := c.settings.Load()
+	model := settings.Model
+	if opts.Model != "" {
+		model = opts.Model
+	}
+	span.SetAttributes(attribute.String("translation.model", model))
+
+	result, retries, err := withRetries(ctx, span, settings.MaxRetries, c.retryBackoff, func() (*Result, error) {
+		return observe(ctx, text, func() (*Result, error) {
+			return c.translate(ctx, settings, model, text, source, target, opts)
+		})
+	})
+
+	span.SetAttributes(attribute.Int("translation.retry_count", retries))
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *OpenAIClient) translate(ctx context.Context, settings *OpenAISettings, model, text, source, target string, opts Options) (*Result, error) {
+	reqBody := chatCompletionRequest{
+		Model: model,
+		Messages: []chatMessage{
+			{Role: "system", Content: translationPrompt(source, target, opts.Context)},
+			{Role: "user", Content: text},
+		},
+	}
+
+	jsonData, err := marshalWithExtra(reqBody, settings.Extra, opts.Extra)
+	if err != nil {
+		return nil, fmt.Errorf("marshal error: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint(settings.BaseURL, "/chat/completions"), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("create request error: %w", err)
+	}
+	c.setHeaders(req, settings)
+	if id := logging.RequestIDFromContext(ctx); id != "" {
+		req.Header.Set(logging.RequestIDHeader, id)
+	}
+	tracing.Inject(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http request error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var completion chatCompletionResponse
+	if err := json.Unmarshal(body, &completion); err != nil {
+		return nil, &DecodeError{Reason: "unable to parse chat completion: " + err.Error()}
+	}
+	if len(completion.Choices) == 0 {
+		return nil, &DecodeError{Reason: "no choices in chat completion"}
+	}
+
+	result := &Result{
+		Text:     strings.TrimSpace(completion.Choices[0].Message.Content),
+		Model:    model,
+		Provider: c.Name(),
+	}
+	if completion.Usage != nil {
+		result.InputTokens = completion.Usage.PromptTokens
+		result.OutputTokens = completion.Usage.CompletionTokens
+	}
+	return result, nil
+}
+
+// Ping checks that the endpoint is reachable and accepts the API key by
+// listing models, which costs no tokens
+func (c *OpenAIClient) Ping(ctx context.Context) error {
+	settings := c.settings.Load()
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint(settings.BaseURL, "/models"), nil)
+	if err != nil {
+		return fmt.Errorf("create request error: %w", err)
+	}
+	c.setHeaders(req, settings)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("http request error: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden || resp.StatusCode >= 500 {
+		return &APIError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return nil
+}
+
+func (c *OpenAIClient) setHeaders(req *http.Request, settings *OpenAISettings) {
+	if settings.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+settings.APIKey)
+	}
+	req.Header.Set("Content-Type", "application/json")
+}
+
+// endpoint joins the API root and path. A full chat completions URL is
+// accepted as the base URL too.
+func endpoint(baseURL, path string) string {
+	root := strings.TrimSuffix(strings.TrimRight(baseURL, "/"), "/chat/completions")
+	return root + path
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeChatServer serves chat completions, echoing the request for checks
+func fakeChatServer(t *testing.T, handle func(w http.ResponseWriter, req chatCompletionRequest, extra map[string]interface{})) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/models" {
+			w.Write([]byte(`{"data":[]}`))
+			return
+		}
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+
+		var raw map[string]interface{}
+		body := json.NewDecoder(r.Body)
+		if err := body.Decode(&raw); err != nil {
+			t.Fatalf("Failed to decode request: %v", err)
+		}
+		data, _ := json.Marshal(raw)
+		var req chatCompletionRequest
+		json.Unmarshal(data, &req)
+		handle(w, req, raw)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOpenAITranslate(t *testing.T) {
+	server := fakeChatServer(t, func(w http.ResponseWriter, req chatCompletionRequest, extra map[string]interface{}) {
+		if req.Model != "qwen2.5" {
+			t.Errorf("Expected configured model, got %s", req.Model)
+		}
+		if len(req.Messages) != 2 || req.Messages[0].Role != "system" || req.Messages[1].Content != "hello" {
+			t.Errorf("Unexpected messages: %+v", req.Messages)
+		}
+		if !strings.Contains(req.Messages[0].Content, `"zh"`) || !strings.Contains(req.Messages[0].Content, `"en"`) {
+			t.Errorf("Expected languages in the system prompt, got %q", req.Messages[0].Content)
+		}
+		if extra["temperature"] != 0.1 {
+			t.Errorf("Expected extra options in the body, got %v", extra)
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":" 你好\n"},"finish_reason":"stop"}],"usage":{"prompt_tokens":12,"completion_tokens":3}}`))
+	})
+
+	client := NewOpenAIClient(OpenAISettings{
+		BaseURL: server.URL + "/v1",
+		Model:   "qwen2.5",
+		Extra:   map[string]interface{}{"temperature": 0.1},
+	})
+	result, err := client.Translate(context.Background(), "hello", "en", "zh", Options{})
+	if err != nil {
+		t.Fatalf("Translate failed: %v", err)
+	}
+	if result.Text != "你好" || result.Provider != "openai" {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if result.InputTokens != 12 || result.OutputTokens != 3 {
+		t.Errorf("Expected usage to be reported, got %d/%d", result.InputTokens, result.OutputTokens)
+	}
+}
+
+func TestOpenAITranslateWithContext(t *testing.T) {
+	server := fakeChatServer(t, func(w http.ResponseWriter, req chatCompletionRequest, extra map[string]interface{}) {
+		if !strings.Contains(req.Messages[0].Content, `"toolbar"`) || req.Messages[1].Content != "Open" {
+			t.Errorf("Expected the context in the system prompt, got %+v", req.Messages)
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"打开"},"finish_reason":"stop"}]}`))
+	})
+
+	client := NewOpenAIClient(OpenAISettings{BaseURL: server.URL + "/v1", Model: "m"})
+	if _, err := client.Translate(context.Background(), "Open", "en", "zh", Options{Context: "toolbar"}); err != nil {
+		t.Fatalf("Translate failed: %v", err)
+	}
+}
+
+func TestOpenAIErrorMapping(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		class   string
+		retries int32
+	}{
+		{"server error is retried", http.StatusBadGateway, `{"error":"down"}`, ErrClassServer, 3},
+		{"auth is not retried", http.StatusUnauthorized, `{"error":"bad key"}`, ErrClassAuth, 1},
+		{"empty choices", http.StatusOK, `{"choices":[]}`, ErrClassDecode, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := fakeChatServer(t, func(w http.ResponseWriter, req chatCompletionRequest, extra map[string]interface{}) {
+				calls.Add(1)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			})
+
+			client := NewOpenAIClient(OpenAISettings{BaseURL: server.URL + "/v1/chat/completions", Model: "m", MaxRetries: 2},
+				WithOpenAIRetryBackoff(time.Millisecond))
+			_, err := client.Translate(context.Background(), "hello", "", "zh", Options{})
+			if got := ErrorClass(err); got != tt.class {
+				t.Errorf("Expected class %s, got %s (%v)", tt.class, got, err)
+			}
+			if calls.Load() != tt.retries {
+				t.Errorf("Expected %d calls, got %d", tt.retries, calls.Load())
+			}
+		})
+	}
+}
+
+func TestOpenAIPing(t *testing.T) {
+	server := fakeChatServer(t, nil)
+	client := NewOpenAIClient(OpenAISettings{BaseURL: server.URL + "/v1/"})
+	if err := client.Ping(context.Background()); err != nil {
+		t.Errorf("Expected ping to succeed, got %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/LouisLau-art/go-translator/logging"
+	"github.com/LouisLau-art/go-translator/metrics"
+)
+
+// withRetries runs call until it succeeds, fails with a non-retryable
+// error or maxRetries retries were spent, waiting backoff<<attempt between
+// attempts. Retries are recorded as events on span.
+func withRetries(ctx context.Context, span trace.Span, maxRetries int, backoff time.Duration, call func() (*Result, error)) (*Result, int, error) {
+	var (
+		result *Result
+		err    error
+	)
+	retries := 0
+	for attempt := 0; ; attempt++ {
+		result, err = call()
+		if err == nil || attempt >= maxRetries || !Retryable(err) {
+			break
+		}
+
+		retries++
+		span.AddEvent("retry", trace.WithAttributes(
+			attribute.Int("attempt", attempt+1),
+			attribute.String("error_class", ErrorClass(err)),
+		))
+
+		delay := backoff << attempt
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return result, retries, err
+}
+
+// observe performs a single upstream call and records its metrics
+func observe(ctx context.Context, text string, call func() (*Result, error)) (*Result, error) {
+	start := time.Now()
+	result, err := call()
+	elapsed := time.Since(start)
+
+	logger := logging.FromContext(ctx)
+	if err != nil {
+		metrics.UpstreamDuration.WithLabelValues("error").Observe(elapsed.Seconds())
+		metrics.UpstreamErrors.WithLabelValues(ErrorClass(err)).Inc()
+		logger.Warn("upstream call failed",
+			slog.String("error_class", ErrorClass(err)),
+			slog.Duration("latency", elapsed),
+			slog.Any("error", err))
+		return nil, err
+	}
+
+	metrics.UpstreamDuration.WithLabelValues("success").Observe(elapsed.Seconds())
+	if result.InputTokens > 0 || result.OutputTokens > 0 {
+		metrics.UpstreamTokens.WithLabelValues("input").Add(float64(result.InputTokens))
+		metrics.UpstreamTokens.WithLabelValues("output").Add(float64(result.OutputTokens))
+	}
+	logger.Debug("upstream call succeeded",
+		slog.Int("text_length", len(text)),
+		slog.Duration("latency", elapsed))
+	return result, nil
+}
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/LouisLau-art/go-translator/breaker"
+	"github.com/LouisLau-art/go-translator/logging"
+	"github.com/LouisLau-art/go-translator/metrics"
+	"github.com/LouisLau-art/go-translator/tracing"
+)
+
+// ErrUnavailable is returned when every provider's breaker is open
+var ErrUnavailable = errors.New("all translation providers are unavailable")
+
+// UnavailableError is returned instead of calling any provider while every
+// breaker is open. It matches ErrUnavailable with errors.Is.
+type UnavailableError struct {
+	// RetryAfter is when the first breaker will let a call through again
+	RetryAfter time.Duration
+}
+
+func (e *UnavailableError) Error() string {
+	return ErrUnavailable.Error()
+}
+
+func (e *UnavailableError) Unwrap() error {
+	return ErrUnavailable
+}
+
+// Route is one step of a fallback chain
+type Route struct {
+	// Name labels the route in logs, metrics and responses
+	Name string
+	// Translator is the provider serving the route
+	Translator Translator
+	// Model pins the model for this route; empty uses the provider default
+	Model string
+}
+
+// Router tries routes in order, skipping those whose breaker is open, and
+// falls back to the next route when one fails
+type Router struct {
+	routes []*routeState
+}
+
+type routeState struct {
+	Route
+	breaker *breaker.Breaker
+}
+
+// RouterOption customizes a Router
+type RouterOption func(*routerConfig)
+
+type routerConfig struct {
+	breaker breaker.Settings
+}
+
+// WithBreaker sets the circuit breaker settings used for every route
+func WithBreaker(settings breaker.Settings) RouterOption {
+	return func(c *routerConfig) {
+		c.breaker = settings
+	}
+}
+
+// NewRouter creates a router over routes, in order of preference
+func NewRouter(routes []Route, opts ...RouterOption) *Router {
+	cfg := routerConfig{breaker: breaker.DefaultSettings()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	r := &Router{}
+	for _, route := range routes {
+		if route.Name == "" {
+			route.Name = route.Translator.Name()
+		}
+		r.routes = append(r.routes, &routeState{
+			Route:   route,
+			breaker: breaker.New(route.Name, cfg.breaker),
+		})
+	}
+	return r
+}
+
+// Name identifies the router
+func (r *Router) Name() string {
+	return "router"
+}
+
+// Ping reports the router healthy when every provider that can be pinged
+// is reachable. A provider left unreachable would make the chain fall back
+// on every request, so it fails the check even while another route works.
+func (r *Router) Ping(ctx context.Context) error {
+	results := r.PingRoutes(ctx)
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(results)) {
+		if err := results[name]; err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// PingRoutes pings every route whose provider supports it, returning the
+// result of each by route name. Routes that cannot be pinged are left out.
+func (r *Router) PingRoutes(ctx context.Context) map[string]error {
+	results := make(map[string]error, len(r.routes))
+	for _, route := range r.routes {
+		pinger, ok := route.Translator.(interface{ Ping(context.Context) error })
+		if !ok {
+			continue
+		}
+		results[route.Name] = pinger.Ping(ctx)
+	}
+	return results
+}
+
+// BreakerStates returns the circuit breaker state of every route
+func (r *Router) BreakerStates() map[string]string {
+	states := make(map[string]string, len(r.routes))
+	for _, route := range r.routes {
+		states[route.Name] = route.breaker.State().String()
+	}
+	return states
+}
+
+// Translate translates text with the first route that succeeds. A model
+// requested in opts applies to the primary route only; fallback routes use
+// their own model. When every breaker is open it fails fast with an
+// *UnavailableError.
+func (r *Router) Translate(ctx context.Context, text, source, target string, opts Options) (*Result, error) {
+	ctx, span := tracing.Start(ctx, "Router.Translate")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+
+	var (
+		lastErr    error
+		retryAfter time.Duration
+	)
+	for i, route := range r.routes {
+		token, err := route.breaker.Allow()
+		if err != nil {
+			var openErr *breaker.OpenError
+			if errors.As(err, &openErr) && (retryAfter == 0 || openErr.RetryAfter < retryAfter) {
+				retryAfter = openErr.RetryAfter
+			}
+			metrics.ProviderRequests.WithLabelValues(route.Name, "skipped").Inc()
+			span.AddEvent("breaker_open", trace.WithAttributes(attribute.String("provider", route.Name)))
+			continue
+		}
+
+		routeOpts := opts
+		if i > 0 || opts.Model == "" {
+			routeOpts.Model = route.Model
+		}
+
+		result, err := route.Translator.Translate(ctx, text, source, target, routeOpts)
+		if err == nil {
+			route.breaker.Success(token)
+			metrics.ProviderRequests.WithLabelValues(route.Name, "success").Inc()
+			result.Provider = route.Name
+			result.Fallback = i > 0
+			if result.Fallback {
+				metrics.FallbackResponses.Inc()
+			}
+			span.SetAttributes(
+				attribute.String("translation.provider", route.Name),
+				attribute.Bool("translation.fallback", result.Fallback),
+			)
+			return result, nil
+		}
+
+		if ctx.Err() != nil {
+			route.breaker.Release(token)
+			tracing.RecordError(span, err)
+			return nil, err
+		}
+		if ErrorClass(err) == ErrClassClient {
+			// The provider answered; the request itself was rejected and
+			// would be rejected by the others too
+			route.breaker.Success(token)
+			tracing.RecordError(span, err)
+			return nil, err
+		}
+
+		route.breaker.Failure(token)
+		metrics.ProviderRequests.WithLabelValues(route.Name, "error").Inc()
+		logger.Warn("provider failed, trying next",
+			slog.String("provider", route.Name),
+			slog.String("error_class", ErrorClass(err)),
+			slog.Any("error", err))
+		lastErr = err
+	}
+
+	if lastErr == nil {
+		lastErr = &UnavailableError{RetryAfter: retryAfter}
+	}
+	tracing.RecordError(span, lastErr)
+	return nil, lastErr
+}
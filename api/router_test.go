@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/LouisLau-art/go-translator/breaker"
+)
+
+// fakeTranslator returns err when set, otherwise a canned translation
+type fakeTranslator struct {
+	name   string
+	err    error
+	calls  int
+	models []string
+}
+
+func (f *fakeTranslator) Name() string { return f.name }
+
+func (f *fakeTranslator) Translate(ctx context.Context, text, source, target string, opts Options) (*Result, error) {
+	f.calls++
+	f.models = append(f.models, opts.Model)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &Result{Text: f.name + ":" + text, Model: opts.Model, Provider: f.name}, nil
+}
+
+func TestRouterFallsBackAndReportsProvider(t *testing.T) {
+	primary := &fakeTranslator{name: "primary", err: &APIError{StatusCode: 503}}
+	secondary := &fakeTranslator{name: "secondary"}
+	router := NewRouter([]Route{
+		{Translator: primary},
+		{Name: "secondary:small", Translator: secondary, Model: "small"},
+	}, WithBreaker(breaker.Settings{FailureThreshold: 2, Cooldown: time.Minute}))
+
+	result, err := router.Translate(context.Background(), "hi", "en", "zh", Options{Model: "big"})
+	if err != nil {
+		t.Fatalf("Expected fallback to succeed, got %v", err)
+	}
+	if result.Provider != "secondary:small" || !result.Fallback {
+		t.Errorf("Expected fallback result from secondary:small, got %+v", result)
+	}
+	if primary.models[0] != "big" || secondary.models[0] != "small" {
+		t.Errorf("Expected requested model on primary and pinned model on fallback, got %v and %v", primary.models, secondary.models)
+	}
+
+	// The second failure opens the primary's breaker, so the third call
+	// goes straight to the fallback
+	router.Translate(context.Background(), "hi", "en", "zh", Options{})
+	router.Translate(context.Background(), "hi", "en", "zh", Options{})
+	if primary.calls != 2 {
+		t.Errorf("Expected open breaker to skip primary, got %d calls", primary.calls)
+	}
+}
+
+func TestRouterDoesNotFallBackOnClientErrors(t *testing.T) {
+	primary := &fakeTranslator{name: "primary", err: &APIError{StatusCode: 400}}
+	secondary := &fakeTranslator{name: "secondary"}
+	router := NewRouter([]Route{{Translator: primary}, {Translator: secondary}})
+
+	_, err := router.Translate(context.Background(), "hi", "en", "zh", Options{})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 400 {
+		t.Errorf("Expected the client error to be returned, got %v", err)
+	}
+	if secondary.calls != 0 {
+		t.Error("Expected no fallback for a rejected request")
+	}
+}
+
+func TestRouterUnavailableWhenAllBreakersOpen(t *testing.T) {
+	only := &fakeTranslator{name: "only", err: &APIError{StatusCode: 500}}
+	router := NewRouter([]Route{{Translator: only}}, WithBreaker(breaker.Settings{FailureThreshold: 1, Cooldown: time.Minute}))
+
+	router.Translate(context.Background(), "hi", "en", "zh", Options{})
+	_, err := router.Translate(context.Background(), "hi", "en", "zh", Options{})
+	var unavailable *UnavailableError
+	if !errors.As(err, &unavailable) || !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("Expected UnavailableError, got %v", err)
+	}
+	if unavailable.RetryAfter <= 0 || unavailable.RetryAfter > time.Minute {
+		t.Errorf("Expected RetryAfter within the cooldown, got %v", unavailable.RetryAfter)
+	}
+	if only.calls != 1 {
+		t.Errorf("Expected open breaker to fail fast, got %d calls", only.calls)
+	}
+	if got := router.BreakerStates()["only"]; got != "open" {
+		t.Errorf("Expected breaker state open, got %q", got)
+	}
+	if ErrorClass(err) != ErrClassUnavailable {
+		t.Errorf("Expected unavailable error class, got %s", ErrorClass(err))
+	}
+}
+
+// pingTranslator is a fakeTranslator that can be pinged
+type pingTranslator struct {
+	fakeTranslator
+	pingErr error
+}
+
+func (p *pingTranslator) Ping(context.Context) error { return p.pingErr }
+
+func TestRouterPingChecksEveryRoute(t *testing.T) {
+	// A failing primary is reported even though the offline fallback, and
+	// a route that cannot be pinged, would keep serving
+	router := NewRouter([]Route{
+		{Translator: &pingTranslator{fakeTranslator: fakeTranslator{name: "doubao"}, pingErr: &APIError{StatusCode: 401}}},
+		{Translator: &fakeTranslator{name: "custom"}},
+		{Translator: NewOfflineClient()},
+	})
+
+	results := router.PingRoutes(context.Background())
+	if len(results) != 2 || results["doubao"] == nil || results["offline"] != nil {
+		t.Errorf("PingRoutes = %v", results)
+	}
+	if err := router.Ping(context.Background()); err == nil {
+		t.Error("Expected Ping to fail while doubao is unreachable")
+	}
+
+	router = NewRouter([]Route{{Translator: &fakeTranslator{name: "custom"}}, {Translator: NewOfflineClient()}})
+	if err := router.Ping(context.Background()); err != nil {
+		t.Errorf("Expected healthy router, got %v", err)
+	}
+}
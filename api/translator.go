@@ -0,0 +1,11 @@
+package api
+
+import "context"
+
+// Translator is implemented by every translation provider
+type Translator interface {
+	// Name identifies the provider in logs, metrics and responses
+	Name() string
+	// Translate translates text from source to target language
+	Translate(ctx context.Context, text, source, target string, opts Options) (*Result, error)
+}
@@ -0,0 +1,211 @@
+package breaker
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/LouisLau-art/go-translator/metrics"
+)
+
+// State is the position of a circuit breaker
+type State int
+
+const (
+	// Closed lets every call through and counts consecutive failures
+	Closed State = iota
+	// HalfOpen lets a limited number of probe calls through after the
+	// cooldown to test whether the dependency has recovered
+	HalfOpen
+	// Open rejects calls until the cooldown has passed
+	Open
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case HalfOpen:
+		return "half_open"
+	case Open:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// Settings configures a breaker
+type Settings struct {
+	// FailureThreshold is how many consecutive failures open the breaker
+	FailureThreshold int
+	// Cooldown is how long the breaker stays open before probing
+	Cooldown time.Duration
+	// HalfOpenProbes is how many concurrent probe calls are allowed while
+	// half-open; that many successes in a row close the breaker again
+	HalfOpenProbes int
+}
+
+// DefaultSettings returns the settings used when none are configured
+func DefaultSettings() Settings {
+	return Settings{FailureThreshold: 5, Cooldown: 30 * time.Second, HalfOpenProbes: 1}
+}
+
+// OpenError is returned by Allow while the breaker rejects calls
+type OpenError struct {
+	Name       string
+	RetryAfter time.Duration
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("circuit breaker %q is open, retry after %v", e.Name, e.RetryAfter)
+}
+
+// Breaker stops calls to a failing dependency. After FailureThreshold
+// consecutive failures it opens and rejects calls for Cooldown, then lets
+// probe calls through half-open: successful probes close it, a failed
+// probe opens it again.
+type Breaker struct {
+	name     string
+	settings Settings
+	now      func() time.Time
+
+	mu        sync.Mutex
+	state     State
+	failures  int
+	successes int
+	probes    int
+	openedAt  time.Time
+	// generation counts state changes, so results of calls allowed in an
+	// earlier state can be told apart
+	generation uint64
+}
+
+// Token identifies an allowed call. Its result is only counted while the
+// breaker is still in the state that allowed it.
+type Token struct {
+	generation uint64
+}
+
+// New creates a closed breaker. The name labels its metrics.
+func New(name string, settings Settings) *Breaker {
+	if settings.FailureThreshold < 1 {
+		settings.FailureThreshold = 1
+	}
+	if settings.HalfOpenProbes < 1 {
+		settings.HalfOpenProbes = 1
+	}
+	b := &Breaker{name: name, settings: settings, now: time.Now}
+	metrics.BreakerState.WithLabelValues(name).Set(float64(Closed))
+	return b
+}
+
+// Name returns the breaker's name
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State returns the current state, moving from open to half-open once
+// the cooldown has passed
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+	return b.state
+}
+
+// Allow reserves a call. It returns an *OpenError while the breaker is
+// open or all half-open probe slots are taken. Every allowed call must be
+// finished by passing its token to Success, Failure or Release.
+func (b *Breaker) Allow() (Token, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+
+	switch b.state {
+	case Open:
+		return Token{}, &OpenError{Name: b.name, RetryAfter: b.settings.Cooldown - b.now().Sub(b.openedAt)}
+	case HalfOpen:
+		if b.probes >= b.settings.HalfOpenProbes {
+			return Token{}, &OpenError{Name: b.name, RetryAfter: time.Second}
+		}
+		b.probes++
+	}
+	return Token{generation: b.generation}, nil
+}
+
+// Success records a successful call. A call allowed before the breaker
+// last changed state is ignored, so a slow call from before it opened
+// cannot close it while its probes are still running.
+func (b *Breaker) Success(t Token) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if t.generation != b.generation {
+		return
+	}
+
+	switch b.state {
+	case Closed:
+		b.failures = 0
+	case HalfOpen:
+		b.probes--
+		b.successes++
+		if b.successes >= b.settings.HalfOpenProbes {
+			b.transition(Closed)
+		}
+	}
+}
+
+// Failure records a failed call, opening the breaker once the threshold
+// is reached or when a half-open probe fails. Like Success, it ignores
+// calls allowed before the last state change.
+func (b *Breaker) Failure(t Token) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if t.generation != b.generation {
+		return
+	}
+
+	switch b.state {
+	case Closed:
+		b.failures++
+		if b.failures >= b.settings.FailureThreshold {
+			b.transition(Open)
+		}
+	case HalfOpen:
+		b.probes--
+		b.transition(Open)
+	}
+}
+
+// Release ends an allowed call without judging the dependency, for
+// example when the caller gave up before it answered
+func (b *Breaker) Release(t Token) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if t.generation == b.generation && b.state == HalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+// advance moves an open breaker to half-open once the cooldown has passed.
+// Callers must hold b.mu.
+func (b *Breaker) advance() {
+	if b.state == Open && b.now().Sub(b.openedAt) >= b.settings.Cooldown {
+		b.transition(HalfOpen)
+	}
+}
+
+// transition switches state, resetting counters. Callers must hold b.mu.
+func (b *Breaker) transition(to State) {
+	if b.state == to {
+		return
+	}
+	b.state = to
+	b.generation++
+	b.failures, b.successes, b.probes = 0, 0, 0
+	if to == Open {
+		b.openedAt = b.now()
+	}
+	metrics.BreakerState.WithLabelValues(b.name).Set(float64(to))
+	metrics.BreakerTransitions.WithLabelValues(b.name, to.String()).Inc()
+}
@@ -0,0 +1,134 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestBreaker(settings Settings) (*Breaker, *time.Time) {
+	now := time.Now()
+	b := New("test", settings)
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	b, _ := newTestBreaker(Settings{FailureThreshold: 2, Cooldown: time.Minute})
+
+	for i := 0; i < 2; i++ {
+		token, err := b.Allow()
+		if err != nil {
+			t.Fatalf("Expected closed breaker to allow call %d, got %v", i, err)
+		}
+		b.Failure(token)
+	}
+
+	_, err := b.Allow()
+	var openErr *OpenError
+	if !errors.As(err, &openErr) {
+		t.Fatalf("Expected OpenError, got %v", err)
+	}
+	if openErr.RetryAfter != time.Minute {
+		t.Errorf("Expected RetryAfter of the full cooldown, got %v", openErr.RetryAfter)
+	}
+	if b.State() != Open {
+		t.Errorf("Expected open state, got %v", b.State())
+	}
+}
+
+// allow reserves a call that the test expects to be allowed
+func allow(t *testing.T, b *Breaker) Token {
+	t.Helper()
+	token, err := b.Allow()
+	if err != nil {
+		t.Fatalf("Expected a call to be allowed, got %v", err)
+	}
+	return token
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	b, _ := newTestBreaker(Settings{FailureThreshold: 2, Cooldown: time.Minute})
+
+	b.Failure(allow(t, b))
+	b.Success(allow(t, b))
+	b.Failure(allow(t, b))
+
+	if b.State() != Closed {
+		t.Errorf("Expected non-consecutive failures to keep the breaker closed, got %v", b.State())
+	}
+}
+
+func TestBreakerHalfOpenProbes(t *testing.T) {
+	b, now := newTestBreaker(Settings{FailureThreshold: 1, Cooldown: time.Minute, HalfOpenProbes: 1})
+
+	b.Failure(allow(t, b))
+	*now = now.Add(time.Minute)
+
+	if b.State() != HalfOpen {
+		t.Fatalf("Expected half-open after cooldown, got %v", b.State())
+	}
+	probe := allow(t, b)
+	if _, err := b.Allow(); err == nil {
+		t.Fatal("Expected a second concurrent probe to be rejected")
+	}
+
+	// A failed probe reopens the breaker for another cooldown
+	b.Failure(probe)
+	if b.State() != Open {
+		t.Fatalf("Expected failed probe to reopen, got %v", b.State())
+	}
+
+	*now = now.Add(time.Minute)
+	b.Success(allow(t, b))
+	if b.State() != Closed {
+		t.Errorf("Expected successful probe to close, got %v", b.State())
+	}
+}
+
+func TestBreakerReleaseFreesProbe(t *testing.T) {
+	b, now := newTestBreaker(Settings{FailureThreshold: 1, Cooldown: time.Second})
+
+	b.Failure(allow(t, b))
+	*now = now.Add(time.Second)
+
+	b.Release(allow(t, b))
+	if _, err := b.Allow(); err != nil {
+		t.Errorf("Expected released probe slot to be reusable, got %v", err)
+	}
+}
+
+func TestBreakerIgnoresLateResults(t *testing.T) {
+	b, now := newTestBreaker(Settings{FailureThreshold: 1, Cooldown: time.Second})
+
+	// A slow call is admitted while closed and a quicker one opens the
+	// breaker before it finishes
+	slow := allow(t, b)
+	b.Failure(allow(t, b))
+	*now = now.Add(time.Second)
+	if b.State() != HalfOpen {
+		t.Fatalf("Expected half-open after cooldown, got %v", b.State())
+	}
+
+	// A probe is running when the slow call's result arrives; it must not
+	// decide for the probe
+	probe := allow(t, b)
+	b.Success(slow)
+	if b.State() != HalfOpen {
+		t.Fatalf("Expected a late success to leave the breaker half-open, got %v", b.State())
+	}
+	b.Failure(slow)
+	if b.State() != HalfOpen {
+		t.Fatalf("Expected a late failure to leave the breaker half-open, got %v", b.State())
+	}
+	b.Release(slow)
+	if _, err := b.Allow(); err == nil {
+		t.Fatal("Expected the probe to keep its slot")
+	}
+
+	// The probe's own result still counts
+	b.Success(probe)
+	if b.State() != Closed {
+		t.Errorf("Expected the probe to close the breaker, got %v", b.State())
+	}
+}
@@ -4,8 +4,12 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"fmt"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/LouisLau-art/go-translator/metrics"
 )
 
 // CacheItem represents a cached item with timestamp
@@ -17,20 +21,22 @@ type CacheItem struct {
 // TranslatorCache implements a thread-safe cache for translations
 type TranslatorCache struct {
 	store     sync.Map
-	ttl       time.Duration
+	ttl       atomic.Int64 // time.Duration, swappable at runtime
 	maxSize   int
 	size      int
 	mu        sync.RWMutex
 	cleanupCh chan struct{}
+	stopOnce  sync.Once
+	stopped   atomic.Bool
 }
 
 // NewTranslatorCache creates a new cache instance
 func NewTranslatorCache(ttl time.Duration, maxSize int) *TranslatorCache {
 	cache := &TranslatorCache{
-		ttl:       ttl,
 		maxSize:   maxSize,
 		cleanupCh: make(chan struct{}),
 	}
+	cache.ttl.Store(int64(ttl))
 
 	// Start background cleanup goroutine
 	go cache.startCleanup()
@@ -44,7 +50,8 @@ func (c *TranslatorCache) Get(key string) (string, bool) {
 		item := val.(CacheItem)
 
 		// Check if item has expired
-		if time.Since(item.Timestamp) < c.ttl {
+		if time.Since(item.Timestamp) < c.TTL() {
+			metrics.CacheHits.Inc()
 			return item.Value, true
 		}
 
@@ -53,8 +60,10 @@ func (c *TranslatorCache) Get(key string) (string, bool) {
 		c.mu.Lock()
 		c.size--
 		c.mu.Unlock()
+		metrics.CacheEvictions.WithLabelValues("expired").Inc()
 	}
 
+	metrics.CacheMisses.Inc()
 	return "", false
 }
 
@@ -97,6 +106,7 @@ func (c *TranslatorCache) Delete(key string) {
 func (c *TranslatorCache) Clear() {
 	c.store.Range(func(key, value interface{}) bool {
 		c.store.Delete(key)
+		metrics.CacheEvictions.WithLabelValues("cleared").Inc()
 		return true
 	})
 
@@ -112,9 +122,32 @@ func (c *TranslatorCache) Size() int {
 	return c.size
 }
 
-// GetCacheKey generates a cache key from text and language codes
-func GetCacheKey(text, source, target string) string {
+// TTL returns the current entry lifetime
+func (c *TranslatorCache) TTL() time.Duration {
+	return time.Duration(c.ttl.Load())
+}
+
+// SetTTL changes the entry lifetime. Existing entries are judged against
+// the new TTL from the next lookup on.
+func (c *TranslatorCache) SetTTL(ttl time.Duration) {
+	c.ttl.Store(int64(ttl))
+}
+
+// SetMaxSize changes the maximum number of entries. Shrinking below the
+// current size only blocks new inserts; nothing is evicted eagerly.
+func (c *TranslatorCache) SetMaxSize(maxSize int) {
+	c.mu.Lock()
+	c.maxSize = maxSize
+	c.mu.Unlock()
+}
+
+// GetCacheKey generates a cache key from text and language codes. Optional
+// variant parts (model, options) keep results of different models apart.
+func GetCacheKey(text, source, target string, variant ...string) string {
 	data := fmt.Sprintf("%s:%s:%s", source, target, text)
+	if len(variant) > 0 {
+		data = strings.Join(variant, ":") + "|" + data
+	}
 	hash := md5.Sum([]byte(data))
 	return hex.EncodeToString(hash[:])
 }
@@ -140,7 +173,7 @@ func (c *TranslatorCache) cleanupExpired() {
 
 	c.store.Range(func(key, value interface{}) bool {
 		item := value.(CacheItem)
-		if time.Since(item.Timestamp) > c.ttl {
+		if time.Since(item.Timestamp) > c.TTL() {
 			expiredKeys = append(expiredKeys, key.(string))
 		}
 		return true
@@ -149,9 +182,23 @@ func (c *TranslatorCache) cleanupExpired() {
 	for _, key := range expiredKeys {
 		c.Delete(key)
 	}
+	metrics.CacheEvictions.WithLabelValues("expired").Add(float64(len(expiredKeys)))
 }
 
-// StopCleanup stops the background cleanup goroutine
+// StopCleanup stops the background cleanup goroutine. It is safe to call
+// more than once.
 func (c *TranslatorCache) StopCleanup() {
-	close(c.cleanupCh)
+	c.stopOnce.Do(func() {
+		c.stopped.Store(true)
+		close(c.cleanupCh)
+	})
+}
+
+// Ping reports whether the cache is available. The in-memory store only
+// becomes unavailable once it has been stopped during shutdown.
+func (c *TranslatorCache) Ping() error {
+	if c.stopped.Load() {
+		return fmt.Errorf("cache has been stopped")
+	}
+	return nil
 }
\ No newline at end of file
@@ -183,4 +183,31 @@ func TestCacheConcurrentAccess(t *testing.T) {
 	if c.Size() != 10 {
 		t.Errorf("Expected cache size to be 10, got %d", c.Size())
 	}
-}
\ No newline at end of file
+}
+
+func TestCacheStopCleanupIdempotent(t *testing.T) {
+	c := NewTranslatorCache(10*time.Second, 10)
+
+	// Stopping twice must not panic on a closed channel
+	c.StopCleanup()
+	c.StopCleanup()
+}
+
+func TestCacheSetTTL(t *testing.T) {
+	c := NewTranslatorCache(10*time.Second, 10)
+
+	if err := c.Set("test-key", "test-value"); err != nil {
+		t.Fatalf("Failed to set cache: %v", err)
+	}
+
+	// Shortening the TTL applies to existing entries on the next lookup
+	c.SetTTL(time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	if _, ok := c.Get("test-key"); ok {
+		t.Error("Expected entry to expire under the new TTL")
+	}
+	if c.TTL() != time.Nanosecond {
+		t.Errorf("Expected TTL to be updated, got %v", c.TTL())
+	}
+}
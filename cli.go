@@ -0,0 +1,411 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+
+	"golang.org/x/time/rate"
+
+	"github.com/LouisLau-art/go-translator/api"
+	"github.com/LouisLau-art/go-translator/cache"
+	"github.com/LouisLau-art/go-translator/config"
+	"github.com/LouisLau-art/go-translator/formats/po"
+	"github.com/LouisLau-art/go-translator/handlers"
+	"github.com/LouisLau-art/go-translator/logging"
+)
+
+// newLocalTranslator builds the server's translation pipeline (provider
+// chain, cache and chunking) for commands that run without the server.
+// The returned func stops the cache's background cleanup.
+func newLocalTranslator(cfg *config.Config) (*handlers.TranslationHandler, func()) {
+	clients := newProviders(cfg)
+	translatorCache := cache.NewTranslatorCache(cfg.CacheTTL, cfg.CacheMaxSize)
+	h := handlers.NewTranslationHandler(clients.router(cfg), translatorCache, rate.NewLimiter(rate.Inf, 1), cfg.MaxTextLength)
+	h.Update(handlerSettings(cfg))
+	return h, translatorCache.StopCleanup
+}
+
+// loadCommandConfig loads the configuration for a command and sets up
+// logging on stderr
+func loadCommandConfig(fs *flag.FlagSet) (*config.Config, error) {
+	cfg, err := config.LoadWithOptions(config.Options{Flags: fs})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if err := logging.Setup(os.Stderr, logging.Options{
+		Level:      cfg.LogLevel,
+		Format:     cfg.LogFormat,
+		RedactText: cfg.LogRedactText,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to configure logging: %w", err)
+	}
+	return cfg, nil
+}
+
+// readInput reads the named file, or stdin for "-"
+func readInput(name string, stdin io.Reader) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(name)
+}
+
+// runPO implements "translator po": it translates the untranslated and
+// fuzzy entries of a PO or POT file
+func runPO(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("po", flag.ContinueOnError)
+	target := fs.String("t", "", "target language (required)")
+	source := fs.String("s", "auto", "source language, or auto to detect it")
+	model := fs.String("model", "", "model to use instead of the default")
+	output := fs.String("o", "", "output file (default stdout)")
+	config.RegisterFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: translator po -t LANG [-s LANG] [-o FILE] [flags] FILE.po|-")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *target == "" || fs.NArg() != 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	data, err := readInput(fs.Arg(0), stdin)
+	if err != nil {
+		return err
+	}
+	cfg, err := loadCommandConfig(fs)
+	if err != nil {
+		return err
+	}
+	h, stop := newLocalTranslator(cfg)
+	defer stop()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	translate := h.SegmentTranslator(api.TranslateRequest{Source: *source, Target: *target, Model: *model})
+	out, stats, err := po.Translate(ctx, data, *target, translate)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		_, err = stdout.Write(out)
+	} else {
+		err = os.WriteFile(*output, out, 0o644)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stderr, "translated %d entries, skipped %d\n", stats.Translated, stats.Skipped)
+	return nil
+}
+
+// fieldFlags collects repeated -opt name=value flags, the form fields of
+// document formats such as bilingual or output
+type fieldFlags map[string]string
+
+func (f fieldFlags) String() string {
+	return fmt.Sprint(map[string]string(f))
+}
+
+func (f fieldFlags) Set(value string) error {
+	name, v, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected name=value, got %q", value)
+	}
+	f[name] = v
+	return nil
+}
+
+// translateFlags registers the flags shared by translate and batch
+func translateFlags(fs *flag.FlagSet) (*api.TranslateRequest, fieldFlags) {
+	req := &api.TranslateRequest{}
+	fs.StringVar(&req.Target, "t", "", "target language (required)")
+	fs.StringVar(&req.Source, "s", "auto", "source language, or auto to detect it")
+	fs.StringVar(&req.Model, "model", "", "model to use instead of the default")
+	fields := fieldFlags{}
+	fs.Var(fields, "opt", "document option `name=value`, such as bilingual=true or output=text (repeatable)")
+	config.RegisterFlags(fs)
+	return req, fields
+}
+
+// runTranslate implements "translator translate": it translates files, or
+// stdin, to stdout, a file or a directory. Documents are recognised by
+// their extension; anything else is translated as text.
+func runTranslate(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("translate", flag.ContinueOnError)
+	req, fields := translateFlags(fs)
+	format := fs.String("format", "text", "text or html for stdin and text files, or a document extension such as srt for stdin")
+	output := fs.String("o", "", "output file (default stdout)")
+	dir := fs.String("d", "", "output directory; translations are named after the input and target language")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: translator translate -t LANG [-s LANG] [-o FILE | -d DIR] [flags] [FILE...|-]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	inputs := fs.Args()
+	if len(inputs) == 0 {
+		inputs = []string{"-"}
+	}
+	if req.Target == "" || (*output != "" && *dir != "") {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if len(inputs) > 1 && *dir == "" {
+		return fmt.Errorf("translating %d files needs an output directory (-d)", len(inputs))
+	}
+	stdinName := "stdin.txt"
+	switch *format {
+	case "text":
+	case "html":
+		req.Format = "html"
+	default:
+		stdinName = "stdin." + strings.TrimPrefix(*format, ".")
+		if !handlers.IsDocument(stdinName) {
+			return fmt.Errorf("unknown format %q", *format)
+		}
+	}
+
+	cfg, err := loadCommandConfig(fs)
+	if err != nil {
+		return err
+	}
+	h, stop := newLocalTranslator(cfg)
+	defer stop()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	for _, input := range inputs {
+		data, err := readInput(input, stdin)
+		if err != nil {
+			return err
+		}
+		name := input
+		if input == "-" {
+			name = stdinName
+		}
+		result, err := h.TranslateFile(ctx, *req, name, data, fields)
+		if err != nil {
+			return fmt.Errorf("%s: %w", input, err)
+		}
+
+		switch {
+		case *dir != "":
+			path := filepath.Join(*dir, result.Filename)
+			if err := writeOutput(path, result.Data); err != nil {
+				return err
+			}
+			fmt.Fprintf(stderr, "%s -> %s\n", input, path)
+		case *output != "":
+			if err := writeOutput(*output, result.Data); err != nil {
+				return err
+			}
+		default:
+			if _, err := stdout.Write(result.Data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// batchTextExtensions are the text files batch picks up besides documents
+var batchTextExtensions = []string{".md", ".markdown", ".txt"}
+
+// runBatch implements "translator batch": it translates every text file
+// and document under the given directories into an output directory,
+// keeping their relative paths. Hidden files and directories, such as
+// .git, are skipped.
+func runBatch(args []string, stderr io.Writer) error {
+	fs := flag.NewFlagSet("batch", flag.ContinueOnError)
+	req, fields := translateFlags(fs)
+	dir := fs.String("d", "", "output directory (required)")
+	jobs := fs.Int("j", 4, "files translated at once")
+	exts := fs.String("ext", "", "comma-separated extensions to translate (default text files and every document format)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: translator batch -t LANG -d DIR [-s LANG] [-j N] [-ext .md,.srt] [flags] DIR|FILE...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if req.Target == "" || *dir == "" || fs.NArg() == 0 || *jobs < 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	include := func(path string) bool {
+		ext := strings.ToLower(filepath.Ext(path))
+		if *exts == "" {
+			return slices.Contains(batchTextExtensions, ext) || handlers.IsDocument(path)
+		}
+		for _, e := range strings.Split(*exts, ",") {
+			if e = strings.TrimSpace(e); e != "" && "."+strings.TrimPrefix(strings.ToLower(e), ".") == ext {
+				return true
+			}
+		}
+		return false
+	}
+
+	files, err := batchFiles(fs.Args(), *dir, include)
+	if err != nil {
+		return err
+	}
+	cfg, err := loadCommandConfig(fs)
+	if err != nil {
+		return err
+	}
+	h, stop := newLocalTranslator(cfg)
+	defer stop()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	var (
+		mu     sync.Mutex
+		failed int
+		wg     sync.WaitGroup
+	)
+	sem := make(chan struct{}, *jobs)
+files:
+	for _, f := range files {
+		// An interrupt stops the batch even while every slot is busy
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			break files
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			path, err := translateBatchFile(ctx, h, *req, fields, f, *dir)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed++
+				fmt.Fprintf(stderr, "%s: %v\n", f.path, err)
+				return
+			}
+			fmt.Fprintf(stderr, "%s -> %s\n", f.path, path)
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	fmt.Fprintf(stderr, "translated %d of %d files\n", len(files)-failed, len(files))
+	if failed > 0 {
+		return fmt.Errorf("%d files failed", failed)
+	}
+	return nil
+}
+
+// batchFile is a file found by batch and its path relative to the
+// directory it was found in
+type batchFile struct {
+	path string
+	rel  string
+}
+
+// batchFiles lists the files to translate under roots, leaving out the
+// output directory so translations are not translated again. With several
+// roots, the files of each directory are placed under its name. Files
+// that would be written to the same place are rejected.
+func batchFiles(roots []string, outDir string, include func(string) bool) ([]batchFile, error) {
+	out, err := filepath.Abs(outDir)
+	if err != nil {
+		return nil, err
+	}
+	var files []batchFile
+	sources := map[string]string{}
+	add := func(path, rel string) error {
+		if other, ok := sources[rel]; ok {
+			return fmt.Errorf("%s and %s would both be written to %s", other, path, filepath.Join(outDir, rel))
+		}
+		sources[rel] = path
+		files = append(files, batchFile{path: path, rel: rel})
+		return nil
+	}
+	for _, root := range roots {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			if err := add(root, filepath.Base(root)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		prefix := ""
+		if len(roots) > 1 {
+			abs, err := filepath.Abs(root)
+			if err != nil {
+				return nil, err
+			}
+			prefix = filepath.Base(abs)
+		}
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			hidden := path != root && strings.HasPrefix(d.Name(), ".")
+			if d.IsDir() {
+				if abs, _ := filepath.Abs(path); hidden || abs == out {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if hidden || !d.Type().IsRegular() || !include(path) {
+				return nil
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			return add(path, filepath.Join(prefix, rel))
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// translateBatchFile translates one file into outDir, returning the path
+// of the translation
+func translateBatchFile(ctx context.Context, h *handlers.TranslationHandler, req api.TranslateRequest, fields map[string]string, f batchFile, outDir string) (string, error) {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return "", err
+	}
+	result, err := h.TranslateFile(ctx, req, f.path, data, fields)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(outDir, filepath.Dir(f.rel), result.Filename)
+	return path, writeOutput(path, result.Data)
+}
+
+// writeOutput writes a translation, creating its directory
+func writeOutput(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/LouisLau-art/go-translator/config"
+)
+
+const usage = `Usage:
+  translator [serve] [flags]                  start the web server
+  translator translate -t LANG [FILE...|-]    translate files or stdin to stdout
+  translator batch -t LANG -d OUT DIR...      translate every file under directories
+  translator config print [flags]             print the effective configuration
+  translator po -t LANG FILE.po               machine-translate a gettext catalog
+
+Run "translator -h" or "translator COMMAND -h" to list the flags.
+`
+
+// runCommand dispatches the command line to a subcommand. Without a
+// subcommand the web server is started.
+func runCommand(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "serve":
+			return runServe(args[1:])
+		case "translate":
+			return runTranslate(args[1:], os.Stdin, os.Stdout, os.Stderr)
+		case "batch":
+			return runBatch(args[1:], os.Stderr)
+		case "config":
+			return runConfig(args[1:], os.Stdout)
+		case "po":
+			return runPO(args[1:], os.Stdin, os.Stdout, os.Stderr)
+		case "help":
+			fmt.Fprint(os.Stdout, usage)
+			return nil
+		}
+	}
+	return runServe(args)
+}
+
+// runConfig implements "translator config print"
+func runConfig(args []string, stdout io.Writer) error {
+	if len(args) == 0 || args[0] != "print" {
+		return fmt.Errorf("usage: translator config print [--format yaml|toml] [flags]")
+	}
+
+	fs := flag.NewFlagSet("config print", flag.ContinueOnError)
+	format := fs.String("format", "yaml", "output format (yaml or toml)")
+	config.RegisterFlags(fs)
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadWithOptions(config.Options{Flags: fs, SkipValidation: true})
+	if err != nil {
+		return err
+	}
+	if err := config.Print(stdout, cfg, *format); err != nil {
+		return err
+	}
+
+	// Still report problems so the command can be used as a config check
+	return cfg.Validate()
+}
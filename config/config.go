@@ -1,9 +1,15 @@
 package config
 
 import (
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -20,36 +26,540 @@ type Config struct {
 	MaxTextLength  int
 	RateLimitRPM   int
 	RateLimitBurst int
+	LogLevel       string
+	LogFormat      string
+	LogRedactText  bool
+
+	UpstreamMaxRetries int
+	Model              string
+	AllowedModels      []string
+	ExtraOptions       map[string]interface{}
+
+	// Providers is the fallback chain, each entry "provider" or "provider:model"
+	Providers []string
+
+	OpenAIAPIKey  string
+	OpenAIBaseURL string
+	OpenAIModel   string
+	// OpenAIExtraOptions are added to every chat completions request; the
+	// Responses API options in ExtraOptions are not sent to OpenAI
+	OpenAIExtraOptions map[string]interface{}
+
+	// MaxDocumentSize is the largest accepted document upload, in bytes
+	MaxDocumentSize int
+
+	// PseudoExpansion is how much longer, in percent, pseudo-localized
+	// text is made
+	PseudoExpansion int
+
+	BreakerFailureThreshold int
+	BreakerCooldown         time.Duration
+	BreakerHalfOpenProbes   int
+
+	// JobsDir is the directory background jobs are kept in
+	JobsDir string
+	// JobWorkers is how many jobs run at once
+	JobWorkers int
+	// JobRetention is how long finished jobs and their results are kept
+	JobRetention time.Duration
+
+	// WebhookSecret signs job callbacks; callbacks are refused without it
+	WebhookSecret string
+	// WebhookMaxAttempts is how many times a callback is tried
+	WebhookMaxAttempts int
+	// WebhookBackoff is the wait before the first callback retry, doubled
+	// for each further retry
+	WebhookBackoff time.Duration
+	// WebhookTimeout bounds each callback attempt
+	WebhookTimeout time.Duration
+	// WebhookAllowedHosts are callback hosts that may resolve to loopback
+	// or private addresses, which are refused otherwise
+	WebhookAllowedHosts []string
+
+	ShutdownTimeout   time.Duration
+	ReadinessProbeTTL time.Duration
+
+	TracingExporter    string
+	TracingEndpoint    string
+	TracingSampleRatio float64
+}
+
+// Options controls the optional layers applied on top of the defaults.
+// Precedence, lowest to highest: defaults < file < environment < flags.
+type Options struct {
+	// File is an optional YAML (.yaml, .yml) or TOML (.toml) config file.
+	// When empty, the --config flag and then CONFIG_FILE are consulted.
+	File string
+
+	// Flags is a parsed flag set on which RegisterFlags was called
+	Flags *flag.FlagSet
+
+	// SkipValidation returns the merged configuration even if it is invalid
+	SkipValidation bool
+}
+
+// ConfigFile resolves the config file path: File, then the --config flag,
+// then CONFIG_FILE. It returns "" when no file is configured.
+func (o Options) ConfigFile() string {
+	if o.File != "" {
+		return o.File
+	}
+	if o.Flags != nil {
+		if f := o.Flags.Lookup("config"); f != nil && f.Value.String() != "" {
+			return f.Value.String()
+		}
+	}
+	return os.Getenv("CONFIG_FILE")
+}
+
+// Default returns the built-in configuration defaults
+func Default() *Config {
+	return &Config{
+		APIURL:         "https://ark.cn-beijing.volces.com/api/v3/responses",
+		Port:           "5000",
+		GinMode:        "release",
+		CacheTTL:       3600 * time.Second,
+		CacheMaxSize:   1000,
+		MaxTextLength:  5000,
+		RateLimitRPM:   30,
+		RateLimitBurst: 30,
+		LogLevel:       "info",
+		LogFormat:      "text",
+		LogRedactText:  true,
+
+		// Retries are opt-in; a failed call is not repeated by default
+		UpstreamMaxRetries: 0,
+		Model:              "doubao-seed-translation-250915",
+		Providers:          []string{"doubao"},
+
+		MaxDocumentSize: 10 << 20,
+		PseudoExpansion: 30,
+
+		BreakerFailureThreshold: 5,
+		BreakerCooldown:         30 * time.Second,
+		BreakerHalfOpenProbes:   1,
+
+		JobsDir:      "data/jobs",
+		JobWorkers:   2,
+		JobRetention: 24 * time.Hour,
+
+		WebhookMaxAttempts: 5,
+		WebhookBackoff:     2 * time.Second,
+		WebhookTimeout:     10 * time.Second,
+
+		ShutdownTimeout:   30 * time.Second,
+		ReadinessProbeTTL: 60 * time.Second,
+
+		TracingExporter:    "none",
+		TracingSampleRatio: 1.0,
+	}
 }
 
-// Load loads configuration from environment variables
+// Load loads configuration from the environment and, if CONFIG_FILE is
+// set, from that config file
 func Load() (*Config, error) {
-	// Try to load .env file, but don't fail if it doesn't exist
-	_ = godotenv.Load()
+	return LoadWithOptions(Options{})
+}
+
+// LoadWithOptions merges defaults, the config file, environment variables
+// and command-line flags, then validates the result
+func LoadWithOptions(opts Options) (*Config, error) {
+	loadDotEnv()
 
-	cfg := &Config{
-		APIKey:         getEnv("ARK_API_KEY", ""),
-		APIURL:         getEnv("ARK_API_URL", "https://ark.cn-beijing.volces.com/api/v3/responses"),
-		Port:           getEnv("PORT", "5000"),
-		GinMode:        getEnv("GIN_MODE", "release"),
-		CacheTTL:       getEnvAsDuration("CACHE_TTL", 3600*time.Second),
-		CacheMaxSize:   getEnvAsInt("CACHE_MAX_SIZE", 1000),
-		MaxTextLength:  getEnvAsInt("MAX_TEXT_LENGTH", 5000),
-		RateLimitRPM:   getEnvAsInt("RATE_LIMIT_RPM", 30),
-		RateLimitBurst: 30, // Default burst size
+	cfg := Default()
+	var errs []error
+
+	if file := opts.ConfigFile(); file != "" {
+		if err := applyFile(cfg, file); err != nil {
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				return nil, err
+			}
+			errs = append(errs, verr.Errors...)
+		}
 	}
 
+	errs = append(errs, applyEnv(cfg)...)
+
+	if opts.Flags != nil {
+		if err := applyFlags(cfg, opts.Flags); err != nil {
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				return nil, err
+			}
+			errs = append(errs, verr.Errors...)
+		}
+	}
+
+	// Malformed values are always fatal; range checks can be skipped
+	if len(errs) > 0 && opts.SkipValidation {
+		return nil, &ValidationError{Errors: errs}
+	}
+	if !opts.SkipValidation {
+		var verr *ValidationError
+		if err := cfg.Validate(); errors.As(err, &verr) {
+			errs = append(errs, verr.Errors...)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, &ValidationError{Errors: errs}
+	}
+
+	return cfg, nil
+}
+
+// dotEnv tracks the variables loadDotEnv set from .env, so a reload can
+// tell them from variables the process was started with
+var dotEnv struct {
+	mu  sync.Mutex
+	set map[string]string
+}
+
+// loadDotEnv reads .env, if there is one, into the environment. Variables
+// the process was started with take precedence; those set from an earlier
+// read follow the file, and are unset again when removed from it.
+func loadDotEnv() {
+	values, err := godotenv.Read()
+	if err != nil {
+		values = nil
+	}
+
+	dotEnv.mu.Lock()
+	defer dotEnv.mu.Unlock()
+	owned := func(key string) bool {
+		value, ok := dotEnv.set[key]
+		return ok && os.Getenv(key) == value
+	}
+	for key := range dotEnv.set {
+		if _, ok := values[key]; !ok && owned(key) {
+			os.Unsetenv(key)
+		}
+	}
+	set := make(map[string]string, len(values))
+	for key, value := range values {
+		if _, exists := os.LookupEnv(key); exists && !owned(key) {
+			continue
+		}
+		os.Setenv(key, value)
+		set[key] = value
+	}
+	dotEnv.set = set
+}
+
+// applyEnv overrides cfg with any environment variables that are set and
+// returns an error for every value that cannot be parsed
+func applyEnv(cfg *Config) []error {
+	var errs []error
+	collect := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	cfg.APIKey = getEnv("ARK_API_KEY", cfg.APIKey)
+	cfg.APIURL = getEnv("ARK_API_URL", cfg.APIURL)
+	cfg.Port = getEnv("PORT", cfg.Port)
+	cfg.GinMode = getEnv("GIN_MODE", cfg.GinMode)
+	cfg.LogLevel = getEnv("LOG_LEVEL", cfg.LogLevel)
+	cfg.LogFormat = getEnv("LOG_FORMAT", cfg.LogFormat)
+	cfg.TracingExporter = getEnv("TRACING_EXPORTER", cfg.TracingExporter)
+	cfg.TracingEndpoint = getEnv("TRACING_ENDPOINT", cfg.TracingEndpoint)
+	cfg.Model = getEnv("ARK_MODEL", cfg.Model)
+	cfg.AllowedModels = getEnvAsList("ARK_ALLOWED_MODELS", cfg.AllowedModels)
+	cfg.Providers = getEnvAsList("PROVIDERS", cfg.Providers)
+	cfg.OpenAIAPIKey = getEnv("OPENAI_API_KEY", cfg.OpenAIAPIKey)
+	cfg.OpenAIBaseURL = getEnv("OPENAI_BASE_URL", cfg.OpenAIBaseURL)
+	cfg.OpenAIModel = getEnv("OPENAI_MODEL", cfg.OpenAIModel)
+	cfg.JobsDir = getEnv("JOBS_DIR", cfg.JobsDir)
+	cfg.WebhookSecret = getEnv("WEBHOOK_SECRET", cfg.WebhookSecret)
+	cfg.WebhookAllowedHosts = getEnvAsList("WEBHOOK_ALLOWED_HOSTS", cfg.WebhookAllowedHosts)
+
+	var err error
+	cfg.CacheTTL, err = getEnvAsDuration("CACHE_TTL", cfg.CacheTTL)
+	collect(err)
+	cfg.CacheMaxSize, err = getEnvAsInt("CACHE_MAX_SIZE", cfg.CacheMaxSize)
+	collect(err)
+	cfg.MaxTextLength, err = getEnvAsInt("MAX_TEXT_LENGTH", cfg.MaxTextLength)
+	collect(err)
+	cfg.RateLimitRPM, err = getEnvAsInt("RATE_LIMIT_RPM", cfg.RateLimitRPM)
+	collect(err)
+	cfg.RateLimitBurst, err = getEnvAsInt("RATE_LIMIT_BURST", cfg.RateLimitBurst)
+	collect(err)
+	cfg.LogRedactText, err = getEnvAsBool("LOG_REDACT_TEXT", cfg.LogRedactText)
+	collect(err)
+	cfg.UpstreamMaxRetries, err = getEnvAsInt("UPSTREAM_MAX_RETRIES", cfg.UpstreamMaxRetries)
+	collect(err)
+	cfg.ShutdownTimeout, err = getEnvAsDuration("SHUTDOWN_TIMEOUT", cfg.ShutdownTimeout)
+	collect(err)
+	cfg.ReadinessProbeTTL, err = getEnvAsDuration("READINESS_PROBE_TTL", cfg.ReadinessProbeTTL)
+	collect(err)
+	cfg.TracingSampleRatio, err = getEnvAsFloat("TRACING_SAMPLE_RATIO", cfg.TracingSampleRatio)
+	collect(err)
+	cfg.ExtraOptions, err = getEnvAsJSONObject("ARK_EXTRA_OPTIONS", cfg.ExtraOptions)
+	collect(err)
+	cfg.OpenAIExtraOptions, err = getEnvAsJSONObject("OPENAI_EXTRA_OPTIONS", cfg.OpenAIExtraOptions)
+	collect(err)
+	cfg.MaxDocumentSize, err = getEnvAsInt("MAX_DOCUMENT_SIZE", cfg.MaxDocumentSize)
+	collect(err)
+	cfg.PseudoExpansion, err = getEnvAsInt("PSEUDO_EXPANSION", cfg.PseudoExpansion)
+	collect(err)
+	cfg.BreakerFailureThreshold, err = getEnvAsInt("BREAKER_FAILURE_THRESHOLD", cfg.BreakerFailureThreshold)
+	collect(err)
+	cfg.BreakerCooldown, err = getEnvAsDuration("BREAKER_COOLDOWN", cfg.BreakerCooldown)
+	collect(err)
+	cfg.BreakerHalfOpenProbes, err = getEnvAsInt("BREAKER_HALF_OPEN_PROBES", cfg.BreakerHalfOpenProbes)
+	collect(err)
+	cfg.JobWorkers, err = getEnvAsInt("JOB_WORKERS", cfg.JobWorkers)
+	collect(err)
+	cfg.JobRetention, err = getEnvAsDuration("JOB_RETENTION", cfg.JobRetention)
+	collect(err)
+	cfg.WebhookMaxAttempts, err = getEnvAsInt("WEBHOOK_MAX_ATTEMPTS", cfg.WebhookMaxAttempts)
+	collect(err)
+	cfg.WebhookBackoff, err = getEnvAsDuration("WEBHOOK_BACKOFF", cfg.WebhookBackoff)
+	collect(err)
+	cfg.WebhookTimeout, err = getEnvAsDuration("WEBHOOK_TIMEOUT", cfg.WebhookTimeout)
+	collect(err)
+
+	return errs
+}
+
+// ValidationError aggregates every problem found in a configuration
+type ValidationError struct {
+	Errors []error
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = "  - " + err.Error()
+	}
+	return "invalid configuration:\n" + strings.Join(msgs, "\n")
+}
+
+// Unwrap exposes the individual errors to errors.Is and errors.As
+func (e *ValidationError) Unwrap() []error {
+	return e.Errors
+}
+
+// Validate checks every field and reports all problems at once
+func (c *Config) Validate() error {
+	var errs []error
+
 	// Validate required configuration
-	if cfg.APIKey == "" {
-		return nil, fmt.Errorf("ARK_API_KEY is required")
+	if c.APIKey == "" && c.UsesProvider("doubao") {
+		errs = append(errs, fmt.Errorf("ARK_API_KEY is required"))
 	}
 
 	// Validate port
-	if _, err := strconv.Atoi(cfg.Port); err != nil {
-		return nil, fmt.Errorf("invalid PORT: %v", err)
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("PORT: invalid port %q", c.Port))
 	}
 
-	return cfg, nil
+	if !oneOf(c.GinMode, "debug", "release", "test") {
+		errs = append(errs, fmt.Errorf("GIN_MODE: unknown mode %q (expected debug, release or test)", c.GinMode))
+	}
+	if err := validateURL(c.APIURL); err != nil {
+		errs = append(errs, fmt.Errorf("ARK_API_URL: %v", err))
+	}
+
+	positive := func(name string, value int) {
+		if value <= 0 {
+			errs = append(errs, fmt.Errorf("%s: must be greater than 0, got %d", name, value))
+		}
+	}
+	positive("CACHE_MAX_SIZE", c.CacheMaxSize)
+	positive("MAX_TEXT_LENGTH", c.MaxTextLength)
+	positive("MAX_DOCUMENT_SIZE", c.MaxDocumentSize)
+	positive("RATE_LIMIT_RPM", c.RateLimitRPM)
+	positive("RATE_LIMIT_BURST", c.RateLimitBurst)
+	positive("BREAKER_FAILURE_THRESHOLD", c.BreakerFailureThreshold)
+	if c.PseudoExpansion < 0 || c.PseudoExpansion > 500 {
+		errs = append(errs, fmt.Errorf("PSEUDO_EXPANSION: must be between 0 and 500 percent, got %d", c.PseudoExpansion))
+	}
+	positive("BREAKER_HALF_OPEN_PROBES", c.BreakerHalfOpenProbes)
+	positive("JOB_WORKERS", c.JobWorkers)
+	positive("WEBHOOK_MAX_ATTEMPTS", c.WebhookMaxAttempts)
+	if strings.TrimSpace(c.JobsDir) == "" {
+		errs = append(errs, fmt.Errorf("JOBS_DIR: must not be empty"))
+	}
+	if strings.TrimSpace(c.Model) == "" {
+		errs = append(errs, fmt.Errorf("ARK_MODEL: must not be empty"))
+	}
+	for key := range c.ExtraOptions {
+		// Mirrors the fields the API client always sets itself
+		if oneOf(key, "model", "input", "messages", "stream") {
+			errs = append(errs, fmt.Errorf("ARK_EXTRA_OPTIONS: option %q cannot be overridden", key))
+		}
+	}
+	if len(c.Providers) == 0 {
+		errs = append(errs, fmt.Errorf("PROVIDERS: at least one provider is required"))
+	}
+	for _, p := range c.ProviderChain() {
+		if !oneOf(p.Name, knownProviders...) {
+			errs = append(errs, fmt.Errorf("PROVIDERS: unknown provider %q (expected %s)", p.Name, strings.Join(knownProviders, ", ")))
+		}
+		if p.Name == "openai" && p.Model == "" && c.OpenAIModel == "" {
+			errs = append(errs, fmt.Errorf("OPENAI_MODEL: required when PROVIDERS uses openai without a model"))
+		}
+	}
+	if c.UsesProvider("openai") {
+		if err := validateURL(c.OpenAIBaseURL); err != nil {
+			errs = append(errs, fmt.Errorf("OPENAI_BASE_URL: %v", err))
+		}
+	}
+	for key := range c.OpenAIExtraOptions {
+		// The chat completions fields the OpenAI client sets itself, and
+		// the Responses API fields chat completions rejects
+		if oneOf(key, "model", "messages", "stream", "input", "instructions", "max_output_tokens") {
+			errs = append(errs, fmt.Errorf("OPENAI_EXTRA_OPTIONS: option %q cannot be used with chat completions", key))
+		}
+	}
+	if c.UpstreamMaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("UPSTREAM_MAX_RETRIES: must not be negative, got %d", c.UpstreamMaxRetries))
+	}
+
+	positiveDuration := func(name string, value time.Duration) {
+		if value <= 0 {
+			errs = append(errs, fmt.Errorf("%s: must be greater than 0, got %v", name, value))
+		}
+	}
+	positiveDuration("CACHE_TTL", c.CacheTTL)
+	positiveDuration("SHUTDOWN_TIMEOUT", c.ShutdownTimeout)
+	positiveDuration("BREAKER_COOLDOWN", c.BreakerCooldown)
+	positiveDuration("JOB_RETENTION", c.JobRetention)
+	positiveDuration("WEBHOOK_BACKOFF", c.WebhookBackoff)
+	positiveDuration("WEBHOOK_TIMEOUT", c.WebhookTimeout)
+	if c.ReadinessProbeTTL < 0 {
+		errs = append(errs, fmt.Errorf("READINESS_PROBE_TTL: must not be negative, got %v", c.ReadinessProbeTTL))
+	}
+
+	if !oneOf(strings.ToLower(c.LogLevel), "debug", "info", "warn", "warning", "error") {
+		errs = append(errs, fmt.Errorf("LOG_LEVEL: unknown level %q (expected debug, info, warn or error)", c.LogLevel))
+	}
+	if !oneOf(strings.ToLower(c.LogFormat), "text", "json") {
+		errs = append(errs, fmt.Errorf("LOG_FORMAT: unknown format %q (expected text or json)", c.LogFormat))
+	}
+	if !oneOf(strings.ToLower(c.TracingExporter), "none", "stdout", "otlp") {
+		errs = append(errs, fmt.Errorf("TRACING_EXPORTER: unknown exporter %q (expected none, stdout or otlp)", c.TracingExporter))
+	}
+	if c.TracingEndpoint != "" {
+		if err := validateURL(c.TracingEndpoint); err != nil {
+			errs = append(errs, fmt.Errorf("TRACING_ENDPOINT: %v", err))
+		}
+	}
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("TRACING_SAMPLE_RATIO: must be between 0 and 1, got %v", c.TracingSampleRatio))
+	}
+
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// knownProviders are the provider names PROVIDERS entries may use
+var knownProviders = []string{"doubao", "openai", "offline"}
+
+// ProviderSpec is one parsed entry of the provider fallback chain
+type ProviderSpec struct {
+	Name  string
+	Model string
+}
+
+// String returns the entry as written in PROVIDERS
+func (p ProviderSpec) String() string {
+	if p.Model == "" {
+		return p.Name
+	}
+	return p.Name + ":" + p.Model
+}
+
+// ProviderChain parses Providers into provider names and pinned models
+func (c *Config) ProviderChain() []ProviderSpec {
+	specs := make([]ProviderSpec, 0, len(c.Providers))
+	for _, entry := range c.Providers {
+		name, model, _ := strings.Cut(strings.TrimSpace(entry), ":")
+		specs = append(specs, ProviderSpec{Name: strings.ToLower(name), Model: model})
+	}
+	return specs
+}
+
+// UsesProvider reports whether name appears in the provider chain
+func (c *Config) UsesProvider(name string) bool {
+	for _, p := range c.ProviderChain() {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// MissingAPIKeys returns the API key settings left empty for providers in
+// the chain. OpenAI-compatible servers may run without a key, so Validate
+// accepts that; readiness still reports it.
+func (c *Config) MissingAPIKeys() []string {
+	var missing []string
+	if c.UsesProvider("doubao") && c.APIKey == "" {
+		missing = append(missing, "ARK_API_KEY")
+	}
+	if c.UsesProvider("openai") && c.OpenAIAPIKey == "" {
+		missing = append(missing, "OPENAI_API_KEY")
+	}
+	return missing
+}
+
+// DefaultModel returns the model the primary provider uses when a request
+// does not pick one
+func (c *Config) DefaultModel() string {
+	chain := c.ProviderChain()
+	if len(chain) == 0 {
+		return c.Model
+	}
+	switch primary := chain[0]; {
+	case primary.Model != "":
+		return primary.Model
+	case primary.Name == "openai":
+		return c.OpenAIModel
+	case primary.Name == "offline":
+		return "offline-phrasebook"
+	default:
+		return c.Model
+	}
+}
+
+// Models returns the default model followed by the other allowed models
+func (c *Config) Models() []string {
+	models := []string{c.DefaultModel()}
+	for _, m := range c.AllowedModels {
+		if m != "" && !oneOf(m, models...) {
+			models = append(models, m)
+		}
+	}
+	return models
+}
+
+// validateURL requires an absolute http or https URL with a host
+func validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %v", raw, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid URL %q: scheme must be http or https", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid URL %q: missing host", raw)
+	}
+	return nil
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
 }
 
 // getEnv gets an environment variable with a default value
@@ -60,37 +570,108 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
-// getEnvAsInt gets an environment variable as integer
-func getEnvAsInt(key string, defaultValue int) int {
+// getEnvAsInt gets an environment variable as integer. A malformed value
+// is reported together with the variable name.
+func getEnvAsInt(key string, defaultValue int) (int, error) {
 	valueStr := getEnv(key, "")
 	if valueStr == "" {
-		return defaultValue
+		return defaultValue, nil
+	}
+
+	value, err := strconv.Atoi(strings.TrimSpace(valueStr))
+	if err != nil {
+		return defaultValue, fmt.Errorf("%s: invalid integer %q", key, valueStr)
 	}
 
-	value, err := strconv.Atoi(valueStr)
+	return value, nil
+}
+
+// getEnvAsFloat gets an environment variable as float
+func getEnvAsFloat(key string, defaultValue float64) (float64, error) {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue, nil
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(valueStr), 64)
 	if err != nil {
+		return defaultValue, fmt.Errorf("%s: invalid number %q", key, valueStr)
+	}
+
+	return value, nil
+}
+
+// getEnvAsBool gets an environment variable as boolean
+func getEnvAsBool(key string, defaultValue bool) (bool, error) {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue, nil
+	}
+
+	value, err := strconv.ParseBool(strings.TrimSpace(valueStr))
+	if err != nil {
+		return defaultValue, fmt.Errorf("%s: invalid boolean %q (use true or false)", key, valueStr)
+	}
+
+	return value, nil
+}
+
+// getEnvAsList gets a comma-separated environment variable as a list
+func getEnvAsList(key string, defaultValue []string) []string {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
 		return defaultValue
 	}
 
-	return value
+	var values []string
+	for _, part := range strings.Split(valueStr, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
+// getEnvAsJSONObject gets an environment variable holding a JSON object
+func getEnvAsJSONObject(key string, defaultValue map[string]interface{}) (map[string]interface{}, error) {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue, nil
+	}
+
+	var value map[string]interface{}
+	if err := json.Unmarshal([]byte(valueStr), &value); err != nil {
+		return defaultValue, fmt.Errorf("%s: invalid JSON object: %v", key, err)
+	}
+	return value, nil
 }
 
 // getEnvAsDuration gets an environment variable as duration
-func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+func getEnvAsDuration(key string, defaultValue time.Duration) (time.Duration, error) {
 	valueStr := getEnv(key, "")
 	if valueStr == "" {
-		return defaultValue
+		return defaultValue, nil
 	}
 
+	duration, err := parseDuration(strings.TrimSpace(valueStr))
+	if err != nil {
+		return defaultValue, fmt.Errorf("%s: %v", key, err)
+	}
+
+	return duration, nil
+}
+
+// parseDuration accepts plain seconds ("3600") or a Go duration ("1h")
+func parseDuration(value string) (time.Duration, error) {
 	// Try to parse as seconds first
-	if seconds, err := strconv.Atoi(valueStr); err == nil {
-		return time.Duration(seconds) * time.Second
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, nil
 	}
 
 	// Try to parse as duration string
-	if duration, err := time.ParseDuration(valueStr); err == nil {
-		return duration
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q (use seconds or a value like 90s, 1h)", value)
 	}
-
-	return defaultValue
-}
\ No newline at end of file
+	return duration, nil
+}
@@ -1,7 +1,13 @@
 package config
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -204,4 +210,392 @@ func TestGetEnvWithDefault(t *testing.T) {
 	if val != "actual-value" {
 		t.Errorf("Expected 'actual-value', got '%s'", val)
 	}
-}
\ No newline at end of file
+}
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfigLayerPrecedence(t *testing.T) {
+	t.Setenv("ARK_API_KEY", "")
+	t.Setenv("PORT", "")
+	t.Setenv("LOG_LEVEL", "")
+
+	path := writeConfigFile(t, "config.yaml", `
+server:
+  port: 6000
+upstream:
+  api_key: file-key
+cache:
+  ttl: 2h
+  max_size: 42
+log:
+  level: debug
+`)
+
+	// Environment overrides the file
+	t.Setenv("CACHE_MAX_SIZE", "77")
+
+	// Flags override the environment
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	RegisterFlags(fs)
+	if err := fs.Parse([]string{"--config", path, "--port", "7000"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	t.Setenv("PORT", "6500")
+
+	cfg, err := LoadWithOptions(Options{Flags: fs})
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if cfg.APIKey != "file-key" {
+		t.Errorf("Expected APIKey from file, got '%s'", cfg.APIKey)
+	}
+	if cfg.CacheTTL != 2*time.Hour {
+		t.Errorf("Expected CacheTTL from file to be 2h, got %v", cfg.CacheTTL)
+	}
+	if cfg.LogLevel != "debug" {
+		t.Errorf("Expected LogLevel from file, got '%s'", cfg.LogLevel)
+	}
+	if cfg.CacheMaxSize != 77 {
+		t.Errorf("Expected CacheMaxSize from env to be 77, got %d", cfg.CacheMaxSize)
+	}
+	if cfg.Port != "7000" {
+		t.Errorf("Expected Port from flag to be 7000, got '%s'", cfg.Port)
+	}
+	if cfg.MaxTextLength != 5000 {
+		t.Errorf("Expected default MaxTextLength, got %d", cfg.MaxTextLength)
+	}
+}
+
+func TestLoadConfigTOMLFile(t *testing.T) {
+	t.Setenv("ARK_API_KEY", "")
+	path := writeConfigFile(t, "config.toml", `
+[upstream]
+api_key = "toml-key"
+
+[limits]
+max_text_length = 9000
+`)
+
+	cfg, err := LoadWithOptions(Options{File: path})
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.APIKey != "toml-key" || cfg.MaxTextLength != 9000 {
+		t.Errorf("Unexpected values from TOML file: key=%s max=%d", cfg.APIKey, cfg.MaxTextLength)
+	}
+}
+
+func TestLoadConfigRejectsUnknownFileKeys(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "server:\n  prot: 6000\n")
+	if _, err := LoadWithOptions(Options{File: path}); err == nil {
+		t.Fatal("Expected error for unknown key in config file")
+	}
+}
+
+func TestValidateAggregatesErrors(t *testing.T) {
+	cfg := Default()
+	cfg.Port = "not-a-port"
+	cfg.LogFormat = "xml"
+
+	err := cfg.Validate()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected ValidationError, got %v", err)
+	}
+
+	// Missing API key, bad port and bad log format
+	if len(verr.Errors) != 3 {
+		t.Errorf("Expected 3 errors, got %d: %v", len(verr.Errors), err)
+	}
+}
+
+func TestPrintMasksSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.APIKey = "super-secret-key-1234"
+
+	var buf bytes.Buffer
+	if err := Print(&buf, cfg, "yaml"); err != nil {
+		t.Fatalf("Failed to print config: %v", err)
+	}
+
+	out := buf.String()
+	if strings.Contains(out, "super-secret") {
+		t.Error("Expected API key to be masked")
+	}
+	if !strings.Contains(out, "********1234") {
+		t.Errorf("Expected masked API key suffix, got:\n%s", out)
+	}
+}
+
+func TestLoadConfigReportsMalformedEnv(t *testing.T) {
+	t.Setenv("ARK_API_KEY", "test-key-123")
+	t.Setenv("CACHE_TTL", "1hr")
+	t.Setenv("MAX_TEXT_LENGTH", "abc")
+	t.Setenv("LOG_REDACT_TEXT", "maybe")
+
+	cfg, err := Load()
+	if err == nil {
+		t.Fatalf("Expected malformed values to be rejected, got config %+v", cfg)
+	}
+
+	// Every malformed variable is named, not just the first one
+	for _, name := range []string{"CACHE_TTL", "MAX_TEXT_LENGTH", "LOG_REDACT_TEXT"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("Expected error to mention %s, got: %v", name, err)
+		}
+	}
+}
+
+func TestLoadConfigReportsOutOfRangeValues(t *testing.T) {
+	t.Setenv("ARK_API_KEY", "test-key-123")
+	t.Setenv("CACHE_MAX_SIZE", "-1")
+	t.Setenv("CACHE_TTL", "0")
+	t.Setenv("RATE_LIMIT_RPM", "0")
+	t.Setenv("ARK_API_URL", "ark.example.com/api")
+	t.Setenv("GIN_MODE", "production")
+
+	_, err := Load()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected ValidationError, got %v", err)
+	}
+
+	for _, name := range []string{"CACHE_MAX_SIZE", "CACHE_TTL", "RATE_LIMIT_RPM", "ARK_API_URL", "GIN_MODE"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("Expected error to mention %s, got: %v", name, err)
+		}
+	}
+	if len(verr.Errors) != 5 {
+		t.Errorf("Expected 5 errors, got %d: %v", len(verr.Errors), err)
+	}
+}
+
+func TestLoadConfigAcceptsDurationStrings(t *testing.T) {
+	t.Setenv("ARK_API_KEY", "test-key-123")
+	t.Setenv("CACHE_TTL", "90m")
+	t.Setenv("SHUTDOWN_TIMEOUT", "45")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.CacheTTL != 90*time.Minute {
+		t.Errorf("Expected CacheTTL 90m, got %v", cfg.CacheTTL)
+	}
+	if cfg.ShutdownTimeout != 45*time.Second {
+		t.Errorf("Expected ShutdownTimeout 45s, got %v", cfg.ShutdownTimeout)
+	}
+}
+
+func TestGetEnvAsIntReportsName(t *testing.T) {
+	t.Setenv("TEST_INT", "12x")
+
+	value, err := getEnvAsInt("TEST_INT", 7)
+	if err == nil || !strings.Contains(err.Error(), "TEST_INT") {
+		t.Errorf("Expected error naming TEST_INT, got %v", err)
+	}
+	if value != 7 {
+		t.Errorf("Expected default on error, got %d", value)
+	}
+}
+
+func TestWatchFileDetectsChanges(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "log:\n  level: info\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changed := make(chan struct{}, 1)
+	go WatchFile(ctx, path, 10*time.Millisecond, func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+
+	time.Sleep(30 * time.Millisecond)
+	if err := os.WriteFile(path, []byte("log:\n  level: debug\n"), 0o600); err != nil {
+		t.Fatalf("Failed to rewrite config: %v", err)
+	}
+
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected change to be detected")
+	}
+}
+
+func TestLoadConfigModels(t *testing.T) {
+	t.Setenv("ARK_API_KEY", "test-key-123")
+	t.Setenv("ARK_MODEL", "model-a")
+	t.Setenv("ARK_ALLOWED_MODELS", "model-b, model-a,model-c")
+	t.Setenv("ARK_EXTRA_OPTIONS", `{"temperature":0.2}`)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if got := strings.Join(cfg.Models(), ","); got != "model-a,model-b,model-c" {
+		t.Errorf("Expected default model first without duplicates, got %s", got)
+	}
+	if cfg.ExtraOptions["temperature"] != 0.2 {
+		t.Errorf("Expected extra options from ARK_EXTRA_OPTIONS, got %v", cfg.ExtraOptions)
+	}
+
+	t.Setenv("ARK_EXTRA_OPTIONS", `{"model":"other"}`)
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "ARK_EXTRA_OPTIONS") {
+		t.Errorf("Expected reserved option to be rejected, got %v", err)
+	}
+}
+
+func TestLoadConfigBreakerSettings(t *testing.T) {
+	t.Setenv("ARK_API_KEY", "test-key-123")
+	t.Setenv("BREAKER_FAILURE_THRESHOLD", "3")
+	t.Setenv("BREAKER_COOLDOWN", "1m")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.BreakerFailureThreshold != 3 || cfg.BreakerCooldown != time.Minute || cfg.BreakerHalfOpenProbes != 1 {
+		t.Errorf("Unexpected breaker settings: %d, %v, %d", cfg.BreakerFailureThreshold, cfg.BreakerCooldown, cfg.BreakerHalfOpenProbes)
+	}
+
+	t.Setenv("BREAKER_FAILURE_THRESHOLD", "0")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "BREAKER_FAILURE_THRESHOLD") {
+		t.Errorf("Expected zero threshold to be rejected, got %v", err)
+	}
+}
+
+func TestLoadConfigJobSettings(t *testing.T) {
+	t.Setenv("ARK_API_KEY", "test-key-123")
+	t.Setenv("JOBS_DIR", "/var/lib/translator/jobs")
+	t.Setenv("JOB_RETENTION", "2h")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.JobsDir != "/var/lib/translator/jobs" || cfg.JobWorkers != 2 || cfg.JobRetention != 2*time.Hour {
+		t.Errorf("Unexpected job settings: %s, %d, %v", cfg.JobsDir, cfg.JobWorkers, cfg.JobRetention)
+	}
+
+	t.Setenv("JOB_WORKERS", "0")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "JOB_WORKERS") {
+		t.Errorf("Expected zero workers to be rejected, got %v", err)
+	}
+}
+
+func TestLoadConfigWebhookSettings(t *testing.T) {
+	t.Setenv("ARK_API_KEY", "test-key-123")
+	t.Setenv("WEBHOOK_SECRET", "hook-secret-456")
+	t.Setenv("WEBHOOK_BACKOFF", "500ms")
+	t.Setenv("WEBHOOK_ALLOWED_HOSTS", "cms.internal, 10.0.0.8")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.WebhookSecret != "hook-secret-456" || cfg.WebhookMaxAttempts != 5 || cfg.WebhookBackoff != 500*time.Millisecond || cfg.WebhookTimeout != 10*time.Second {
+		t.Errorf("Unexpected webhook settings: %d, %v, %v", cfg.WebhookMaxAttempts, cfg.WebhookBackoff, cfg.WebhookTimeout)
+	}
+	if strings.Join(cfg.WebhookAllowedHosts, ",") != "cms.internal,10.0.0.8" {
+		t.Errorf("Unexpected webhook allowed hosts: %v", cfg.WebhookAllowedHosts)
+	}
+
+	t.Setenv("WEBHOOK_MAX_ATTEMPTS", "0")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "WEBHOOK_MAX_ATTEMPTS") {
+		t.Errorf("Expected zero attempts to be rejected, got %v", err)
+	}
+}
+
+func TestLoadConfigOpenAIProvider(t *testing.T) {
+	t.Setenv("ARK_API_KEY", "")
+	t.Setenv("PROVIDERS", "openai")
+	t.Setenv("OPENAI_BASE_URL", "http://localhost:8000/v1")
+
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "OPENAI_MODEL") {
+		t.Fatalf("Expected missing OPENAI_MODEL to be reported, got %v", err)
+	}
+
+	t.Setenv("OPENAI_MODEL", "qwen2.5")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Expected openai-only chain to load without ARK_API_KEY, got %v", err)
+	}
+	if cfg.DefaultModel() != "qwen2.5" {
+		t.Errorf("Expected the primary provider's model as default, got %s", cfg.DefaultModel())
+	}
+
+	// OpenAI takes its own extra options, not the Responses API ones
+	t.Setenv("ARK_EXTRA_OPTIONS", `{"max_output_tokens":100}`)
+	t.Setenv("OPENAI_EXTRA_OPTIONS", `{"temperature":0.2}`)
+	cfg, err = Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.OpenAIExtraOptions["temperature"] != 0.2 || cfg.OpenAIExtraOptions["max_output_tokens"] != nil {
+		t.Errorf("Expected extra options from OPENAI_EXTRA_OPTIONS, got %v", cfg.OpenAIExtraOptions)
+	}
+	t.Setenv("OPENAI_EXTRA_OPTIONS", `{"max_output_tokens":100}`)
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "OPENAI_EXTRA_OPTIONS") {
+		t.Errorf("Expected a Responses API option to be rejected, got %v", err)
+	}
+}
+
+func TestLoadConfigRereadsDotEnv(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("PROVIDERS", "offline")
+	t.Setenv("RATE_LIMIT_RPM", "7")
+	// Unset so .env can supply it; t.Setenv restores it afterwards
+	t.Setenv("MAX_TEXT_LENGTH", "")
+	os.Unsetenv("MAX_TEXT_LENGTH")
+	t.Cleanup(func() {
+		// Drop what the test's .env set
+		os.Remove(".env")
+		loadDotEnv()
+	})
+
+	write := func(content string) {
+		if err := os.WriteFile(".env", []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	load := func() *Config {
+		cfg, err := Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cfg
+	}
+
+	write("MAX_TEXT_LENGTH=100\nRATE_LIMIT_RPM=9\n")
+	cfg := load()
+	if cfg.MaxTextLength != 100 {
+		t.Errorf("Expected MAX_TEXT_LENGTH from .env, got %d", cfg.MaxTextLength)
+	}
+	if cfg.RateLimitRPM != 7 {
+		t.Errorf("Expected the process environment to win over .env, got %d", cfg.RateLimitRPM)
+	}
+
+	// Edits to .env show up on the next load
+	write("MAX_TEXT_LENGTH=200\nRATE_LIMIT_RPM=9\n")
+	if cfg := load(); cfg.MaxTextLength != 200 {
+		t.Errorf("Expected the edited .env value, got %d", cfg.MaxTextLength)
+	}
+
+	// So do removals
+	write("RATE_LIMIT_RPM=9\n")
+	if cfg := load(); cfg.MaxTextLength != Default().MaxTextLength {
+		t.Errorf("Expected the default once removed from .env, got %d", cfg.MaxTextLength)
+	}
+}
@@ -0,0 +1,322 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// fileConfig is the on-disk layout of a YAML or TOML config file. Every
+// field is optional; only keys present in the file override defaults.
+// Durations are strings such as "90s" or "1h", or plain seconds.
+type fileConfig struct {
+	Server struct {
+		Port              *int    `yaml:"port,omitempty" toml:"port,omitempty"`
+		GinMode           *string `yaml:"gin_mode,omitempty" toml:"gin_mode,omitempty"`
+		ShutdownTimeout   *string `yaml:"shutdown_timeout,omitempty" toml:"shutdown_timeout,omitempty"`
+		ReadinessProbeTTL *string `yaml:"readiness_probe_ttl,omitempty" toml:"readiness_probe_ttl,omitempty"`
+	} `yaml:"server" toml:"server"`
+
+	Upstream struct {
+		APIKey     *string `yaml:"api_key,omitempty" toml:"api_key,omitempty"`
+		APIURL     *string `yaml:"api_url,omitempty" toml:"api_url,omitempty"`
+		MaxRetries *int    `yaml:"max_retries,omitempty" toml:"max_retries,omitempty"`
+
+		Model         *string                `yaml:"model,omitempty" toml:"model,omitempty"`
+		AllowedModels []string               `yaml:"allowed_models,omitempty" toml:"allowed_models,omitempty"`
+		Options       map[string]interface{} `yaml:"options,omitempty" toml:"options,omitempty"`
+		Providers     []string               `yaml:"providers,omitempty" toml:"providers,omitempty"`
+	} `yaml:"upstream" toml:"upstream"`
+
+	Cache struct {
+		TTL     *string `yaml:"ttl,omitempty" toml:"ttl,omitempty"`
+		MaxSize *int    `yaml:"max_size,omitempty" toml:"max_size,omitempty"`
+	} `yaml:"cache" toml:"cache"`
+
+	Limits struct {
+		MaxTextLength  *int `yaml:"max_text_length,omitempty" toml:"max_text_length,omitempty"`
+		RateLimitRPM   *int `yaml:"rate_limit_rpm,omitempty" toml:"rate_limit_rpm,omitempty"`
+		RateLimitBurst *int `yaml:"rate_limit_burst,omitempty" toml:"rate_limit_burst,omitempty"`
+
+		MaxDocumentSize *int `yaml:"max_document_size,omitempty" toml:"max_document_size,omitempty"`
+	} `yaml:"limits" toml:"limits"`
+
+	Log struct {
+		Level      *string `yaml:"level,omitempty" toml:"level,omitempty"`
+		Format     *string `yaml:"format,omitempty" toml:"format,omitempty"`
+		RedactText *bool   `yaml:"redact_text,omitempty" toml:"redact_text,omitempty"`
+	} `yaml:"log" toml:"log"`
+
+	OpenAI struct {
+		APIKey  *string                `yaml:"api_key,omitempty" toml:"api_key,omitempty"`
+		BaseURL *string                `yaml:"base_url,omitempty" toml:"base_url,omitempty"`
+		Model   *string                `yaml:"model,omitempty" toml:"model,omitempty"`
+		Options map[string]interface{} `yaml:"options,omitempty" toml:"options,omitempty"`
+	} `yaml:"openai" toml:"openai"`
+
+	Pseudo struct {
+		Expansion *int `yaml:"expansion,omitempty" toml:"expansion,omitempty"`
+	} `yaml:"pseudo" toml:"pseudo"`
+
+	Breaker struct {
+		FailureThreshold *int    `yaml:"failure_threshold,omitempty" toml:"failure_threshold,omitempty"`
+		Cooldown         *string `yaml:"cooldown,omitempty" toml:"cooldown,omitempty"`
+		HalfOpenProbes   *int    `yaml:"half_open_probes,omitempty" toml:"half_open_probes,omitempty"`
+	} `yaml:"breaker" toml:"breaker"`
+
+	Jobs struct {
+		Dir       *string `yaml:"dir,omitempty" toml:"dir,omitempty"`
+		Workers   *int    `yaml:"workers,omitempty" toml:"workers,omitempty"`
+		Retention *string `yaml:"retention,omitempty" toml:"retention,omitempty"`
+	} `yaml:"jobs" toml:"jobs"`
+
+	Webhook struct {
+		Secret       *string  `yaml:"secret,omitempty" toml:"secret,omitempty"`
+		MaxAttempts  *int     `yaml:"max_attempts,omitempty" toml:"max_attempts,omitempty"`
+		Backoff      *string  `yaml:"backoff,omitempty" toml:"backoff,omitempty"`
+		Timeout      *string  `yaml:"timeout,omitempty" toml:"timeout,omitempty"`
+		AllowedHosts []string `yaml:"allowed_hosts,omitempty" toml:"allowed_hosts,omitempty"`
+	} `yaml:"webhook" toml:"webhook"`
+
+	Tracing struct {
+		Exporter    *string  `yaml:"exporter,omitempty" toml:"exporter,omitempty"`
+		Endpoint    *string  `yaml:"endpoint,omitempty" toml:"endpoint,omitempty"`
+		SampleRatio *float64 `yaml:"sample_ratio,omitempty" toml:"sample_ratio,omitempty"`
+	} `yaml:"tracing" toml:"tracing"`
+}
+
+// applyFile reads path and overrides cfg with every key it sets
+func applyFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	var fc fileConfig
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalWithOptions(data, &fc, yaml.Strict())
+	case ".toml":
+		dec := toml.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&fc)
+	default:
+		return fmt.Errorf("config file %s: unsupported extension (expected .yaml, .yml or .toml)", path)
+	}
+	if err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	return fc.apply(cfg, path)
+}
+
+// apply copies every key set in fc onto cfg, collecting malformed values
+func (fc *fileConfig) apply(cfg *Config, path string) error {
+	var errs []error
+	duration := func(key string, value *string, dst *time.Duration) {
+		if value == nil {
+			return
+		}
+		d, err := parseDuration(*value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %v", path, key, err))
+			return
+		}
+		*dst = d
+	}
+
+	if fc.Server.Port != nil {
+		cfg.Port = strconv.Itoa(*fc.Server.Port)
+	}
+	setString(&cfg.GinMode, fc.Server.GinMode)
+	duration("server.shutdown_timeout", fc.Server.ShutdownTimeout, &cfg.ShutdownTimeout)
+	duration("server.readiness_probe_ttl", fc.Server.ReadinessProbeTTL, &cfg.ReadinessProbeTTL)
+
+	setString(&cfg.APIKey, fc.Upstream.APIKey)
+	setString(&cfg.APIURL, fc.Upstream.APIURL)
+	setInt(&cfg.UpstreamMaxRetries, fc.Upstream.MaxRetries)
+	setString(&cfg.Model, fc.Upstream.Model)
+	if fc.Upstream.AllowedModels != nil {
+		cfg.AllowedModels = fc.Upstream.AllowedModels
+	}
+	if fc.Upstream.Options != nil {
+		cfg.ExtraOptions = fc.Upstream.Options
+	}
+	if fc.Upstream.Providers != nil {
+		cfg.Providers = fc.Upstream.Providers
+	}
+
+	duration("cache.ttl", fc.Cache.TTL, &cfg.CacheTTL)
+	setInt(&cfg.CacheMaxSize, fc.Cache.MaxSize)
+
+	setInt(&cfg.MaxTextLength, fc.Limits.MaxTextLength)
+	setInt(&cfg.RateLimitRPM, fc.Limits.RateLimitRPM)
+	setInt(&cfg.RateLimitBurst, fc.Limits.RateLimitBurst)
+	setInt(&cfg.MaxDocumentSize, fc.Limits.MaxDocumentSize)
+
+	setString(&cfg.LogLevel, fc.Log.Level)
+	setString(&cfg.LogFormat, fc.Log.Format)
+	if fc.Log.RedactText != nil {
+		cfg.LogRedactText = *fc.Log.RedactText
+	}
+
+	setString(&cfg.OpenAIAPIKey, fc.OpenAI.APIKey)
+	setString(&cfg.OpenAIBaseURL, fc.OpenAI.BaseURL)
+	setString(&cfg.OpenAIModel, fc.OpenAI.Model)
+	if fc.OpenAI.Options != nil {
+		cfg.OpenAIExtraOptions = fc.OpenAI.Options
+	}
+
+	setInt(&cfg.PseudoExpansion, fc.Pseudo.Expansion)
+
+	setInt(&cfg.BreakerFailureThreshold, fc.Breaker.FailureThreshold)
+	duration("breaker.cooldown", fc.Breaker.Cooldown, &cfg.BreakerCooldown)
+	setInt(&cfg.BreakerHalfOpenProbes, fc.Breaker.HalfOpenProbes)
+
+	setString(&cfg.JobsDir, fc.Jobs.Dir)
+	setInt(&cfg.JobWorkers, fc.Jobs.Workers)
+	duration("jobs.retention", fc.Jobs.Retention, &cfg.JobRetention)
+
+	setString(&cfg.WebhookSecret, fc.Webhook.Secret)
+	setInt(&cfg.WebhookMaxAttempts, fc.Webhook.MaxAttempts)
+	duration("webhook.backoff", fc.Webhook.Backoff, &cfg.WebhookBackoff)
+	duration("webhook.timeout", fc.Webhook.Timeout, &cfg.WebhookTimeout)
+	if fc.Webhook.AllowedHosts != nil {
+		cfg.WebhookAllowedHosts = fc.Webhook.AllowedHosts
+	}
+
+	setString(&cfg.TracingExporter, fc.Tracing.Exporter)
+	setString(&cfg.TracingEndpoint, fc.Tracing.Endpoint)
+	if fc.Tracing.SampleRatio != nil {
+		cfg.TracingSampleRatio = *fc.Tracing.SampleRatio
+	}
+
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// toFile converts cfg into the file layout, masking secrets if requested
+func toFile(cfg *Config, maskSecrets bool) *fileConfig {
+	var fc fileConfig
+
+	port, _ := strconv.Atoi(cfg.Port)
+	fc.Server.Port = &port
+	fc.Server.GinMode = &cfg.GinMode
+	fc.Server.ShutdownTimeout = ptr(cfg.ShutdownTimeout.String())
+	fc.Server.ReadinessProbeTTL = ptr(cfg.ReadinessProbeTTL.String())
+
+	apiKey, openAIKey, webhookSecret := cfg.APIKey, cfg.OpenAIAPIKey, cfg.WebhookSecret
+	if maskSecrets {
+		apiKey = maskSecret(apiKey)
+		openAIKey = maskSecret(openAIKey)
+		webhookSecret = maskSecret(webhookSecret)
+	}
+	fc.Upstream.APIKey = &apiKey
+	fc.Upstream.APIURL = &cfg.APIURL
+	fc.Upstream.MaxRetries = &cfg.UpstreamMaxRetries
+	fc.Upstream.Model = &cfg.Model
+	fc.Upstream.AllowedModels = cfg.AllowedModels
+	fc.Upstream.Options = cfg.ExtraOptions
+	fc.Upstream.Providers = cfg.Providers
+
+	fc.Cache.TTL = ptr(cfg.CacheTTL.String())
+	fc.Cache.MaxSize = &cfg.CacheMaxSize
+
+	fc.Limits.MaxTextLength = &cfg.MaxTextLength
+	fc.Limits.RateLimitRPM = &cfg.RateLimitRPM
+	fc.Limits.RateLimitBurst = &cfg.RateLimitBurst
+	fc.Limits.MaxDocumentSize = &cfg.MaxDocumentSize
+
+	fc.Log.Level = &cfg.LogLevel
+	fc.Log.Format = &cfg.LogFormat
+	fc.Log.RedactText = &cfg.LogRedactText
+
+	fc.OpenAI.APIKey = &openAIKey
+	fc.OpenAI.BaseURL = &cfg.OpenAIBaseURL
+	fc.OpenAI.Model = &cfg.OpenAIModel
+	fc.OpenAI.Options = cfg.OpenAIExtraOptions
+
+	fc.Pseudo.Expansion = &cfg.PseudoExpansion
+
+	fc.Breaker.FailureThreshold = &cfg.BreakerFailureThreshold
+	fc.Breaker.Cooldown = ptr(cfg.BreakerCooldown.String())
+	fc.Breaker.HalfOpenProbes = &cfg.BreakerHalfOpenProbes
+
+	fc.Jobs.Dir = &cfg.JobsDir
+	fc.Jobs.Workers = &cfg.JobWorkers
+	fc.Jobs.Retention = ptr(cfg.JobRetention.String())
+
+	fc.Webhook.Secret = &webhookSecret
+	fc.Webhook.MaxAttempts = &cfg.WebhookMaxAttempts
+	fc.Webhook.Backoff = ptr(cfg.WebhookBackoff.String())
+	fc.Webhook.Timeout = ptr(cfg.WebhookTimeout.String())
+	fc.Webhook.AllowedHosts = cfg.WebhookAllowedHosts
+
+	fc.Tracing.Exporter = &cfg.TracingExporter
+	fc.Tracing.Endpoint = &cfg.TracingEndpoint
+	fc.Tracing.SampleRatio = &cfg.TracingSampleRatio
+
+	return &fc
+}
+
+// Print writes the effective configuration to w as YAML or TOML with
+// secrets masked
+func Print(w io.Writer, cfg *Config, format string) error {
+	fc := toFile(cfg, true)
+
+	var (
+		out []byte
+		err error
+	)
+	switch strings.ToLower(format) {
+	case "", "yaml", "yml":
+		out, err = yaml.Marshal(fc)
+	case "toml":
+		out, err = toml.Marshal(fc)
+	default:
+		return fmt.Errorf("unknown output format %q (expected yaml or toml)", format)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(out)
+	return err
+}
+
+// maskSecret hides all but the last four characters of a secret
+func maskSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	if len(secret) <= 8 {
+		return "********"
+	}
+	return "********" + secret[len(secret)-4:]
+}
+
+func setString(dst *string, value *string) {
+	if value != nil {
+		*dst = *value
+	}
+}
+
+func setInt(dst *int, value *int) {
+	if value != nil {
+		*dst = *value
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
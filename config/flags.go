@@ -0,0 +1,79 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+)
+
+// flagBinding maps a command-line flag onto a Config field
+type flagBinding struct {
+	name  string
+	usage string
+	apply func(cfg *Config, value string) error
+}
+
+// flagBindings lists the settings that can be overridden on the command
+// line. Secrets are deliberately excluded so they never show up in ps.
+var flagBindings = []flagBinding{
+	{"port", "HTTP listen port", func(c *Config, v string) error { c.Port = v; return nil }},
+	{"gin-mode", "Gin mode (debug or release)", func(c *Config, v string) error { c.GinMode = v; return nil }},
+	{"api-url", "upstream translation API URL", func(c *Config, v string) error { c.APIURL = v; return nil }},
+	{"log-level", "log level (debug, info, warn, error)", func(c *Config, v string) error { c.LogLevel = v; return nil }},
+	{"log-format", "log format (text or json)", func(c *Config, v string) error { c.LogFormat = v; return nil }},
+	{"cache-ttl", "cache entry lifetime (e.g. 1h or 3600)", func(c *Config, v string) error {
+		d, err := parseDuration(v)
+		if err == nil {
+			c.CacheTTL = d
+		}
+		return err
+	}},
+	{"cache-max-size", "maximum number of cache entries", func(c *Config, v string) error {
+		return setIntFlag(&c.CacheMaxSize, v)
+	}},
+	{"max-text-length", "maximum text length per request", func(c *Config, v string) error {
+		return setIntFlag(&c.MaxTextLength, v)
+	}},
+	{"tracing-exporter", "tracing exporter (none, stdout, otlp)", func(c *Config, v string) error { c.TracingExporter = v; return nil }},
+}
+
+// RegisterFlags registers --config and the per-setting override flags on fs
+func RegisterFlags(fs *flag.FlagSet) {
+	fs.String("config", "", "path to a YAML or TOML config file")
+	for _, b := range flagBindings {
+		fs.String(b.name, "", b.usage)
+	}
+}
+
+// applyFlags overrides cfg with every flag explicitly set on fs
+func applyFlags(cfg *Config, fs *flag.FlagSet) error {
+	bindings := make(map[string]flagBinding, len(flagBindings))
+	for _, b := range flagBindings {
+		bindings[b.name] = b
+	}
+
+	var errs []error
+	fs.Visit(func(f *flag.Flag) {
+		b, ok := bindings[f.Name]
+		if !ok {
+			return
+		}
+		if err := b.apply(cfg, f.Value.String()); err != nil {
+			errs = append(errs, fmt.Errorf("--%s: %v", f.Name, err))
+		}
+	})
+
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+func setIntFlag(dst *int, value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid integer %q", value)
+	}
+	*dst = n
+	return nil
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// WatchFile polls path every interval and calls onChange whenever its
+// modification time or size changes. Polling is used instead of inotify so
+// editors that replace the file atomically are handled the same way. It
+// returns when ctx is cancelled.
+func WatchFile(ctx context.Context, path string, interval time.Duration, onChange func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last, _ := os.Stat(path)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(path)
+			if err != nil {
+				// Keep the previous state while the file is being replaced
+				continue
+			}
+			if last == nil || !info.ModTime().Equal(last.ModTime()) || info.Size() != last.Size() {
+				last = info
+				onChange()
+			}
+		}
+	}
+}
@@ -0,0 +1,416 @@
+// Package docx translates Word documents in place. Text is read from the
+// runs of word/document.xml and written back into the same runs, so
+// styles, tables, images and run formatting are left untouched.
+package docx
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/LouisLau-art/go-translator/formats"
+)
+
+// ContentType is the MIME type of .docx files
+const ContentType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+
+// documentPart is the zip entry holding the main document body
+const documentPart = "word/document.xml"
+
+// ErrInvalid is returned for files that are not Word documents
+var ErrInvalid = errors.New("not a valid .docx file")
+
+// textElem is one <w:t> element and its location in the XML
+type textElem struct {
+	start, end int // byte span of the whole element
+	text       string
+	// marker numbers, within the paragraph, a <w:t> that follows another
+	// in the same run with something such as <w:br/> or <w:tab/> between
+	// them; it is 0 for the first <w:t> of a run
+	marker int
+}
+
+// run is a <w:r> with its formatting and text elements
+type run struct {
+	props string // raw <w:rPr> element, used to compare formatting
+	texts []*textElem
+}
+
+// paragraph is a <w:p> with the runs that carry text
+type paragraph struct {
+	runs []*run
+}
+
+func (p *paragraph) text() string {
+	var b strings.Builder
+	for _, r := range p.runs {
+		for _, t := range r.texts {
+			b.WriteString(t.text)
+		}
+	}
+	return b.String()
+}
+
+// mixed reports whether the paragraph's runs use different formatting
+func (p *paragraph) mixed() bool {
+	for _, r := range p.runs[1:] {
+		if r.props != p.runs[0].props {
+			return true
+		}
+	}
+	return false
+}
+
+// Translate translates the body text of a .docx file and returns the
+// rebuilt file. Every other part of the package is copied unchanged.
+func Translate(ctx context.Context, data []byte, translate formats.TranslateFunc) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	var document []byte
+	for _, f := range zr.File {
+		if f.Name == documentPart {
+			if document, err = readFile(f); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if document == nil {
+		return nil, fmt.Errorf("%w: missing %s", ErrInvalid, documentPart)
+	}
+
+	translated, err := translateXML(ctx, document, translate)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	zw := zip.NewWriter(&out)
+	for _, f := range zr.File {
+		header := f.FileHeader
+		w, err := zw.CreateHeader(&header)
+		if err != nil {
+			return nil, err
+		}
+		if f.Name == documentPart {
+			_, err = w.Write(translated)
+		} else {
+			err = copyFile(w, f)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func readFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+func copyFile(w io.Writer, f *zip.File) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	_, err = io.Copy(w, rc)
+	return err
+}
+
+// translateXML translates every paragraph of document.xml and rewrites
+// only the <w:t> elements whose text changed
+func translateXML(ctx context.Context, document []byte, translate formats.TranslateFunc) ([]byte, error) {
+	paragraphs, err := parse(document)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		segments []string
+		targets  []*paragraph
+	)
+	for _, p := range paragraphs {
+		if strings.TrimSpace(p.text()) == "" {
+			continue
+		}
+		segments = append(segments, segmentText(p))
+		targets = append(targets, p)
+	}
+	if len(segments) == 0 {
+		return document, nil
+	}
+
+	translations, err := translate(ctx, segments)
+	if err != nil {
+		return nil, err
+	}
+	if len(translations) != len(segments) {
+		return nil, fmt.Errorf("got %d translations for %d paragraphs", len(translations), len(segments))
+	}
+
+	var edits []edit
+	for i, p := range targets {
+		edits = append(edits, apply(p, translations[i])...)
+	}
+	return rewrite(document, edits), nil
+}
+
+// segmentText is the text sent for translation. When runs are formatted
+// differently each run is wrapped in a numbered <rN> tag so the
+// translation can be mapped back onto the runs. Within a run, the breaks
+// and tabs between its <w:t> elements become <xN/> markers, so each piece
+// of the translation returns to its own side of them.
+func segmentText(p *paragraph) string {
+	tagged := len(p.runs) > 1 && p.mixed()
+	var b strings.Builder
+	for i, r := range p.runs {
+		if tagged {
+			fmt.Fprintf(&b, "<r%d>", i)
+		}
+		for _, t := range r.texts {
+			if t.marker > 0 {
+				fmt.Fprintf(&b, "<x%d/>", t.marker)
+			}
+			b.WriteString(t.text)
+		}
+		if tagged {
+			fmt.Fprintf(&b, "</r%d>", i)
+		}
+	}
+	return b.String()
+}
+
+var (
+	runTag    = regexp.MustCompile(`<r(\d+)>([\s\S]*?)</r(\d+)>`)
+	breakMark = regexp.MustCompile(`<x(\d+)/>`)
+)
+
+// splitRuns maps a tagged translation back onto n runs. It reports false
+// when the tags did not survive translation intact.
+func splitRuns(translation string, n int) ([]string, bool) {
+	matches := runTag.FindAllStringSubmatch(translation, -1)
+	if len(matches) != n {
+		return nil, false
+	}
+	parts := make([]string, n)
+	seen := make([]bool, n)
+	for _, m := range matches {
+		i, err := strconv.Atoi(m[1])
+		if err != nil || m[1] != m[3] || i >= n || seen[i] {
+			return nil, false
+		}
+		parts[i], seen[i] = m[2], true
+	}
+	return parts, true
+}
+
+// edit replaces document[start:end] with text
+type edit struct {
+	start, end int
+	text       string
+}
+
+// apply distributes a paragraph's translation over its text elements
+func apply(p *paragraph, translation string) []edit {
+	var edits []edit
+	if parts, ok := splitRuns(translation, len(p.runs)); ok && p.mixed() {
+		for i, r := range p.runs {
+			edits = append(edits, fill(r.texts, parts[i])...)
+		}
+		return edits
+	}
+
+	// Same formatting throughout, or the tags were lost: the first run
+	// takes the whole translation and keeps its formatting
+	var texts []*textElem
+	for _, r := range p.runs {
+		texts = append(texts, r.texts...)
+	}
+	return fill(texts, runTag.ReplaceAllString(translation, "$2"))
+}
+
+// fill writes translation into texts: the text before the first <xN/>
+// marker goes to the first element and the text after each marker to the
+// element it numbers. Other elements are emptied. If the markers did not
+// survive translation intact, the first element takes the whole
+// translation.
+func fill(texts []*textElem, translation string) []edit {
+	targets := []*textElem{texts[0]}
+	for _, t := range texts[1:] {
+		if t.marker > 0 {
+			targets = append(targets, t)
+		}
+	}
+	pieces := map[*textElem]string{texts[0]: breakMark.ReplaceAllString(translation, "")}
+	if split, ok := splitBreaks(translation, targets[1:]); ok {
+		for i, t := range targets {
+			pieces[t] = split[i]
+		}
+	}
+
+	edits := make([]edit, len(texts))
+	for i, t := range texts {
+		edits[i] = edit{start: t.start, end: t.end, text: textElement(pieces[t])}
+	}
+	return edits
+}
+
+// splitBreaks splits translation at its <xN/> markers, which must be
+// those of marked, in order. It reports false when they did not survive
+// translation intact.
+func splitBreaks(translation string, marked []*textElem) ([]string, bool) {
+	matches := breakMark.FindAllStringSubmatchIndex(translation, -1)
+	if len(matches) != len(marked) {
+		return nil, false
+	}
+	parts := make([]string, 0, len(marked)+1)
+	pos := 0
+	for i, m := range matches {
+		if translation[m[2]:m[3]] != strconv.Itoa(marked[i].marker) {
+			return nil, false
+		}
+		parts = append(parts, translation[pos:m[0]])
+		pos = m[1]
+	}
+	return append(parts, translation[pos:]), true
+}
+
+// textElement renders a <w:t> element, preserving surrounding spaces
+func textElement(text string) string {
+	var b strings.Builder
+	b.WriteString(`<w:t xml:space="preserve">`)
+	xml.EscapeText(&b, []byte(text))
+	b.WriteString(`</w:t>`)
+	return b.String()
+}
+
+func rewrite(document []byte, edits []edit) []byte {
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+	var out bytes.Buffer
+	pos := 0
+	for _, e := range edits {
+		out.Write(document[pos:e.start])
+		out.WriteString(e.text)
+		pos = e.end
+	}
+	out.Write(document[pos:])
+	return out.Bytes()
+}
+
+// parse scans document.xml and records the paragraphs, runs and text
+// elements with their byte offsets. Nested paragraphs, such as those in
+// text boxes, are kept separate from the paragraph containing them.
+func parse(document []byte) ([]*paragraph, error) {
+	dec := xml.NewDecoder(bytes.NewReader(document))
+
+	var (
+		paragraphs []*paragraph
+		pStack     []*paragraph
+		rStack     []*run
+		current    *textElem
+		propsStart = -1
+		propsDepth int
+	)
+	isW := func(name xml.Name, local string) bool {
+		return name.Space == "w" && name.Local == local
+	}
+
+	for {
+		offset := int(dec.InputOffset())
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case isW(t.Name, "p"):
+				p := &paragraph{}
+				paragraphs = append(paragraphs, p)
+				pStack = append(pStack, p)
+			case isW(t.Name, "r") && len(pStack) > 0:
+				r := &run{}
+				rStack = append(rStack, r)
+			case isW(t.Name, "rPr") && len(rStack) > 0:
+				if propsStart < 0 {
+					propsStart = offset
+				}
+				propsDepth++
+			case isW(t.Name, "t") && len(rStack) > 0:
+				current = &textElem{start: offset}
+			}
+
+		case xml.CharData:
+			if current != nil {
+				current.text += string(t)
+			}
+
+		case xml.EndElement:
+			end := int(dec.InputOffset())
+			switch {
+			case isW(t.Name, "p") && len(pStack) > 0:
+				pStack = pStack[:len(pStack)-1]
+			case isW(t.Name, "r") && len(rStack) > 0:
+				r := rStack[len(rStack)-1]
+				rStack = rStack[:len(rStack)-1]
+				if len(r.texts) > 0 && len(pStack) > 0 {
+					p := pStack[len(pStack)-1]
+					p.runs = append(p.runs, r)
+				}
+			case isW(t.Name, "rPr") && propsDepth > 0:
+				propsDepth--
+				if propsDepth == 0 {
+					rStack[len(rStack)-1].props = string(document[propsStart:end])
+					propsStart = -1
+				}
+			case isW(t.Name, "t") && current != nil:
+				current.end = end
+				r := rStack[len(rStack)-1]
+				r.texts = append(r.texts, current)
+				current = nil
+			}
+		}
+	}
+
+	// Self-closing <w:t/> elements are reported as a start and end token
+	// at the same offset; they carry no text and need no special casing.
+	var withText []*paragraph
+	for _, p := range paragraphs {
+		if len(p.runs) == 0 {
+			continue
+		}
+		// Number the <w:t> elements that follow another in their run
+		n := 0
+		for _, r := range p.runs {
+			for _, t := range r.texts[1:] {
+				n++
+				t.marker = n
+			}
+		}
+		withText = append(withText, p)
+	}
+	return withText, nil
+}
@@ -0,0 +1,163 @@
+package docx
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/LouisLau-art/go-translator/formats/formatstest"
+)
+
+const testDocument = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+<w:body>
+<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>Hello</w:t></w:r><w:r><w:t xml:space="preserve"> world</w:t></w:r></w:p>
+<w:p><w:r><w:t xml:space="preserve">Click </w:t></w:r><w:r><w:rPr><w:b/></w:rPr><w:t>Save</w:t></w:r><w:r><w:t xml:space="preserve"> &amp; exit</w:t></w:r></w:p>
+<w:tbl><w:tr><w:tc><w:p><w:r><w:t>Cell</w:t></w:r></w:p></w:tc></w:tr></w:tbl>
+<w:p><w:r><w:t></w:t></w:r></w:p>
+<w:sectPr/>
+</w:body>
+</w:document>`
+
+func buildDocx(t *testing.T, document string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range []struct{ name, body string }{
+		{"[Content_Types].xml", `<Types/>`},
+		{documentPart, document},
+		{"word/styles.xml", `<w:styles/>`},
+	} {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(f.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func readPart(t *testing.T, data []byte, name string) string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range zr.File {
+		if f.Name == name {
+			body, err := readFile(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return string(body)
+		}
+	}
+	t.Fatalf("part %s missing", name)
+	return ""
+}
+
+func TestTranslate(t *testing.T) {
+	var calls formatstest.Recorder
+	out, err := Translate(context.Background(), buildDocx(t, testDocument), calls.Upper())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"Hello world", "<r0>Click </r0><r1>Save</r1><r2> & exit</r2>", "Cell"}
+	if strings.Join(calls.Segments(), "|") != strings.Join(want, "|") {
+		t.Fatalf("segments = %q, want %q", calls.Segments(), want)
+	}
+
+	document := readPart(t, out, documentPart)
+	for _, s := range []string{
+		`<w:pStyle w:val="Heading1"/>`,
+		`<w:r><w:t xml:space="preserve">HELLO WORLD</w:t></w:r><w:r><w:t xml:space="preserve"></w:t></w:r>`,
+		`<w:rPr><w:b/></w:rPr><w:t xml:space="preserve">SAVE</w:t>`,
+		`<w:t xml:space="preserve"> &amp; EXIT</w:t>`,
+		`<w:tbl><w:tr><w:tc><w:p><w:r><w:t xml:space="preserve">CELL</w:t>`,
+		`<w:sectPr/>`,
+	} {
+		if !strings.Contains(document, s) {
+			t.Errorf("document missing %s:\n%s", s, document)
+		}
+	}
+	if styles := readPart(t, out, "word/styles.xml"); styles != `<w:styles/>` {
+		t.Errorf("styles changed: %s", styles)
+	}
+}
+
+func TestTranslateLostRunTags(t *testing.T) {
+	flatten := func(_ context.Context, segments []string) ([]string, error) {
+		out := make([]string, len(segments))
+		for i, s := range segments {
+			out[i] = runTag.ReplaceAllString(s, "$2")
+		}
+		return out, nil
+	}
+
+	out, err := Translate(context.Background(), buildDocx(t, testDocument), flatten)
+	if err != nil {
+		t.Fatal(err)
+	}
+	document := readPart(t, out, documentPart)
+	if !strings.Contains(document, `<w:t xml:space="preserve">Click Save &amp; exit</w:t>`) {
+		t.Errorf("translation not placed in the first run:\n%s", document)
+	}
+}
+
+func TestTranslateKeepsBreaksInPlace(t *testing.T) {
+	document := `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+		`<w:p><w:r><w:t>First line</w:t><w:br/><w:t>Second line</w:t></w:r></w:p>` +
+		`<w:p><w:r><w:t xml:space="preserve">Name:</w:t></w:r><w:r><w:rPr><w:b/></w:rPr><w:tab/><w:t>Value</w:t><w:tab/><w:t>Unit</w:t></w:r></w:p>` +
+		`</w:body></w:document>`
+
+	var calls formatstest.Recorder
+	out, err := Translate(context.Background(), buildDocx(t, document), calls.Upper())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"First line<x1/>Second line", "<r0>Name:</r0><r1>Value<x1/>Unit</r1>"}
+	if strings.Join(calls.Segments(), "|") != strings.Join(want, "|") {
+		t.Fatalf("segments = %q, want %q", calls.Segments(), want)
+	}
+	result := readPart(t, out, documentPart)
+	for _, s := range []string{
+		`<w:t xml:space="preserve">FIRST LINE</w:t><w:br/><w:t xml:space="preserve">SECOND LINE</w:t>`,
+		`<w:tab/><w:t xml:space="preserve">VALUE</w:t><w:tab/><w:t xml:space="preserve">UNIT</w:t>`,
+	} {
+		if !strings.Contains(result, s) {
+			t.Errorf("document missing %s:\n%s", s, result)
+		}
+	}
+
+	// Without its markers the translation goes before the first break
+	drop := func(_ context.Context, segments []string) ([]string, error) {
+		out := make([]string, len(segments))
+		for i, s := range segments {
+			out[i] = breakMark.ReplaceAllString(s, " ")
+		}
+		return out, nil
+	}
+	out, err = Translate(context.Background(), buildDocx(t, document), drop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := readPart(t, out, documentPart); !strings.Contains(result, `<w:t xml:space="preserve">First line Second line</w:t><w:br/><w:t xml:space="preserve"></w:t>`) {
+		t.Errorf("translation not placed before the break:\n%s", result)
+	}
+}
+
+func TestTranslateRejectsNonDocx(t *testing.T) {
+	_, err := Translate(context.Background(), []byte("plain text"), nil)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,34 @@
+// Package formats holds what the document format packages share
+package formats
+
+import "context"
+
+// TranslateFunc translates segments of text, returning one translation per
+// segment in the same order
+type TranslateFunc func(ctx context.Context, segments []string) ([]string, error)
+
+// Stats counts the entries of a catalog-style format that a translation
+// touched
+type Stats struct {
+	Translated int
+	// Skipped entries could not be translated with their placeholders or
+	// inline tags intact and were left as they were
+	Skipped int
+}
+
+// messageContextKey carries the message context of segments
+type messageContextKey struct{}
+
+// WithMessageContext returns a context telling a TranslateFunc that its
+// segments share a message context, such as a gettext msgctxt, which
+// tells apart identical texts used in different places
+func WithMessageContext(ctx context.Context, message string) context.Context {
+	return context.WithValue(ctx, messageContextKey{}, message)
+}
+
+// MessageContext returns the message context set by WithMessageContext,
+// or "" when there is none
+func MessageContext(ctx context.Context) string {
+	message, _ := ctx.Value(messageContextKey{}).(string)
+	return message
+}
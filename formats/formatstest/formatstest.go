@@ -0,0 +1,62 @@
+// Package formatstest provides fake translators for testing the document
+// formats
+package formatstest
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/LouisLau-art/go-translator/formats"
+)
+
+// marker matches the inline tags the formats send for translation, such as
+// <g0>, </g0>, <x1/> and <r2>
+var marker = regexp.MustCompile(`</?[a-z]+\d+/?>`)
+
+// Recorder records the batches of segments sent to its translators
+type Recorder struct {
+	Batches [][]string
+}
+
+// Segments returns every segment sent, in order
+func (r *Recorder) Segments() []string {
+	var segments []string
+	for _, batch := range r.Batches {
+		segments = append(segments, batch...)
+	}
+	return segments
+}
+
+// Upper returns a TranslateFunc that upper-cases text and leaves inline
+// tags as they are
+func (r *Recorder) Upper() formats.TranslateFunc {
+	return r.translate(func(s string) string {
+		var b strings.Builder
+		pos := 0
+		for _, m := range marker.FindAllStringIndex(s, -1) {
+			b.WriteString(strings.ToUpper(s[pos:m[0]]))
+			b.WriteString(s[m[0]:m[1]])
+			pos = m[1]
+		}
+		b.WriteString(strings.ToUpper(s[pos:]))
+		return b.String()
+	})
+}
+
+// Wrap returns a TranslateFunc that encloses each segment in open and
+// close, such as "T(" and ")", so the output shows what was sent
+func (r *Recorder) Wrap(open, close string) formats.TranslateFunc {
+	return r.translate(func(s string) string { return open + s + close })
+}
+
+func (r *Recorder) translate(fn func(string) string) formats.TranslateFunc {
+	return func(_ context.Context, segments []string) ([]string, error) {
+		r.Batches = append(r.Batches, segments)
+		out := make([]string, len(segments))
+		for i, s := range segments {
+			out[i] = fn(s)
+		}
+		return out, nil
+	}
+}
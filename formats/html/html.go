@@ -0,0 +1,422 @@
+// Package html translates HTML documents and fragments. Text and the alt,
+// title and placeholder attributes are translated; tags, other attributes
+// and content marked translate="no" or class="notranslate" are kept.
+// Inline elements such as <a> or <b> stay inside the sentence they belong
+// to, so the sentence is translated as a whole.
+package html
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/LouisLau-art/go-translator/formats"
+)
+
+// ContentType is the MIME type of HTML documents
+const ContentType = "text/html; charset=utf-8"
+
+// translatedAttrs are the attributes holding text for people
+var translatedAttrs = []string{"alt", "title", "placeholder"}
+
+// skippedElements hold code or data rather than prose; they are kept as
+// they are, along with their attributes
+var skippedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Template: true, atom.Noscript: true,
+	atom.Pre: true, atom.Code: true, atom.Kbd: true, atom.Samp: true, atom.Var: true,
+	atom.Textarea: true, atom.Svg: true, atom.Math: true, atom.Iframe: true, atom.Object: true,
+}
+
+// inlineElements are the elements that can sit inside a sentence
+var inlineElements = map[atom.Atom]bool{
+	atom.A: true, atom.Abbr: true, atom.B: true, atom.Bdi: true, atom.Bdo: true,
+	atom.Big: true, atom.Br: true, atom.Button: true, atom.Cite: true, atom.Code: true,
+	atom.Data: true, atom.Del: true, atom.Dfn: true, atom.Em: true, atom.Font: true,
+	atom.I: true, atom.Img: true, atom.Input: true, atom.Ins: true, atom.Kbd: true,
+	atom.Label: true, atom.Mark: true, atom.Q: true, atom.S: true, atom.Samp: true,
+	atom.Small: true, atom.Span: true, atom.Strike: true, atom.Strong: true, atom.Sub: true,
+	atom.Sup: true, atom.Time: true, atom.Tt: true, atom.U: true, atom.Var: true, atom.Wbr: true,
+}
+
+// Translate translates an HTML document, or a fragment such as pasted
+// markup, and returns it serialized. A document's <html lang> is set to
+// target. Sentences whose inline tags do not survive translation are left
+// as they were and counted as skipped.
+func Translate(ctx context.Context, data []byte, target string, translate formats.TranslateFunc) ([]byte, formats.Stats, error) {
+	var (
+		stats formats.Stats
+		root  *html.Node
+		err   error
+	)
+	document := isDocument(data)
+	if document {
+		if root, err = html.Parse(bytes.NewReader(data)); err != nil {
+			return nil, stats, err
+		}
+	} else {
+		// A fragment is parsed as the content of <body>
+		root = &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+		nodes, err := html.ParseFragment(bytes.NewReader(data), root)
+		if err != nil {
+			return nil, stats, err
+		}
+		for _, n := range nodes {
+			root.AppendChild(n)
+		}
+	}
+
+	w := &walker{}
+	w.container(root)
+	if stats, err = w.translate(ctx, translate); err != nil {
+		return nil, stats, err
+	}
+
+	var out bytes.Buffer
+	if document {
+		setLang(root, target)
+		err = html.Render(&out, root)
+	} else {
+		for n := root.FirstChild; n != nil && err == nil; n = n.NextSibling {
+			err = html.Render(&out, n)
+		}
+	}
+	if err != nil {
+		return nil, stats, err
+	}
+	return out.Bytes(), stats, nil
+}
+
+var documentStart = regexp.MustCompile(`(?i)^(?:\s|<!--.*?-->)*<(?:!doctype|html)|<(?:head|body)[\s>]`)
+
+// isDocument reports whether data is a whole document rather than a
+// fragment
+func isDocument(data []byte) bool {
+	return documentStart.Match(bytes.TrimPrefix(data, []byte("\ufeff")))
+}
+
+// setLang sets the lang attribute of a document's <html> element
+func setLang(doc *html.Node, lang string) {
+	for n := doc.FirstChild; n != nil; n = n.NextSibling {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Html {
+			for i, a := range n.Attr {
+				if a.Key == "lang" && a.Namespace == "" {
+					n.Attr[i].Val = lang
+					return
+				}
+			}
+			n.Attr = append(n.Attr, html.Attribute{Key: "lang", Val: lang})
+			return
+		}
+	}
+}
+
+// noTranslate reports whether an element opts out of translation
+func noTranslate(n *html.Node) bool {
+	for _, a := range n.Attr {
+		if a.Key == "translate" && strings.EqualFold(a.Val, "no") {
+			return true
+		}
+		if a.Key == "class" && slices.Contains(strings.Fields(a.Val), "notranslate") {
+			return true
+		}
+	}
+	return false
+}
+
+// reenabled reports whether an element opts back into translation inside
+// content that is not translated
+func reenabled(n *html.Node) bool {
+	for _, a := range n.Attr {
+		if a.Key == "translate" && strings.EqualFold(a.Val, "yes") {
+			return true
+		}
+	}
+	return false
+}
+
+// kept reports whether an element's content is left untranslated
+func kept(n *html.Node) bool {
+	return skippedElements[n.DataAtom] || n.Namespace != "" || noTranslate(n)
+}
+
+// inline reports whether a node belongs to the sentence around it
+func inline(n *html.Node) bool {
+	switch n.Type {
+	case html.TextNode, html.CommentNode:
+		return true
+	case html.ElementNode:
+		return inlineElements[n.DataAtom] && !hasBlock(n)
+	}
+	return false
+}
+
+// hasBlock reports whether an inline element wraps block content, as an
+// <a> around a <div> may
+func hasBlock(n *html.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && (!inlineElements[c.DataAtom] || hasBlock(c)) {
+			return true
+		}
+	}
+	return false
+}
+
+// segment is a unit of translation: a sentence made of sibling nodes, or
+// an attribute value
+type segment struct {
+	text       string // text with markers, whitespace collapsed and trimmed
+	lead, tail string
+
+	// A sentence: its nodes and the elements behind its markers
+	run  []*html.Node
+	tags []*html.Node
+	// An attribute
+	attr *html.Attribute
+}
+
+// walker collects the segments of a tree
+type walker struct {
+	segments []*segment
+}
+
+// container collects the sentences and attributes of an element whose
+// children may be blocks
+func (w *walker) container(n *html.Node) {
+	var run []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if inline(c) {
+			run = append(run, c)
+			continue
+		}
+		w.sentence(run)
+		run = nil
+		if c.Type != html.ElementNode {
+			continue
+		}
+		if kept(c) {
+			w.reenabled(c)
+			continue
+		}
+		w.attributes(c)
+		w.container(c)
+	}
+	w.sentence(run)
+}
+
+// reenabled looks for translate="yes" inside kept content
+func (w *walker) reenabled(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		if reenabled(c) && !skippedElements[c.DataAtom] {
+			w.attributes(c)
+			w.container(c)
+		} else {
+			w.reenabled(c)
+		}
+	}
+}
+
+// attributes collects the translatable attributes of an element
+func (w *walker) attributes(n *html.Node) {
+	for i := range n.Attr {
+		a := &n.Attr[i]
+		if a.Namespace != "" || !slices.Contains(translatedAttrs, a.Key) {
+			continue
+		}
+		s := &segment{attr: a}
+		s.setText(a.Val)
+		if s.translatable() {
+			w.segments = append(w.segments, s)
+		}
+	}
+}
+
+// sentence collects a run of inline sibling nodes
+func (w *walker) sentence(run []*html.Node) {
+	if len(run) == 0 {
+		return
+	}
+	s := &segment{run: run}
+	var b strings.Builder
+	for _, n := range run {
+		s.write(&b, n, w)
+	}
+	s.setText(b.String())
+	if s.translatable() {
+		w.segments = append(w.segments, s)
+	}
+}
+
+var whitespace = regexp.MustCompile(`[ \t\n\f\r]+`)
+
+// write appends a node to the sentence text. Inline elements become
+// <gN>…</gN> markers around their content; <br>, <img>, comments and kept
+// elements become <xN/>.
+func (s *segment) write(b *strings.Builder, n *html.Node, w *walker) {
+	switch {
+	case n.Type == html.TextNode:
+		b.WriteString(whitespace.ReplaceAllString(n.Data, " "))
+	case n.Type == html.ElementNode && n.FirstChild != nil && !kept(n):
+		i := len(s.tags)
+		s.tags = append(s.tags, n)
+		w.attributes(n)
+		fmt.Fprintf(b, "<g%d>", i)
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			s.write(b, c, w)
+		}
+		fmt.Fprintf(b, "</g%d>", i)
+	default:
+		if n.Type == html.ElementNode && !kept(n) {
+			w.attributes(n)
+		}
+		fmt.Fprintf(b, "<x%d/>", len(s.tags))
+		s.tags = append(s.tags, n)
+	}
+}
+
+func (s *segment) setText(text string) {
+	if s.attr == nil {
+		text = whitespace.ReplaceAllString(text, " ")
+	}
+	trimmed := strings.TrimSpace(text)
+	start := strings.Index(text, trimmed)
+	s.lead, s.text, s.tail = text[:start], trimmed, text[start+len(trimmed):]
+}
+
+var markerRe = regexp.MustCompile(`<(/?)([gx])(\d+)(/?)>`)
+
+// translatable reports whether the segment has letters outside markers
+func (s *segment) translatable() bool {
+	return strings.IndexFunc(markerRe.ReplaceAllString(s.text, ""), unicode.IsLetter) >= 0
+}
+
+// translate translates the collected segments and applies them
+func (w *walker) translate(ctx context.Context, translate formats.TranslateFunc) (formats.Stats, error) {
+	var stats formats.Stats
+	if len(w.segments) == 0 {
+		return stats, nil
+	}
+	texts := make([]string, len(w.segments))
+	for i, s := range w.segments {
+		texts[i] = s.text
+	}
+	translations, err := translate(ctx, texts)
+	if err != nil {
+		return stats, err
+	}
+	if len(translations) != len(texts) {
+		return stats, fmt.Errorf("got %d translations for %d segments", len(translations), len(texts))
+	}
+	for i, s := range w.segments {
+		if s.apply(strings.TrimSpace(translations[i])) {
+			stats.Translated++
+		} else {
+			stats.Skipped++
+		}
+	}
+	return stats, nil
+}
+
+// apply replaces the segment's content with its translation. It reports
+// false, changing nothing, unless every marker appears exactly once and
+// paired markers nest properly.
+func (s *segment) apply(translation string) bool {
+	if s.attr != nil {
+		s.attr.Val = s.lead + translation + s.tail
+		return true
+	}
+	if !s.valid(translation) {
+		return false
+	}
+
+	parent := s.run[0].Parent
+	next := s.run[len(s.run)-1].NextSibling
+	for _, n := range s.run {
+		parent.RemoveChild(n)
+	}
+	add := func(to, n *html.Node) {
+		if to == parent {
+			parent.InsertBefore(n, next)
+		} else {
+			to.AppendChild(n)
+		}
+	}
+	text := func(to *html.Node, text string) {
+		if text != "" {
+			add(to, &html.Node{Type: html.TextNode, Data: text})
+		}
+	}
+
+	text(parent, s.lead)
+	stack := []*html.Node{parent}
+	pos := 0
+	for _, m := range markerRe.FindAllStringSubmatchIndex(translation, -1) {
+		top := stack[len(stack)-1]
+		text(top, translation[pos:m[0]])
+		pos = m[1]
+		n, _ := strconv.Atoi(translation[m[6]:m[7]])
+		orig := s.tags[n]
+		switch {
+		case m[3] > m[2]: // closing
+			stack = stack[:len(stack)-1]
+		case translation[m[4]:m[5]] == "x":
+			if orig.Parent != nil {
+				orig.Parent.RemoveChild(orig)
+			}
+			add(top, orig)
+		default:
+			el := &html.Node{Type: orig.Type, DataAtom: orig.DataAtom, Data: orig.Data, Namespace: orig.Namespace, Attr: orig.Attr}
+			add(top, el)
+			stack = append(stack, el)
+		}
+	}
+	text(parent, translation[pos:])
+	text(parent, s.tail)
+	return true
+}
+
+// valid checks the markers of a translated sentence
+func (s *segment) valid(translation string) bool {
+	seen := make([]bool, len(s.tags))
+	var stack []int
+	for _, m := range markerRe.FindAllStringSubmatchIndex(translation, -1) {
+		closing := m[3] > m[2]
+		kind := translation[m[4]:m[5]]
+		selfClosing := m[9] > m[8]
+		n, err := strconv.Atoi(translation[m[6]:m[7]])
+		if err != nil || n >= len(s.tags) {
+			return false
+		}
+		paired := s.tags[n].Type == html.ElementNode && s.tags[n].FirstChild != nil && !kept(s.tags[n])
+		switch {
+		case kind == "x" && selfClosing && !closing && !paired && !seen[n]:
+			seen[n] = true
+		case kind == "g" && !selfClosing && !closing && paired && !seen[n]:
+			seen[n] = true
+			stack = append(stack, n)
+		case kind == "g" && closing && len(stack) > 0 && stack[len(stack)-1] == n:
+			stack = stack[:len(stack)-1]
+		default:
+			return false
+		}
+	}
+	if len(stack) > 0 {
+		return false
+	}
+	for _, ok := range seen {
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+package html
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/LouisLau-art/go-translator/formats/formatstest"
+)
+
+func TestTranslateFragment(t *testing.T) {
+	input := `<p>Click <a href="/go" title="Go there">here</a> to
+    <b>save</b> your work.<br>Then <code>exit</code>.</p>
+<img src="a.png" alt="A cat"><input placeholder="Your name">
+<p translate="no">Brand <span translate="yes">Slogan</span></p>
+<div class="box notranslate">Keep</div>
+<script>var s = "text";</script>`
+	var calls formatstest.Recorder
+	out, stats, err := Translate(context.Background(), []byte(input), "de", calls.Upper())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `<p>CLICK <a href="/go" title="GO THERE">HERE</a> TO <b>SAVE</b> YOUR WORK.<br/>THEN <code>exit</code>.</p>
+<img src="a.png" alt="A CAT"/><input placeholder="YOUR NAME"/>
+<p translate="no">Brand <span translate="yes">SLOGAN</span></p>
+<div class="box notranslate">Keep</div>
+<script>var s = "text";</script>`
+	if string(out) != want {
+		t.Errorf("output:\n%s\nwant:\n%s", out, want)
+	}
+	if !strings.Contains(strings.Join(calls.Segments(), "|"), "Click <g0>here</g0> to <g1>save</g1> your work.<x2/>Then <x3/>.") {
+		t.Errorf("segments = %q", calls.Segments())
+	}
+	if stats.Translated != 5 || stats.Skipped != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestTranslateDocument(t *testing.T) {
+	input := `<!DOCTYPE html>
+<html lang="en"><head><title>Hello</title><style>p { color: red }</style></head>
+<body><ul><li>One &amp; two</li><li><a href="#"><div>Block link</div></a></li></ul></body></html>`
+	var calls formatstest.Recorder
+	out, _, err := Translate(context.Background(), []byte(input), "fr", calls.Upper())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<!DOCTYPE html>`,
+		`<html lang="fr">`,
+		`<title>HELLO</title>`,
+		`<style>p { color: red }</style>`,
+		`<li>ONE &amp; TWO</li>`,
+		`<a href="#"><div>BLOCK LINK</div></a>`,
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestTranslateSkipsBrokenMarkers(t *testing.T) {
+	input := `<p>Read <a href="/x">this</a></p>`
+	out, stats, err := Translate(context.Background(), []byte(input), "de", func(_ context.Context, s []string) ([]string, error) {
+		return []string{"Lies <g0>das"}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != input || stats.Skipped != 1 {
+		t.Errorf("output = %s, stats = %+v", out, stats)
+	}
+}
+
+func TestTranslateEscapesText(t *testing.T) {
+	out, _, err := Translate(context.Background(), []byte(`<p>a</p>`), "de", func(_ context.Context, s []string) ([]string, error) {
+		return []string{"x < y & <script>"}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `<p>x &lt; y &amp; &lt;script&gt;</p>`; string(out) != want {
+		t.Errorf("output = %s, want %s", out, want)
+	}
+}
@@ -0,0 +1,525 @@
+// Package pdf extracts the text of PDF documents in reading order and
+// translates it. The layout is not reproduced: the result is Markdown or
+// plain text with a marker at the start of each page, paragraphs separated
+// by blank lines and headings recognised by their font size.
+package pdf
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+
+	"github.com/LouisLau-art/go-translator/formats"
+)
+
+// MIME types of the translated document
+const (
+	MarkdownContentType = "text/markdown; charset=utf-8"
+	TextContentType     = "text/plain; charset=utf-8"
+)
+
+// ErrInvalid is returned for files that are not readable PDF documents
+var ErrInvalid = errors.New("not a valid PDF file")
+
+// Output is the form of the translated document
+type Output int
+
+const (
+	Markdown Output = iota
+	PlainText
+)
+
+// ParseOutput reads an output name, "markdown" or "text". The empty string
+// is Markdown.
+func ParseOutput(name string) (Output, error) {
+	switch strings.ToLower(name) {
+	case "", "markdown", "md":
+		return Markdown, nil
+	case "text", "txt":
+		return PlainText, nil
+	}
+	return 0, fmt.Errorf("unknown output %q", name)
+}
+
+// BlockKind tells paragraphs, headings and list items apart
+type BlockKind int
+
+const (
+	Paragraph BlockKind = iota
+	Heading
+	ListItem
+)
+
+// Block is a paragraph, heading or list item of a page, its lines joined
+type Block struct {
+	Kind BlockKind
+	Text string
+}
+
+// Page is the text of one page in reading order
+type Page struct {
+	Number int
+	Blocks []Block
+}
+
+// bullets start list items
+const bullets = "•◦▪‣●○■"
+
+// Extract reads the text of every page of a PDF document. Pages without
+// text, such as scanned images, have no blocks.
+func Extract(data []byte) (pages []Page, err error) {
+	// The reader panics on some malformed files
+	defer func() {
+		if r := recover(); r != nil {
+			pages, err = nil, fmt.Errorf("%w: %v", ErrInvalid, r)
+		}
+	}()
+	r, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	glyphs := make([][]pdf.Text, r.NumPage())
+	for i := range glyphs {
+		if p := r.Page(i + 1); !p.V.IsNull() {
+			glyphs[i] = p.Content().Text
+		}
+	}
+	body := bodySize(glyphs)
+	for i, g := range glyphs {
+		pages = append(pages, Page{Number: i + 1, Blocks: blocks(readingOrder(lines(g)), body)})
+	}
+	return pages, nil
+}
+
+// Translate extracts the text of a PDF document and translates it a page at
+// a time, so each request carries a page of context. Pages longer than
+// maxLength bytes are sent as several groups of blocks, none longer than
+// maxLength; 0 means no limit. Pages without text are counted as skipped.
+func Translate(ctx context.Context, data []byte, output Output, maxLength int, translate formats.TranslateFunc) ([]byte, formats.Stats, error) {
+	var stats formats.Stats
+	pages, err := Extract(data)
+	if err != nil {
+		return nil, stats, err
+	}
+
+	var (
+		segments []string
+		groups   = make([][]group, len(pages))
+	)
+	for i, p := range pages {
+		groups[i] = p.groups(output, maxLength)
+		if len(groups[i]) == 0 {
+			stats.Skipped++
+			continue
+		}
+		for j := range groups[i] {
+			groups[i][j].segment = len(segments)
+			segments = append(segments, groups[i][j].text)
+		}
+		stats.Translated++
+	}
+	var translated []string
+	if len(segments) > 0 {
+		if translated, err = translate(ctx, segments); err != nil {
+			return nil, stats, err
+		}
+	}
+
+	var b strings.Builder
+	for i, p := range pages {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(pageMarker(p.Number, output) + "\n")
+		var body strings.Builder
+		for _, g := range groups[i] {
+			body.WriteString(g.sep + strings.TrimSpace(translated[g.segment]))
+		}
+		if text := strings.TrimSpace(body.String()); text != "" {
+			b.WriteString("\n" + text + "\n")
+		}
+	}
+	return []byte(b.String()), stats, nil
+}
+
+// group is a run of a page's formatted blocks translated together
+type group struct {
+	// sep is the text between the previous group and this one
+	sep     string
+	text    string
+	segment int
+}
+
+// groups splits the formatted page into runs of blocks no longer than
+// maxLength bytes. A block longer than that on its own is cut between
+// words, or between characters in scripts without spaces.
+func (p Page) groups(output Output, maxLength int) []group {
+	parts, seps := p.parts(output)
+	if maxLength <= 0 {
+		if len(parts) == 0 {
+			return nil
+		}
+		return []group{{text: p.Format(output)}}
+	}
+
+	var (
+		groups []group
+		cur    *group
+	)
+	add := func(sep, text string) {
+		if cur != nil && len(cur.text)+len(sep)+len(text) <= maxLength {
+			cur.text += sep + text
+			return
+		}
+		groups = append(groups, group{sep: sep, text: text})
+		cur = &groups[len(groups)-1]
+	}
+	for i, part := range parts {
+		sep := seps[i]
+		for len(part) > maxLength {
+			head, rest, gap := cut(part, maxLength)
+			add(sep, head)
+			part, sep = rest, gap
+		}
+		add(sep, part)
+	}
+	if len(groups) > 0 {
+		groups[0].sep = ""
+	}
+	return groups
+}
+
+// cut splits text into a head of at most maxLength bytes and the rest,
+// preferring the last space, and returns the text that was between them
+func cut(text string, maxLength int) (string, string, string) {
+	end := maxLength
+	for end > 0 && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	if i := strings.LastIndexByte(text[:end], ' '); i > 0 {
+		return text[:i], text[i+1:], " "
+	}
+	if end == 0 {
+		_, size := utf8.DecodeRuneInString(text)
+		end = size
+	}
+	return text[:end], text[end:], ""
+}
+
+// pageMarker is the line starting a page
+func pageMarker(number int, output Output) string {
+	if output == PlainText {
+		return "--- Page " + strconv.Itoa(number) + " ---"
+	}
+	return "<!-- page " + strconv.Itoa(number) + " -->"
+}
+
+// Format writes the blocks of the page as Markdown or plain text, without
+// the page marker
+func (p Page) Format(output Output) string {
+	parts, seps := p.parts(output)
+	var b strings.Builder
+	for i, part := range parts {
+		b.WriteString(seps[i] + part)
+	}
+	return b.String()
+}
+
+// parts formats each block of the page, along with the separator that
+// goes before it: a blank line, or a line break between list items
+func (p Page) parts(output Output) ([]string, []string) {
+	var parts, seps []string
+	for i, block := range p.Blocks {
+		sep := ""
+		if i > 0 {
+			if block.Kind == ListItem && p.Blocks[i-1].Kind == ListItem {
+				sep = "\n"
+			} else {
+				sep = "\n\n"
+			}
+		}
+		seps = append(seps, sep)
+		if output == PlainText {
+			if block.Kind == ListItem {
+				parts = append(parts, "• "+block.Text)
+			} else {
+				parts = append(parts, block.Text)
+			}
+			continue
+		}
+		switch block.Kind {
+		case Heading:
+			parts = append(parts, "## "+block.Text)
+		case ListItem:
+			parts = append(parts, "- "+markdownEscape(block.Text))
+		default:
+			parts = append(parts, markdownEscape(block.Text))
+		}
+	}
+	return parts, seps
+}
+
+// markdownEscape keeps a paragraph that starts like a heading or a quote
+// from being read as one
+func markdownEscape(text string) string {
+	if strings.HasPrefix(text, "#") || strings.HasPrefix(text, ">") {
+		return `\` + text
+	}
+	return text
+}
+
+// glyph is a piece of text placed on the page, with its position in the
+// content stream to keep the order of glyphs the reader could not measure
+type glyph struct {
+	pdf.Text
+	seq int
+}
+
+// line is a run of glyphs sharing a baseline. Lines of columns that share
+// a baseline are separate.
+type line struct {
+	x0, x1 float64
+	y      float64
+	size   float64
+	text   string
+}
+
+// bodySize is the font size of most of the text of the document
+func bodySize(pages [][]pdf.Text) float64 {
+	counts := map[float64]int{}
+	for _, page := range pages {
+		for _, t := range page {
+			if strings.TrimSpace(t.S) != "" {
+				counts[math.Round(math.Abs(t.FontSize)*2)/2] += utf8.RuneCountInString(t.S)
+			}
+		}
+	}
+	var size float64
+	for s, n := range counts {
+		if n > counts[size] || n == counts[size] && s < size {
+			size = s
+		}
+	}
+	return size
+}
+
+// lines groups the glyphs of a page into lines from the top of the page,
+// each line ordered left to right
+func lines(texts []pdf.Text) []line {
+	glyphs := make([]glyph, 0, len(texts))
+	for i, t := range texts {
+		if t.S != "" {
+			t.FontSize = math.Abs(t.FontSize)
+			glyphs = append(glyphs, glyph{t, i})
+		}
+	}
+	slices.SortStableFunc(glyphs, func(a, b glyph) int {
+		return cmp.Compare(b.Y, a.Y)
+	})
+
+	var out []line
+	for start := 0; start < len(glyphs); {
+		// Glyphs within half a font size of the first one share its line,
+		// which keeps superscripts and mixed fonts together
+		top := glyphs[start]
+		end := start + 1
+		for end < len(glyphs) && top.Y-glyphs[end].Y <= max(top.FontSize, glyphs[end].FontSize, 1)/2 {
+			end++
+		}
+		row := glyphs[start:end]
+		slices.SortFunc(row, func(a, b glyph) int {
+			if c := cmp.Compare(a.X, b.X); c != 0 {
+				return c
+			}
+			return a.seq - b.seq
+		})
+		out = append(out, split(row, top.Y)...)
+		start = end
+	}
+	return out
+}
+
+// split turns a row of glyphs into lines, starting a new one at gaps too
+// wide to be spaces between words
+func split(row []glyph, y float64) []line {
+	var (
+		out  []line
+		b    strings.Builder
+		cur  line
+		prev *glyph
+	)
+	flush := func() {
+		if text := strings.TrimSpace(b.String()); text != "" {
+			cur.text = text
+			out = append(out, cur)
+		}
+		b.Reset()
+	}
+	for i := range row {
+		g := &row[i]
+		size := max(g.FontSize, 1)
+		if prev != nil {
+			gap := g.X - (prev.X + prev.W)
+			switch {
+			case gap > 3*size:
+				flush()
+				prev = nil
+			case gap > size/5 && !strings.HasSuffix(prev.S, " ") && !strings.HasPrefix(g.S, " "):
+				b.WriteByte(' ')
+			}
+		}
+		if prev == nil {
+			cur = line{x0: g.X, y: y}
+		}
+		if strings.TrimSpace(g.S) != "" {
+			cur.size = max(cur.size, g.FontSize)
+		}
+		cur.x1 = max(cur.x1, g.X+g.W)
+		b.WriteString(g.S)
+		prev = g
+	}
+	flush()
+	return out
+}
+
+// readingOrder puts the lines of a two-column page column by column. The
+// columns are found from a gutter near the middle of the page that no line
+// crosses.
+func readingOrder(lines []line) []line {
+	if len(lines) < 4 {
+		return lines
+	}
+	spans := make([][2]float64, len(lines))
+	left, right := math.Inf(1), math.Inf(-1)
+	for i, l := range lines {
+		spans[i] = [2]float64{l.x0, max(l.x1, l.x0)}
+		left, right = min(left, l.x0), max(right, spans[i][1])
+	}
+	slices.SortFunc(spans, func(a, b [2]float64) int { return cmp.Compare(a[0], b[0]) })
+
+	// Find the widest gap between the covered spans of the middle half
+	var gutter, width float64
+	end := spans[0][1]
+	for _, s := range spans[1:] {
+		if s[0] > end {
+			mid := (s[0] + end) / 2
+			if s[0]-end > width && mid > left+(right-left)/4 && mid < right-(right-left)/4 {
+				gutter, width = mid, s[0]-end
+			}
+		}
+		end = max(end, s[1])
+	}
+	if width < 8 {
+		return lines
+	}
+
+	var first, second []line
+	for _, l := range lines {
+		if l.x0 < gutter {
+			first = append(first, l)
+		} else {
+			second = append(second, l)
+		}
+	}
+	if len(first) < 2 || len(second) < 2 {
+		return lines
+	}
+	return append(first, second...)
+}
+
+// blocks joins lines into paragraphs, headings and list items. A block
+// ends where the space between lines grows, the font size changes or a
+// bullet starts a list item.
+func blocks(lines []line, body float64) []Block {
+	var spacing []float64
+	for i := 1; i < len(lines); i++ {
+		if gap := lines[i-1].y - lines[i].y; gap > 0 && gap < 3*max(lines[i].size, 1) {
+			spacing = append(spacing, gap)
+		}
+	}
+	var lineGap float64
+	if len(spacing) > 0 {
+		slices.Sort(spacing)
+		lineGap = spacing[len(spacing)/2]
+	}
+
+	var (
+		out  []Block
+		cur  *Block
+		size float64
+	)
+	for i, l := range lines {
+		text, item := trimBullet(l.text)
+		if cur != nil && !item {
+			prev := lines[i-1]
+			gap := prev.y - l.y
+			limit := 1.8 * max(l.size, 1)
+			if lineGap > 0 {
+				limit = min(limit, 1.4*lineGap)
+			}
+			if gap > 0 && gap <= limit && math.Abs(l.size-size) <= 0.5 {
+				cur.Text = joinLines(cur.Text, text)
+				continue
+			}
+		}
+		kind := Paragraph
+		if item {
+			kind = ListItem
+		} else if body > 0 && l.size >= 1.15*body {
+			kind = Heading
+		}
+		out = append(out, Block{Kind: kind, Text: text})
+		cur, size = &out[len(out)-1], l.size
+	}
+
+	// Headings are short; a long block in a large font is body text
+	for i := range out {
+		if out[i].Kind == Heading && utf8.RuneCountInString(out[i].Text) > 200 {
+			out[i].Kind = Paragraph
+		}
+	}
+	return out
+}
+
+// trimBullet removes the bullet starting a list item
+func trimBullet(text string) (string, bool) {
+	r, n := utf8.DecodeRuneInString(text)
+	if !strings.ContainsRune(bullets, r) {
+		return text, false
+	}
+	return strings.TrimSpace(text[n:]), true
+}
+
+// joinLines joins the next line of a paragraph. A word broken across the
+// lines loses its hyphen and a hyphenated compound keeps it. Lines of
+// Chinese or Japanese are joined without a space.
+func joinLines(text, next string) string {
+	last, n := utf8.DecodeLastRuneInString(text)
+	first, _ := utf8.DecodeRuneInString(next)
+	if before, _ := utf8.DecodeLastRuneInString(text[:len(text)-n]); last == '-' && (unicode.IsLetter(before) || unicode.IsDigit(before)) {
+		if unicode.IsLetter(before) && unicode.IsLower(first) {
+			return text[:len(text)-n] + next
+		}
+		return text + next
+	}
+	if unbroken(last) || unbroken(first) {
+		return text + next
+	}
+	return text + " " + next
+}
+
+// unbroken reports whether r belongs to a script written without spaces
+func unbroken(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana) ||
+		r >= 0x3000 && r <= 0x303f || r >= 0xff00 && r <= 0xffef
+}
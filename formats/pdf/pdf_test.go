@@ -0,0 +1,215 @@
+package pdf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/LouisLau-art/go-translator/formats/formatstest"
+)
+
+// testPDF builds a PDF with one page per content stream, set in Helvetica
+// with every character 500 units wide
+func testPDF(pages ...string) []byte {
+	widths := strings.Repeat("500 ", 95)
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding /FirstChar 32 /LastChar 126 /Widths [" + widths + "] >>",
+	}
+	var kids []string
+	for _, content := range pages {
+		n := len(objects) + 1
+		kids = append(kids, fmt.Sprintf("%d 0 R", n))
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", n+1),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content)+1, content))
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))
+
+	var b strings.Builder
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return []byte(b.String())
+}
+
+// text places lines of text, each given as x, y, size and the text
+func text(lines ...any) string {
+	var b strings.Builder
+	for i := 0; i+3 < len(lines); i += 4 {
+		fmt.Fprintf(&b, "BT /F1 %v Tf %v %v Td (%s) Tj ET\n", lines[i+2], lines[i], lines[i+1], lines[i+3])
+	}
+	return b.String()
+}
+
+var sample = testPDF(
+	text(
+		72, 720, 18, "Getting Started",
+		72, 690, 11, "This guide explains the trans-",
+		72, 676, 11, "lation of documents.",
+		72, 650, 11, "A second paragraph.",
+		72, 624, 11, "\x95 First item",
+		72, 610, 11, "\x95 Second item",
+	),
+	"",
+	text(
+		72, 720, 11, "Left column starts",
+		320, 720, 11, "Right column starts",
+		72, 706, 11, "and continues here.",
+		320, 706, 11, "and ends here.",
+	),
+)
+
+func TestExtract(t *testing.T) {
+	pages, err := Extract(sample)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 3 {
+		t.Fatalf("got %d pages, want 3", len(pages))
+	}
+	want := []Block{
+		{Heading, "Getting Started"},
+		{Paragraph, "This guide explains the translation of documents."},
+		{Paragraph, "A second paragraph."},
+		{ListItem, "First item"},
+		{ListItem, "Second item"},
+	}
+	if fmt.Sprint(pages[0].Blocks) != fmt.Sprint(want) {
+		t.Errorf("page 1 = %v, want %v", pages[0].Blocks, want)
+	}
+	if len(pages[1].Blocks) != 0 {
+		t.Errorf("page 2 = %v, want no blocks", pages[1].Blocks)
+	}
+	want = []Block{
+		{Paragraph, "Left column starts and continues here."},
+		{Paragraph, "Right column starts and ends here."},
+	}
+	if fmt.Sprint(pages[2].Blocks) != fmt.Sprint(want) {
+		t.Errorf("page 3 = %v, want %v", pages[2].Blocks, want)
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	var calls formatstest.Recorder
+	upper := calls.Upper()
+	out, stats, err := Translate(context.Background(), sample, Markdown, 0, upper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<!-- page 1 -->
+
+## GETTING STARTED
+
+THIS GUIDE EXPLAINS THE TRANSLATION OF DOCUMENTS.
+
+A SECOND PARAGRAPH.
+
+- FIRST ITEM
+- SECOND ITEM
+
+<!-- page 2 -->
+
+<!-- page 3 -->
+
+LEFT COLUMN STARTS AND CONTINUES HERE.
+
+RIGHT COLUMN STARTS AND ENDS HERE.
+`
+	if string(out) != want {
+		t.Errorf("output:\n%s\nwant:\n%s", out, want)
+	}
+	if len(calls.Segments()) != 2 || stats.Translated != 2 || stats.Skipped != 1 {
+		t.Errorf("segments = %q, stats = %+v", calls.Segments(), stats)
+	}
+
+	out, _, err = Translate(context.Background(), sample, PlainText, 0, upper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"--- Page 1 ---\n\nGETTING STARTED\n\n", "• FIRST ITEM\n• SECOND ITEM\n", "--- Page 2 ---\n\n--- Page 3 ---"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("text output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestTranslateSplitsLongPages(t *testing.T) {
+	var calls formatstest.Recorder
+	upper := calls.Upper()
+	whole, _, err := Translate(context.Background(), sample, Markdown, 0, upper)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first page is longer than the limit; its pieces are rejoined as
+	// if it had been sent whole
+	calls.Batches = nil
+	out, stats, err := Translate(context.Background(), sample, Markdown, 40, upper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != string(whole) {
+		t.Errorf("output:\n%s\nwant:\n%s", out, whole)
+	}
+	if len(calls.Segments()) <= 2 || stats.Translated != 2 {
+		t.Errorf("segments = %q, stats = %+v", calls.Segments(), stats)
+	}
+	for _, call := range calls.Segments() {
+		if len(call) > 40 {
+			t.Errorf("segment of %d bytes exceeds the limit: %q", len(call), call)
+		}
+	}
+}
+
+func TestCut(t *testing.T) {
+	for _, tt := range []struct {
+		text            string
+		max             int
+		head, rest, sep string
+	}{
+		{"one two three", 8, "one two", "three", " "},
+		{"翻译文档", 7, "翻译", "文档", ""},
+		{"翻译", 2, "翻", "译", ""},
+	} {
+		head, rest, sep := cut(tt.text, tt.max)
+		if head != tt.head || rest != tt.rest || sep != tt.sep {
+			t.Errorf("cut(%q, %d) = %q, %q, %q", tt.text, tt.max, head, rest, sep)
+		}
+	}
+}
+
+func TestJoinLines(t *testing.T) {
+	for _, tc := range []struct{ text, next, want string }{
+		{"a trans-", "lation", "a translation"},
+		{"well-", "Known", "well-Known"},
+		{"pages 3-", "5", "pages 3-5"},
+		{"a -", "b", "a - b"},
+		{"文档的", "翻译", "文档的翻译"},
+		{"end.", "Next", "end. Next"},
+	} {
+		if got := joinLines(tc.text, tc.next); got != tc.want {
+			t.Errorf("joinLines(%q, %q) = %q, want %q", tc.text, tc.next, got, tc.want)
+		}
+	}
+}
+
+func TestExtractRejectsInvalid(t *testing.T) {
+	for _, data := range [][]byte{[]byte("not a pdf"), testPDF(text(72, 720, 11, "x"))[:200]} {
+		if _, err := Extract(data); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Extract(%.20q) error = %v, want ErrInvalid", data, err)
+		}
+	}
+}
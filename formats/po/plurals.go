@@ -0,0 +1,70 @@
+package po
+
+import (
+	"fmt"
+	"strings"
+)
+
+// plural is a language's gettext Plural-Forms rule
+type plural struct {
+	nplurals int
+	forms    string
+	// one is the form used for n == 1
+	one int
+}
+
+func newPlural(nplurals int, expr string) plural {
+	return plural{nplurals: nplurals, forms: fmt.Sprintf("nplurals=%d; plural=%s;", nplurals, expr)}
+}
+
+// withOne sets the form a rule uses for n == 1, for rules where it is not
+// the first
+func (p plural) withOne(form int) plural {
+	p.one = form
+	return p
+}
+
+var (
+	pluralNone     = newPlural(1, "0")
+	pluralGermanic = newPlural(2, "(n != 1)")
+	pluralFrench   = newPlural(2, "(n > 1)")
+	pluralSlavic   = newPlural(3, "(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2)")
+)
+
+// pluralRules are the usual gettext Plural-Forms, keyed by language code
+var pluralRules = map[string]plural{
+	"zh": pluralNone, "ja": pluralNone, "ko": pluralNone, "vi": pluralNone,
+	"th": pluralNone, "id": pluralNone, "ms": pluralNone, "lo": pluralNone,
+	"my": pluralNone, "km": pluralNone,
+
+	"en": pluralGermanic, "de": pluralGermanic, "nl": pluralGermanic, "sv": pluralGermanic,
+	"da": pluralGermanic, "nb": pluralGermanic, "nn": pluralGermanic, "no": pluralGermanic,
+	"es": pluralGermanic, "it": pluralGermanic, "pt": pluralGermanic, "el": pluralGermanic,
+	"fi": pluralGermanic, "et": pluralGermanic, "hu": pluralGermanic, "tr": pluralGermanic,
+	"bg": pluralGermanic, "he": pluralGermanic, "ca": pluralGermanic, "hi": pluralGermanic,
+
+	"fr": pluralFrench, "pt-br": pluralFrench,
+
+	"ru": pluralSlavic, "uk": pluralSlavic, "be": pluralSlavic, "sr": pluralSlavic,
+	"hr": pluralSlavic, "bs": pluralSlavic,
+	"pl": newPlural(3, "(n==1 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2)"),
+	"cs": newPlural(3, "(n==1) ? 0 : (n>=2 && n<=4) ? 1 : 2"),
+	"sk": newPlural(3, "(n==1) ? 0 : (n>=2 && n<=4) ? 1 : 2"),
+	"ro": newPlural(3, "(n==1 ? 0 : (n==0 || (n%100 > 0 && n%100 < 20)) ? 1 : 2)"),
+	"lt": newPlural(3, "(n%10==1 && n%100!=11 ? 0 : n%10>=2 && (n%100<10 || n%100>=20) ? 1 : 2)"),
+	"ar": newPlural(6, "(n==0 ? 0 : n==1 ? 1 : n==2 ? 2 : n%100>=3 && n%100<=10 ? 3 : n%100>=11 ? 4 : 5)").withOne(1),
+}
+
+// pluralRule returns the rule for a language such as "zh-Hant" or
+// "pt_BR", falling back to its base language and then to two forms
+func pluralRule(lang string) plural {
+	lang = strings.ToLower(strings.ReplaceAll(lang, "_", "-"))
+	if rule, ok := pluralRules[lang]; ok {
+		return rule
+	}
+	base, _, _ := strings.Cut(lang, "-")
+	if rule, ok := pluralRules[base]; ok {
+		return rule
+	}
+	return pluralGermanic
+}
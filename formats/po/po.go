@@ -0,0 +1,530 @@
+// Package po reads, translates and writes gettext PO and POT catalogs.
+// Only untranslated and fuzzy entries are translated; everything else is
+// written back as it was read.
+package po
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/LouisLau-art/go-translator/formats"
+	"github.com/LouisLau-art/go-translator/placeholder"
+)
+
+// ContentType is the MIME type of PO files
+const ContentType = "text/x-gettext-translation; charset=utf-8"
+
+// ErrInvalid is returned for catalogs that cannot be parsed
+var ErrInvalid = errors.New("not a valid PO file")
+
+// Entry is one message of a catalog
+type Entry struct {
+	// Comments holds the comment lines as written, including the flags
+	// line (#,), translator comments (# ), extracted comments (#.),
+	// references (#:) and previous strings (#|)
+	Comments []string
+	Flags    []string
+
+	Context    string
+	HasContext bool
+	ID         string
+	Plural     string // msgid_plural, empty for singular messages
+	Strs       []string
+
+	// raw is the entry as written, used while it is unchanged; keys holds
+	// the msgctxt, msgid and msgid_plural lines
+	raw     []string
+	keys    []string
+	changed bool
+}
+
+// IsHeader reports whether e is the catalog header
+func (e *Entry) IsHeader() bool {
+	return e.ID == "" && !e.HasContext && e.keys != nil
+}
+
+// HasFlag reports whether e carries flag, such as "fuzzy"
+func (e *Entry) HasFlag(flag string) bool {
+	for _, f := range e.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// Untranslated reports whether no msgstr of e has text
+func (e *Entry) Untranslated() bool {
+	for _, s := range e.Strs {
+		if s != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Catalog is a parsed PO or POT file. Blocks without a message, such as
+// obsolete (#~) entries and leading comments, are kept verbatim.
+type Catalog struct {
+	Entries []*Entry
+}
+
+// Header returns the header entry, or nil
+func (c *Catalog) Header() *Entry {
+	for _, e := range c.Entries {
+		if e.IsHeader() {
+			return e
+		}
+	}
+	return nil
+}
+
+// Parse reads a PO or POT file
+func Parse(data []byte) (*Catalog, error) {
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	c := &Catalog{}
+	var block []string
+	lineNo := 0
+	flush := func() error {
+		if len(block) == 0 {
+			return nil
+		}
+		e, err := parseEntry(block)
+		if err != nil {
+			return fmt.Errorf("%w: line %d: %v", ErrInvalid, lineNo, err)
+		}
+		c.Entries = append(c.Entries, e)
+		block = nil
+		return nil
+	}
+	for _, line := range strings.Split(text, "\n") {
+		lineNo++
+		if strings.TrimSpace(line) == "" {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		block = append(block, line)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+var msgstrIndex = regexp.MustCompile(`^msgstr\[(\d+)\]$`)
+
+func parseEntry(lines []string) (*Entry, error) {
+	e := &Entry{raw: lines}
+	var (
+		target *string // string the continuation lines append to
+		inKeys = true
+	)
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "#~"):
+			// Obsolete entries are kept verbatim
+			e.Comments = append(e.Comments, line)
+			target = nil
+			continue
+		case strings.HasPrefix(trimmed, "#"):
+			e.Comments = append(e.Comments, line)
+			if strings.HasPrefix(trimmed, "#,") {
+				for _, f := range strings.Split(trimmed[2:], ",") {
+					if f = strings.TrimSpace(f); f != "" {
+						e.Flags = append(e.Flags, f)
+					}
+				}
+			}
+			continue
+		case strings.HasPrefix(trimmed, `"`):
+			if target == nil {
+				return nil, fmt.Errorf("unexpected string %s", trimmed)
+			}
+			s, err := unquote(trimmed)
+			if err != nil {
+				return nil, err
+			}
+			*target += s
+			if inKeys {
+				e.keys = append(e.keys, line)
+			}
+			continue
+		}
+
+		keyword, value, ok := strings.Cut(trimmed, " ")
+		if !ok {
+			return nil, fmt.Errorf("invalid line %q", trimmed)
+		}
+		s, err := unquote(strings.TrimSpace(value))
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case keyword == "msgctxt":
+			e.Context, e.HasContext = s, true
+			target = &e.Context
+		case keyword == "msgid":
+			e.ID = s
+			target = &e.ID
+		case keyword == "msgid_plural":
+			e.Plural = s
+			target = &e.Plural
+		case keyword == "msgstr":
+			e.Strs = []string{s}
+			target = &e.Strs[0]
+		case msgstrIndex.MatchString(keyword):
+			i, _ := strconv.Atoi(msgstrIndex.FindStringSubmatch(keyword)[1])
+			if i != len(e.Strs) {
+				return nil, fmt.Errorf("%s out of order", keyword)
+			}
+			e.Strs = append(e.Strs, s)
+			target = &e.Strs[i]
+		default:
+			return nil, fmt.Errorf("unknown keyword %q", keyword)
+		}
+		inKeys = !strings.HasPrefix(keyword, "msgstr")
+		if inKeys {
+			e.keys = append(e.keys, line)
+		}
+	}
+	if e.keys != nil && e.Strs == nil {
+		return nil, errors.New("entry has no msgstr")
+	}
+	return e, nil
+}
+
+// unquote decodes a C-style quoted string
+func unquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", fmt.Errorf("invalid string %s", s)
+	}
+	s = s[1 : len(s)-1]
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'v':
+			b.WriteByte('\v')
+		default:
+			// \\, \" and anything unknown stand for the character itself
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+
+// formatString renders keyword and s, breaking the string after each
+// newline the way gettext tools do
+func formatString(keyword, s string) []string {
+	parts := strings.SplitAfter(s, "\n")
+	if parts[len(parts)-1] == "" {
+		parts = parts[:len(parts)-1]
+	}
+	if len(parts) <= 1 {
+		return []string{keyword + ` "` + escaper.Replace(s) + `"`}
+	}
+	lines := []string{keyword + ` ""`}
+	for _, p := range parts {
+		lines = append(lines, `"`+escaper.Replace(p)+`"`)
+	}
+	return lines
+}
+
+// messageContext returns the msgctxt of e followed by its extracted
+// comments, one per line
+func (e *Entry) messageContext() string {
+	var parts []string
+	if e.Context != "" {
+		parts = append(parts, e.Context)
+	}
+	for _, c := range e.Comments {
+		if comment, ok := strings.CutPrefix(strings.TrimSpace(c), "#."); ok {
+			if comment = strings.TrimSpace(comment); comment != "" {
+				parts = append(parts, comment)
+			}
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// lines renders e, reusing the original text when e is unchanged
+func (e *Entry) lines() []string {
+	if !e.changed {
+		return e.raw
+	}
+
+	var out []string
+	flags := "#, " + strings.Join(e.Flags, ", ")
+	wroteFlags := len(e.Flags) == 0
+	for _, c := range e.Comments {
+		trimmed := strings.TrimSpace(c)
+		switch {
+		case strings.HasPrefix(trimmed, "#,"):
+			if !wroteFlags {
+				out = append(out, flags)
+				wroteFlags = true
+			}
+			continue
+		case strings.HasPrefix(trimmed, "#|") && !wroteFlags:
+			// Flags go before the previous strings
+			out = append(out, flags)
+			wroteFlags = true
+		}
+		out = append(out, c)
+	}
+	if !wroteFlags {
+		out = append(out, flags)
+	}
+
+	out = append(out, e.keys...)
+	if e.Plural == "" {
+		out = append(out, formatString("msgstr", e.Strs[0])...)
+	} else {
+		for i, s := range e.Strs {
+			out = append(out, formatString(fmt.Sprintf("msgstr[%d]", i), s)...)
+		}
+	}
+	return out
+}
+
+// Bytes renders the catalog
+func (c *Catalog) Bytes() []byte {
+	var b strings.Builder
+	for i, e := range c.Entries {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		for _, line := range e.lines() {
+			b.WriteString(line + "\n")
+		}
+	}
+	return []byte(b.String())
+}
+
+// Translate translates the untranslated and fuzzy entries of a catalog
+// into target. Translated entries are flagged fuzzy for review, plural
+// entries get the number of forms the target language uses, and the
+// header's Language and Plural-Forms are set. Messages are translated with
+// their msgctxt and extracted comments (#.) as message context, so
+// identical msgids in different contexts may translate differently.
+func Translate(ctx context.Context, data []byte, target string, translate formats.TranslateFunc) ([]byte, formats.Stats, error) {
+	var stats formats.Stats
+	c, err := Parse(data)
+	if err != nil {
+		return nil, stats, err
+	}
+	rule := c.setHeader(target)
+
+	// Each entry contributes its singular and, for plurals that English
+	// forms can fill, its plural text. Segments are grouped by message
+	// context.
+	type pending struct {
+		entry *Entry
+		texts []*maskedText
+	}
+	var (
+		work     []pending
+		segments []string
+		groups   = map[string][]int{}
+		contexts []string
+	)
+	for _, e := range c.Entries {
+		if e.keys == nil || e.IsHeader() || !(e.Untranslated() || e.HasFlag("fuzzy")) {
+			continue
+		}
+		texts := []string{e.ID}
+		if e.Plural != "" && rule.nplurals <= 2 {
+			texts = append(texts, e.Plural)
+		}
+		p := pending{entry: e}
+		for _, text := range texts {
+			m := mask(text)
+			if m.translate {
+				m.segment = len(segments)
+				message := e.messageContext()
+				if _, ok := groups[message]; !ok {
+					contexts = append(contexts, message)
+				}
+				groups[message] = append(groups[message], len(segments))
+				segments = append(segments, m.masked)
+			}
+			p.texts = append(p.texts, m)
+		}
+		work = append(work, p)
+	}
+
+	translations := make([]string, len(segments))
+	for _, message := range contexts {
+		indexes := groups[message]
+		batch := make([]string, len(indexes))
+		for i, index := range indexes {
+			batch[i] = segments[index]
+		}
+		out, err := translate(formats.WithMessageContext(ctx, message), batch)
+		if err != nil {
+			return nil, stats, err
+		}
+		if len(out) != len(batch) {
+			return nil, stats, fmt.Errorf("got %d translations for %d messages", len(out), len(batch))
+		}
+		for i, index := range indexes {
+			translations[index] = out[i]
+		}
+	}
+
+	for _, p := range work {
+		texts := make([]string, len(p.texts))
+		ok := true
+		for i, m := range p.texts {
+			texts[i], ok = m.restore(translations)
+			if !ok {
+				break
+			}
+		}
+		if !ok {
+			stats.Skipped++
+			continue
+		}
+
+		e := p.entry
+		if e.Plural == "" {
+			e.Strs = []string{texts[0]}
+		} else {
+			e.Strs = pluralForms(texts, rule)
+		}
+		if !e.HasFlag("fuzzy") {
+			e.Flags = append(e.Flags, "fuzzy")
+		}
+		e.changed = true
+		stats.Translated++
+	}
+	return c.Bytes(), stats, nil
+}
+
+// pluralForms fills the msgstr forms of rule from the translated singular
+// and, when there is one, plural text. Languages with one form use the
+// plural text and those with two use both. Languages with more forms split
+// the English plural by number (few, many, ...), which one translation
+// cannot fill, so only the form for n == 1 is set and the others are left
+// for a reviewer.
+func pluralForms(texts []string, rule plural) []string {
+	if rule.nplurals == 1 {
+		return []string{texts[1]}
+	}
+	forms := make([]string, rule.nplurals)
+	for i := range forms {
+		if i != rule.one && len(texts) > 1 {
+			forms[i] = texts[1]
+		}
+	}
+	forms[rule.one] = texts[0]
+	return forms
+}
+
+// maskedText is a msgid prepared for translation: surrounding whitespace
+// is set aside and placeholders are masked
+type maskedText struct {
+	original     string
+	lead, trail  string
+	masked       string
+	placeholders []string
+	translate    bool
+	segment      int
+}
+
+func mask(text string) *maskedText {
+	core := strings.TrimSpace(text)
+	start := strings.Index(text, core)
+	m := &maskedText{original: text, lead: text[:start], trail: text[start+len(core):]}
+	m.masked, m.placeholders, m.translate = placeholder.Mask(core)
+	return m
+}
+
+// restore returns the translation with its placeholders and whitespace
+// put back, or the original when there was nothing to translate
+func (m *maskedText) restore(translations []string) (string, bool) {
+	if !m.translate {
+		return m.original, true
+	}
+	text, ok := placeholder.Unmask(strings.TrimSpace(translations[m.segment]), m.placeholders)
+	return m.lead + text + m.trail, ok
+}
+
+var pluralsRe = regexp.MustCompile(`nplurals\s*=\s*(\d+)`)
+
+// setHeader sets the header's Language and, unless the catalog already
+// declares one, its Plural-Forms for target. It returns the plural rule,
+// adding a header when the catalog has none.
+func (c *Catalog) setHeader(target string) plural {
+	h := c.Header()
+	if h == nil {
+		h = &Entry{keys: []string{`msgid ""`}, Strs: []string{"Content-Type: text/plain; charset=UTF-8\n"}}
+		c.Entries = append([]*Entry{h}, c.Entries...)
+	}
+	h.changed = true
+
+	rule := pluralRule(target)
+	header := setHeaderField(h.Strs[0], "Language", target)
+	if m := pluralsRe.FindStringSubmatch(headerField(header, "Plural-Forms")); m != nil {
+		if n, err := strconv.Atoi(m[1]); err == nil && n > 0 {
+			h.Strs[0] = header
+			if n != rule.nplurals {
+				// An unknown rule is taken to use the first form for one
+				rule = plural{nplurals: n}
+			}
+			return rule
+		}
+	}
+	h.Strs[0] = setHeaderField(header, "Plural-Forms", rule.forms)
+	return rule
+}
+
+func headerField(header, key string) string {
+	for _, line := range strings.Split(header, "\n") {
+		if k, v, ok := strings.Cut(line, ":"); ok && strings.EqualFold(strings.TrimSpace(k), key) {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+func setHeaderField(header, key, value string) string {
+	lines := strings.Split(strings.TrimSuffix(header, "\n"), "\n")
+	for i, line := range lines {
+		if k, _, ok := strings.Cut(line, ":"); ok && strings.EqualFold(strings.TrimSpace(k), key) {
+			lines[i] = key + ": " + value
+			return strings.Join(lines, "\n") + "\n"
+		}
+	}
+	if len(lines) == 1 && lines[0] == "" {
+		lines = nil
+	}
+	return strings.Join(append(lines, key+": "+value), "\n") + "\n"
+}
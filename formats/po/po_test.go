@@ -0,0 +1,183 @@
+package po
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/LouisLau-art/go-translator/formats"
+	"github.com/LouisLau-art/go-translator/formats/formatstest"
+)
+
+const testPOT = `# Translation template
+# Copyright (C) 2024
+#
+#, fuzzy
+msgid ""
+msgstr ""
+"Project-Id-Version: demo\n"
+"Language: \n"
+"Plural-Forms: nplurals=INTEGER; plural=EXPRESSION;\n"
+"Content-Type: text/plain; charset=UTF-8\n"
+
+# Shown on the toolbar
+#: main.go:10
+msgctxt "toolbar"
+msgid "Open"
+msgstr ""
+
+#: main.go:12
+#, c-format
+msgid "Hello %s, welcome\n"
+msgstr ""
+
+#: main.go:14
+msgid "Save"
+msgstr "保存"
+
+#, fuzzy
+#| msgid "Close file"
+msgid "Close"
+msgstr "关闭文件"
+
+msgid "%d file"
+msgid_plural "%d files"
+msgstr[0] ""
+msgstr[1] ""
+
+msgid "%s: %d"
+msgstr ""
+
+#~ msgid "Old"
+#~ msgstr "旧"
+`
+
+func TestTranslate(t *testing.T) {
+	var calls formatstest.Recorder
+	out, stats, err := Translate(context.Background(), []byte(testPOT), "zh", calls.Wrap("T(", ")"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantCalls := []string{"Open", "Hello {0}, welcome", "Close", "{0} file", "{0} files"}
+	if strings.Join(calls.Segments(), "|") != strings.Join(wantCalls, "|") {
+		t.Errorf("segments = %q, want %q", calls.Segments(), wantCalls)
+	}
+	if stats.Translated != 5 || stats.Skipped != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	for _, want := range []string{
+		"\"Language: zh\\n\"\n\"Plural-Forms: nplurals=1; plural=0;\\n\"",
+		"# Shown on the toolbar\n#: main.go:10\n#, fuzzy\nmsgctxt \"toolbar\"\nmsgid \"Open\"\nmsgstr \"T(Open)\"",
+		"#, c-format, fuzzy\nmsgid \"Hello %s, welcome\\n\"\nmsgstr \"T(Hello %s, welcome)\\n\"",
+		"msgid \"Save\"\nmsgstr \"保存\"",
+		"#, fuzzy\n#| msgid \"Close file\"\nmsgid \"Close\"\nmsgstr \"T(Close)\"",
+		"msgid_plural \"%d files\"\nmsgstr[0] \"T(%d files)\"\n\n",
+		"msgid \"%s: %d\"\nmsgstr \"%s: %d\"",
+		"#~ msgid \"Old\"\n#~ msgstr \"旧\"\n",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	// The output parses again
+	if _, err := Parse(out); err != nil {
+		t.Errorf("output does not parse: %v", err)
+	}
+}
+
+func TestTranslatePluralForms(t *testing.T) {
+	input := "msgid \"%d file\"\nmsgid_plural \"%d files\"\nmsgstr[0] \"\"\nmsgstr[1] \"\"\n"
+	for _, tt := range []struct {
+		target string
+		calls  []string
+		want   []string
+	}{
+		{"de", []string{"{0} file", "{0} files"}, []string{
+			`"Plural-Forms: nplurals=2;`,
+			"msgstr[0] \"T(%d file)\"\nmsgstr[1] \"T(%d files)\"\n",
+		}},
+		// The English plural cannot fill the few and many forms, which are
+		// left for a reviewer
+		{"ru", []string{"{0} file"}, []string{
+			`"Plural-Forms: nplurals=3;`,
+			"msgstr[0] \"T(%d file)\"\nmsgstr[1] \"\"\nmsgstr[2] \"\"\n",
+		}},
+		// Arabic uses its second form for one
+		{"ar", []string{"{0} file"}, []string{
+			`"Plural-Forms: nplurals=6;`,
+			"msgstr[0] \"\"\nmsgstr[1] \"T(%d file)\"\nmsgstr[2] \"\"\n",
+		}},
+	} {
+		var calls formatstest.Recorder
+		out, stats, err := Translate(context.Background(), []byte(input), tt.target, calls.Wrap("T(", ")"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(calls.Segments(), "|") != strings.Join(tt.calls, "|") || stats.Translated != 1 {
+			t.Errorf("%s: segments = %q, stats = %+v", tt.target, calls.Segments(), stats)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(string(out), want) {
+				t.Errorf("%s: output missing %q:\n%s", tt.target, want, out)
+			}
+		}
+	}
+}
+
+func TestTranslatePassesMessageContext(t *testing.T) {
+	input := "msgctxt \"verb\"\nmsgid \"Open\"\nmsgstr \"\"\n\n" +
+		"msgctxt \"adjective\"\nmsgid \"Open\"\nmsgstr \"\"\n\n" +
+		"#. Toolbar button\nmsgctxt \"verb\"\nmsgid \"Close\"\nmsgstr \"\"\n\n" +
+		"msgid \"Save\"\nmsgstr \"\"\n"
+	translate := func(ctx context.Context, segments []string) ([]string, error) {
+		out := make([]string, len(segments))
+		for i, s := range segments {
+			out[i] = s + "@" + formats.MessageContext(ctx)
+		}
+		return out, nil
+	}
+	out, _, err := Translate(context.Background(), []byte(input), "zh", translate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"msgctxt \"verb\"\nmsgid \"Open\"\nmsgstr \"Open@verb\"",
+		"msgctxt \"adjective\"\nmsgid \"Open\"\nmsgstr \"Open@adjective\"",
+		"msgid \"Close\"\nmsgstr \"\"\n\"Close@verb\\n\"\n\"Toolbar button\"",
+		"msgid \"Save\"\nmsgstr \"Save@\"",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestTranslateSkipsBrokenPlaceholders(t *testing.T) {
+	input := "msgid \"Hello %s\"\nmsgstr \"\"\n"
+	drop := func(_ context.Context, segments []string) ([]string, error) {
+		return []string{"Bonjour"}, nil
+	}
+	out, stats, err := Translate(context.Background(), []byte(input), "fr", drop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Skipped != 1 || !strings.Contains(string(out), "msgid \"Hello %s\"\nmsgstr \"\"\n") {
+		t.Errorf("stats = %+v, output:\n%s", stats, out)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, input := range []string{
+		"msgid \"unterminated\nmsgstr \"\"",
+		"msgid \"x\"",
+		"msgstr[1] \"x\"",
+		"bogus \"x\"",
+	} {
+		if _, err := Parse([]byte(input)); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", input)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package resource
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// androidFrame is an open element of a strings.xml file
+type androidFrame struct {
+	name      string
+	key       string // name attribute of <string-array> and <plurals>
+	translate bool
+	items     int
+}
+
+// parseAndroid finds the <string> values and the <item>s of
+// <string-array> and <plurals> in an Android resources file. Resources
+// marked translatable="false" and references such as @string/name are
+// skipped.
+func parseAndroid(data []byte) ([]entry, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var (
+		stack   []*androidFrame
+		entries []entry
+		root    bool
+	)
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		end := int(dec.InputOffset())
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			f := &androidFrame{name: t.Name.Local, key: attr(t, "name"), translate: true}
+			if len(stack) == 0 {
+				if root || f.name != "resources" {
+					return nil, fmt.Errorf("%w: unexpected root element <%s>", ErrInvalid, f.name)
+				}
+				root = true
+			}
+			if len(stack) > 0 {
+				f.translate = stack[len(stack)-1].translate
+			}
+			if attr(t, "translatable") == "false" {
+				f.translate = false
+			}
+
+			var key string
+			switch {
+			case len(stack) == 1 && f.name == "string":
+				key = f.key
+			case len(stack) == 2 && f.name == "item" && stack[1].name == "string-array":
+				key = stack[1].key + "[" + strconv.Itoa(stack[1].items) + "]"
+				stack[1].items++
+			case len(stack) == 2 && f.name == "item" && stack[1].name == "plurals":
+				key = stack[1].key + "[" + attr(t, "quantity") + "]"
+			}
+			if key == "" {
+				stack = append(stack, f)
+				continue
+			}
+
+			innerEnd, err := skipElement(dec)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+			}
+			if !f.translate {
+				continue
+			}
+			if value, encode, ok := androidValue(data[end:innerEnd]); ok {
+				entries = append(entries, entry{key: key, value: value, start: end, end: innerEnd, encode: encode})
+			}
+		case xml.EndElement:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("%w: unexpected </%s>", ErrInvalid, t.Name.Local)
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+	if !root || len(stack) > 0 {
+		return nil, fmt.Errorf("%w: missing or unclosed <resources>", ErrInvalid)
+	}
+	return entries, nil
+}
+
+func attr(t xml.StartElement, name string) string {
+	for _, a := range t.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// skipElement consumes the rest of the element whose start tag was just
+// read and returns the offset where its end tag starts
+func skipElement(dec *xml.Decoder) (int, error) {
+	for depth := 1; ; {
+		offset := int(dec.InputOffset())
+		tok, err := dec.RawToken()
+		if err != nil {
+			return 0, err
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			if depth--; depth == 0 {
+				return offset, nil
+			}
+		}
+	}
+}
+
+// androidMarker stands for a piece of markup in a decoded value
+var androidMarker = regexp.MustCompile(`<x(\d+)/>`)
+
+// androidValue decodes the content of a string resource. Markup, such as
+// <b> or <xliff:g>, becomes <xN/> markers, which placeholder masking keeps
+// out of translation; the encoder puts the original markup back. It
+// reports false for references and content it cannot round-trip.
+func androidValue(raw []byte) (string, func(string) string, bool) {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) > 0 && (trimmed[0] == '@' || trimmed[0] == '?') {
+		return "", nil, false
+	}
+	quoted := len(trimmed) >= 2 && trimmed[0] == '"' && trimmed[len(trimmed)-1] == '"'
+
+	dec := xml.NewDecoder(bytes.NewReader(raw))
+	var (
+		b        strings.Builder
+		markup   []string
+		inQuotes bool
+	)
+	for {
+		start := int(dec.InputOffset())
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", nil, false
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			b.WriteString(androidUnescape(string(t), &inQuotes))
+		case xml.StartElement:
+			if t.Name.Space == "xliff" && t.Name.Local == "g" {
+				// Content marked with <xliff:g> must not be translated
+				if _, err := skipElement(dec); err != nil {
+					return "", nil, false
+				}
+			}
+			fmt.Fprintf(&b, "<x%d/>", len(markup))
+			markup = append(markup, string(raw[start:int(dec.InputOffset())]))
+		case xml.EndElement:
+			fmt.Fprintf(&b, "<x%d/>", len(markup))
+			markup = append(markup, string(raw[start:int(dec.InputOffset())]))
+		default:
+			return "", nil, false
+		}
+	}
+
+	encode := func(value string) string {
+		var out strings.Builder
+		pos := 0
+		for _, m := range androidMarker.FindAllStringSubmatchIndex(value, -1) {
+			n, _ := strconv.Atoi(value[m[2]:m[3]])
+			if n >= len(markup) {
+				continue
+			}
+			out.WriteString(androidEscape(value[pos:m[0]], quoted, pos == 0))
+			out.WriteString(markup[n])
+			pos = m[1]
+		}
+		out.WriteString(androidEscape(value[pos:], quoted, pos == 0))
+		if quoted {
+			return `"` + out.String() + `"`
+		}
+		return out.String()
+	}
+	return b.String(), encode, true
+}
+
+// androidUnescape resolves the backslash escapes and double quotes of
+// Android string resources. Outside quotes, runs of whitespace collapse
+// to one space as they do on the device.
+func androidUnescape(s string, inQuotes *bool) string {
+	var b strings.Builder
+	space := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if r, err := strconv.ParseUint(s[i+1:min(i+5, len(s))], 16, 32); err == nil && i+5 <= len(s) {
+					b.WriteRune(rune(r))
+					i += 4
+				} else {
+					b.WriteByte('u')
+				}
+			default:
+				b.WriteByte(s[i])
+			}
+			space = false
+		case c == '"':
+			*inQuotes = !*inQuotes
+		case !*inQuotes && (c == ' ' || c == '\t' || c == '\n' || c == '\r'):
+			if !space {
+				b.WriteByte(' ')
+			}
+			space = true
+			continue
+		default:
+			b.WriteByte(c)
+		}
+		space = false
+	}
+	return b.String()
+}
+
+// androidEscape writes text as string resource content. Inside a quoted
+// value apostrophes and whitespace need no escaping.
+func androidEscape(s string, quoted, first bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '\\' || r == '"':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\'' && !quoted:
+			b.WriteString(`\'`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case (r == '@' || r == '?') && i == 0 && first && !quoted:
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '&':
+			b.WriteString("&amp;")
+		case r == '<':
+			b.WriteString("&lt;")
+		case r == '>':
+			b.WriteString("&gt;")
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
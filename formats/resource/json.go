@@ -0,0 +1,140 @@
+package resource
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonScanner finds the string values of a JSON document. The document is
+// validated first, so the scanner only has to find token boundaries.
+type jsonScanner struct {
+	data    []byte
+	pos     int
+	entries []entry
+}
+
+func parseJSON(data []byte) ([]entry, error) {
+	if !json.Valid(data) {
+		var v any
+		err := json.Unmarshal(data, &v)
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	s := &jsonScanner{data: data}
+	if err := s.value(""); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	return s.entries, nil
+}
+
+func (s *jsonScanner) skipSpace() {
+	for s.pos < len(s.data) && strings.IndexByte(" \t\r\n", s.data[s.pos]) >= 0 {
+		s.pos++
+	}
+}
+
+func (s *jsonScanner) value(path string) error {
+	s.skipSpace()
+	switch s.data[s.pos] {
+	case '{':
+		s.pos++
+		for {
+			s.skipSpace()
+			if s.data[s.pos] == '}' {
+				s.pos++
+				return nil
+			}
+			key, err := s.string()
+			if err != nil {
+				return err
+			}
+			s.skipSpace()
+			s.pos++ // ':'
+			if err := s.value(joinKey(path, key)); err != nil {
+				return err
+			}
+			s.skipSpace()
+			if s.data[s.pos] == ',' {
+				s.pos++
+			}
+		}
+	case '[':
+		s.pos++
+		for i := 0; ; i++ {
+			s.skipSpace()
+			if s.data[s.pos] == ']' {
+				s.pos++
+				return nil
+			}
+			if err := s.value(path + "[" + strconv.Itoa(i) + "]"); err != nil {
+				return err
+			}
+			s.skipSpace()
+			if s.data[s.pos] == ',' {
+				s.pos++
+			}
+		}
+	case '"':
+		start := s.pos
+		value, err := s.string()
+		if err != nil {
+			return err
+		}
+		s.entries = append(s.entries, entry{key: path, value: value, start: start, end: s.pos, encode: jsonQuote})
+	default:
+		// Numbers, booleans and null
+		for s.pos < len(s.data) && strings.IndexByte(",]} \t\r\n", s.data[s.pos]) < 0 {
+			s.pos++
+		}
+	}
+	return nil
+}
+
+// string reads a string literal
+func (s *jsonScanner) string() (string, error) {
+	start := s.pos
+	for s.pos++; s.data[s.pos] != '"'; s.pos++ {
+		if s.data[s.pos] == '\\' {
+			s.pos++
+		}
+	}
+	s.pos++
+	var value string
+	err := json.Unmarshal(s.data[start:s.pos], &value)
+	return value, err
+}
+
+// joinKey appends a key to a dotted path
+func joinKey(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// jsonQuote writes a JSON string literal, leaving non-ASCII text and HTML
+// characters unescaped as hand-written locale files have them
+func jsonQuote(value string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range value {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == '\u2028' || r == '\u2029':
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
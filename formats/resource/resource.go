@@ -0,0 +1,235 @@
+// Package resource translates the string values of app localization files:
+// nested JSON and YAML, Android strings.xml and iOS .strings. Keys, order,
+// comments and formatting stay as written; only the bytes of translated
+// values change.
+package resource
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/LouisLau-art/go-translator/formats"
+	"github.com/LouisLau-art/go-translator/placeholder"
+)
+
+// ErrInvalid is returned for files that cannot be parsed in their format
+var ErrInvalid = errors.New("not a valid resource file")
+
+// Format is a resource file format
+type Format struct {
+	Name        string
+	ContentType string
+	parse       func(data []byte) ([]entry, error)
+}
+
+// The supported formats
+var (
+	JSON    = &Format{Name: "JSON", ContentType: "application/json; charset=utf-8", parse: parseJSON}
+	YAML    = &Format{Name: "YAML", ContentType: "application/yaml; charset=utf-8", parse: parseYAML}
+	Android = &Format{Name: "Android strings.xml", ContentType: "application/xml; charset=utf-8", parse: parseAndroid}
+	Strings = &Format{Name: "iOS .strings", ContentType: "text/plain; charset=utf-8", parse: parseStrings}
+)
+
+// FormatFor returns the format of a file name by its extension
+func FormatFor(name string) (*Format, bool) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return JSON, true
+	case ".yaml", ".yml":
+		return YAML, true
+	case ".xml":
+		return Android, true
+	case ".strings":
+		return Strings, true
+	}
+	return nil, false
+}
+
+// entry is a translatable string value of a resource file
+type entry struct {
+	key   string // path of the value, such as "home.title" or "items[2]"
+	value string // decoded value
+	start int    // byte range of the value as written
+	end   int
+	// encode writes a value in the same style as the original
+	encode func(value string) string
+}
+
+// Translate translates the string values of data. With existing, the
+// file translated previously, values whose keys already have text there
+// are copied from it and only the missing keys are translated. Values
+// whose placeholders do not survive translation keep the source text and
+// are counted as skipped.
+func Translate(ctx context.Context, data []byte, format *Format, translate formats.TranslateFunc, existing []byte) ([]byte, formats.Stats, error) {
+	var stats formats.Stats
+	text, encodeOutput, err := decodeText(data)
+	if err != nil {
+		return nil, stats, err
+	}
+	entries, err := format.parse(text)
+	if err != nil {
+		return nil, stats, err
+	}
+
+	var done map[string]string
+	if existing != nil {
+		if done, err = translatedValues(existing, format); err != nil {
+			return nil, stats, fmt.Errorf("existing translation: %w", err)
+		}
+	}
+
+	type pending struct {
+		entry *entry
+		text  *maskedText
+	}
+	var (
+		edits    []edit
+		work     []pending
+		segments []string
+	)
+	for i := range entries {
+		e := &entries[i]
+		if value, ok := done[e.key]; ok {
+			edits = append(edits, edit{e.start, e.end, e.encode(value)})
+			continue
+		}
+		m := mask(e.value)
+		if !m.translate {
+			continue
+		}
+		m.segment = len(segments)
+		segments = append(segments, m.masked)
+		work = append(work, pending{e, m})
+	}
+
+	var translations []string
+	if len(segments) > 0 {
+		if translations, err = translate(ctx, segments); err != nil {
+			return nil, stats, err
+		}
+		if len(translations) != len(segments) {
+			return nil, stats, fmt.Errorf("got %d translations for %d values", len(translations), len(segments))
+		}
+	}
+	for _, p := range work {
+		value, ok := p.text.restore(translations)
+		if !ok {
+			stats.Skipped++
+			continue
+		}
+		edits = append(edits, edit{p.entry.start, p.entry.end, p.entry.encode(value)})
+		stats.Translated++
+	}
+
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+	var out bytes.Buffer
+	pos := 0
+	for _, e := range edits {
+		out.Write(text[pos:e.start])
+		out.WriteString(e.text)
+		pos = e.end
+	}
+	out.Write(text[pos:])
+	return encodeOutput(out.Bytes()), stats, nil
+}
+
+// translatedValues returns the non-empty values of an existing translation
+// by key
+func translatedValues(data []byte, format *Format) (map[string]string, error) {
+	text, _, err := decodeText(data)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := format.parse(text)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(entries))
+	for _, e := range entries {
+		if strings.TrimSpace(e.value) != "" {
+			values[e.key] = e.value
+		}
+	}
+	return values, nil
+}
+
+// edit replaces a byte range of the file
+type edit struct {
+	start, end int
+	text       string
+}
+
+// maskedText is a value prepared for translation: surrounding whitespace
+// is set aside and placeholders are masked
+type maskedText struct {
+	lead, trail  string
+	masked       string
+	placeholders []string
+	translate    bool
+	segment      int
+}
+
+func mask(text string) *maskedText {
+	core := strings.TrimSpace(text)
+	start := strings.Index(text, core)
+	m := &maskedText{lead: text[:start], trail: text[start+len(core):]}
+	m.masked, m.placeholders, m.translate = placeholder.Mask(core)
+	return m
+}
+
+// restore returns the translation with its placeholders and whitespace
+// put back
+func (m *maskedText) restore(translations []string) (string, bool) {
+	text, ok := placeholder.Unmask(strings.TrimSpace(translations[m.segment]), m.placeholders)
+	return m.lead + text + m.trail, ok
+}
+
+// decodeText converts data to UTF-8 without a byte order mark and returns
+// a func that converts output back to the original encoding. Xcode used
+// to write .strings files in UTF-16.
+func decodeText(data []byte) ([]byte, func([]byte) []byte, error) {
+	bigEndian := bytes.HasPrefix(data, []byte{0xfe, 0xff})
+	if !bigEndian && !bytes.HasPrefix(data, []byte{0xff, 0xfe}) {
+		if bom := "\ufeff"; bytes.HasPrefix(data, []byte(bom)) {
+			return data[len(bom):], func(out []byte) []byte { return append([]byte(bom), out...) }, nil
+		}
+		if !utf8.Valid(data) {
+			return nil, nil, fmt.Errorf("%w: not UTF-8 or UTF-16 text", ErrInvalid)
+		}
+		return data, func(out []byte) []byte { return out }, nil
+	}
+
+	if len(data)%2 != 0 {
+		return nil, nil, fmt.Errorf("%w: truncated UTF-16 text", ErrInvalid)
+	}
+	units := make([]uint16, 0, len(data)/2-1)
+	for i := 2; i < len(data); i += 2 {
+		if bigEndian {
+			units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+		} else {
+			units = append(units, uint16(data[i+1])<<8|uint16(data[i]))
+		}
+	}
+	encode := func(out []byte) []byte {
+		b := []byte{0xff, 0xfe}
+		if bigEndian {
+			b = []byte{0xfe, 0xff}
+		}
+		for _, u := range utf16.Encode([]rune(string(out))) {
+			if bigEndian {
+				b = append(b, byte(u>>8), byte(u))
+			} else {
+				b = append(b, byte(u), byte(u>>8))
+			}
+		}
+		return b
+	}
+	return []byte(string(utf16.Decode(units))), encode, nil
+}
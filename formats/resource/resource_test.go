@@ -0,0 +1,227 @@
+package resource
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/LouisLau-art/go-translator/formats/formatstest"
+)
+
+func translate(t *testing.T, format *Format, input, existing string) (string, []string) {
+	t.Helper()
+	var calls formatstest.Recorder
+	var previous []byte
+	if existing != "" {
+		previous = []byte(existing)
+	}
+	out, _, err := Translate(context.Background(), []byte(input), format, calls.Upper(), previous)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out), calls.Segments()
+}
+
+func TestTranslateJSON(t *testing.T) {
+	input := `{
+  "home": {"title": "Welcome, {name}!", "count": "{n, plural, one {# file} other {# files}}"},
+  "items": ["Open", "Save \"as\"", 3, true, null],
+  "pct": "%d%%",
+  "tags": "<b>Bold</b> & more"
+}
+`
+	out, calls := translate(t, JSON, input, "")
+	want := `{
+  "home": {"title": "WELCOME, {name}!", "count": "{n, plural, one {# FILE} other {# FILES}}"},
+  "items": ["OPEN", "SAVE \"AS\"", 3, true, null],
+  "pct": "%d%%",
+  "tags": "<b>BOLD</b> & MORE"
+}
+`
+	if out != want {
+		t.Errorf("output:\n%s\nwant:\n%s", out, want)
+	}
+	for _, c := range calls {
+		if strings.Contains(c, "name") || strings.Contains(c, "plural") || strings.Contains(c, "home") {
+			t.Errorf("key or placeholder sent for translation: %q", c)
+		}
+	}
+}
+
+func TestTranslateYAML(t *testing.T) {
+	input := `# Greetings
+en:
+  greeting: Hello, %s!   # trailing comment
+  quoted: "Say \"hi\""
+  single: 'It''s here'
+  plain_colon: Time
+  block: |
+    Line one
+    Line two
+  folded: >-
+    folded text
+  list:
+    - One
+    - Two
+  flow: {a: Alpha, b: Beta}
+  num: 42
+  anchor: &x Anchored
+  alias: *x
+`
+	out, _ := translate(t, YAML, input, "")
+	want := `# Greetings
+en:
+  greeting: HELLO, %s!   # trailing comment
+  quoted: "SAY \"HI\""
+  single: 'IT''S HERE'
+  plain_colon: TIME
+  block: |
+    LINE ONE
+    LINE TWO
+  folded: >-
+    FOLDED TEXT
+  list:
+    - ONE
+    - TWO
+  flow: {a: ALPHA, b: BETA}
+  num: 42
+  anchor: &x ANCHORED
+  alias: *x
+`
+	if out != want {
+		t.Errorf("output:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestYAMLPlainFallsBackToQuotes(t *testing.T) {
+	input := "a: note\nb: [x, y]\n"
+	out, _, err := Translate(context.Background(), []byte(input), YAML, func(_ context.Context, s []string) ([]string, error) {
+		return []string{"Note: read me", "yes", "one, two"}, nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "a: \"Note: read me\"\nb: [\"yes\", \"one, two\"]\n"; string(out) != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+}
+
+func TestTranslateAndroid(t *testing.T) {
+	input := `<?xml version="1.0" encoding="utf-8"?>
+<resources xmlns:xliff="urn:oasis:names:tc:xliff:document:1.2">
+    <!-- Main screen -->
+    <string name="app_name" translatable="false">Acme</string>
+    <string name="welcome">Don\'t <b>panic</b>, %1$s</string>
+    <string name="brand">Powered by <xliff:g id="brand">Acme Cloud</xliff:g></string>
+    <string name="ref">@string/welcome</string>
+    <string name="spaced">"  Keep  spaces  "</string>
+    <string-array name="planets">
+        <item>Mercury</item>
+        <item>Venus &amp; Mars</item>
+    </string-array>
+    <plurals name="songs">
+        <item quantity="one">%d song</item>
+        <item quantity="other">%d songs</item>
+    </plurals>
+</resources>
+`
+	out, calls := translate(t, Android, input, "")
+	for _, want := range []string{
+		`<!-- Main screen -->`,
+		`<string name="app_name" translatable="false">Acme</string>`,
+		`<string name="welcome">DON\'T <b>PANIC</b>, %1$s</string>`,
+		`<string name="brand">POWERED BY <xliff:g id="brand">Acme Cloud</xliff:g></string>`,
+		`<string name="ref">@string/welcome</string>`,
+		`<string name="spaced">"  KEEP  SPACES  "</string>`,
+		`<item>VENUS &amp; MARS</item>`,
+		`<item quantity="other">%d SONGS</item>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	for _, c := range calls {
+		if strings.Contains(c, "Acme") || strings.Contains(c, "<") {
+			t.Errorf("protected text sent for translation: %q", c)
+		}
+	}
+}
+
+func TestTranslateStrings(t *testing.T) {
+	input := `/* Title of the main window */
+"title" = "Hello \"World\"";
+// Button
+greeting = "Line\nbreak %@";
+"empty" = "";
+`
+	out, _ := translate(t, Strings, input, "")
+	want := `/* Title of the main window */
+"title" = "HELLO \"WORLD\"";
+// Button
+greeting = "LINE\nBREAK %@";
+"empty" = "";
+`
+	if out != want {
+		t.Errorf("output:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestTranslateStringsUTF16(t *testing.T) {
+	encode := func(s string) []byte {
+		b := []byte{0xff, 0xfe}
+		for _, u := range utf16.Encode([]rune(s)) {
+			b = append(b, byte(u), byte(u>>8))
+		}
+		return b
+	}
+	var calls formatstest.Recorder
+	out, _, err := Translate(context.Background(), encode(`"k" = "Café";`), Strings, calls.Upper(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := encode(`"k" = "CAFÉ";`); string(out) != string(want) {
+		t.Errorf("output = %x, want %x", out, want)
+	}
+}
+
+func TestTranslateIncremental(t *testing.T) {
+	input := `{"a": "Apple", "b": {"c": "Cherry", "d": "Date"}}`
+	existing := `{"b": {"d": "Dattel"}, "a": "", "old": "Alt"}`
+	out, calls := translate(t, JSON, input, existing)
+	if want := `{"a": "APPLE", "b": {"c": "CHERRY", "d": "Dattel"}}`; out != want {
+		t.Errorf("output = %s, want %s", out, want)
+	}
+	if strings.Join(calls, "|") != "Apple|Cherry" {
+		t.Errorf("segments = %q", calls)
+	}
+}
+
+func TestTranslateSkipsLostPlaceholders(t *testing.T) {
+	input := `{"a": "Hello {name}"}`
+	out, stats, err := Translate(context.Background(), []byte(input), JSON, func(_ context.Context, s []string) ([]string, error) {
+		return []string{"Bonjour"}, nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != input || stats.Skipped != 1 || stats.Translated != 0 {
+		t.Errorf("output = %s, stats = %+v", out, stats)
+	}
+}
+
+func TestParseRejectsInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		format *Format
+		input  string
+	}{
+		{JSON, `{"a": }`},
+		{YAML, "a: [b"},
+		{Android, `<html><string name="a">b</string></html>`},
+		{Strings, `"a" = "b"`},
+	} {
+		if _, err := tc.format.parse([]byte(tc.input)); err == nil {
+			t.Errorf("%s: parse(%q) succeeded, want error", tc.format.Name, tc.input)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package resource
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// stringsScanner reads the "key" = "value"; pairs of an iOS .strings file
+type stringsScanner struct {
+	data []byte
+	pos  int
+}
+
+func parseStrings(data []byte) ([]entry, error) {
+	s := &stringsScanner{data: data}
+	var entries []entry
+	for {
+		if err := s.skip(); err != nil {
+			return nil, err
+		}
+		if s.pos == len(data) {
+			return entries, nil
+		}
+		key, _, err := s.token()
+		if err != nil {
+			return nil, err
+		}
+		if err := s.expect('='); err != nil {
+			return nil, err
+		}
+		if err := s.skip(); err != nil {
+			return nil, err
+		}
+		start := s.pos
+		value, quoted, err := s.token()
+		if err != nil {
+			return nil, err
+		}
+		end := s.pos
+		if err := s.expect(';'); err != nil {
+			return nil, err
+		}
+		if quoted {
+			entries = append(entries, entry{key: key, value: value, start: start, end: end, encode: stringsQuote})
+		}
+	}
+}
+
+// skip moves past whitespace and comments
+func (s *stringsScanner) skip() error {
+	for s.pos < len(s.data) {
+		rest := s.data[s.pos:]
+		switch {
+		case strings.IndexByte(" \t\r\n", rest[0]) >= 0:
+			s.pos++
+		case len(rest) > 1 && rest[0] == '/' && rest[1] == '*':
+			end := strings.Index(string(rest[2:]), "*/")
+			if end < 0 {
+				return fmt.Errorf("%w: unterminated comment", ErrInvalid)
+			}
+			s.pos += end + 4
+		case len(rest) > 1 && rest[0] == '/' && rest[1] == '/':
+			end := strings.IndexByte(string(rest), '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			s.pos += end
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// expect skips to the next token, which must be c, and moves past it
+func (s *stringsScanner) expect(c byte) error {
+	if err := s.skip(); err != nil {
+		return err
+	}
+	if s.pos == len(s.data) || s.data[s.pos] != c {
+		return fmt.Errorf("%w: expected %q at offset %d", ErrInvalid, c, s.pos)
+	}
+	s.pos++
+	return nil
+}
+
+// token reads a quoted string or an unquoted word after any whitespace,
+// leaving the position just past it. The first result is decoded.
+func (s *stringsScanner) token() (string, bool, error) {
+	if err := s.skip(); err != nil {
+		return "", false, err
+	}
+	start := s.pos
+	if s.pos < len(s.data) && s.data[s.pos] == '"' {
+		for s.pos++; s.pos < len(s.data) && s.data[s.pos] != '"'; s.pos++ {
+			if s.data[s.pos] == '\\' {
+				s.pos++
+			}
+		}
+		if s.pos >= len(s.data) {
+			return "", false, fmt.Errorf("%w: unterminated string at offset %d", ErrInvalid, start)
+		}
+		s.pos++
+		return stringsUnquote(string(s.data[start+1 : s.pos-1])), true, nil
+	}
+	for s.pos < len(s.data) && strings.IndexByte(" \t\r\n=;/\"", s.data[s.pos]) < 0 {
+		s.pos++
+	}
+	if s.pos == start {
+		return "", false, fmt.Errorf("%w: unexpected character at offset %d", ErrInvalid, start)
+	}
+	return string(s.data[start:s.pos]), false, nil
+}
+
+// stringsUnquote resolves the escapes of a .strings literal
+func stringsUnquote(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case 'U', 'u':
+			if r, err := strconv.ParseUint(s[i+1:min(i+5, len(s))], 16, 32); err == nil && i+5 <= len(s) {
+				b.WriteRune(rune(r))
+				i += 4
+			} else {
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+var stringsEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+
+// stringsQuote writes a .strings literal
+func stringsQuote(value string) string {
+	return `"` + stringsEscaper.Replace(value) + `"`
+}
@@ -0,0 +1,200 @@
+package resource
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+	"github.com/goccy/go-yaml/token"
+)
+
+// yamlScanner finds the string values of a YAML document by the positions
+// of their tokens, so that comments and layout around them stay untouched
+type yamlScanner struct {
+	data       []byte
+	lineStarts []int
+	entries    []entry
+}
+
+func parseYAML(data []byte) ([]entry, error) {
+	file, err := parser.ParseBytes(data, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	s := &yamlScanner{data: data, lineStarts: []int{0}}
+	for i, c := range data {
+		if c == '\n' {
+			s.lineStarts = append(s.lineStarts, i+1)
+		}
+	}
+	for _, doc := range file.Docs {
+		s.node(doc.Body, "", false)
+	}
+	return s.entries, nil
+}
+
+func (s *yamlScanner) node(n ast.Node, path string, flow bool) {
+	switch n := n.(type) {
+	case *ast.MappingNode:
+		for _, v := range n.Values {
+			s.node(v, path, flow || n.IsFlowStyle)
+		}
+	case *ast.MappingValueNode:
+		key := n.Key.String()
+		if k, ok := n.Key.(*ast.StringNode); ok {
+			key = k.Value
+		}
+		s.node(n.Value, joinKey(path, key), flow || n.IsFlowStyle)
+	case *ast.SequenceNode:
+		for i, v := range n.Values {
+			s.node(v, path+"["+strconv.Itoa(i)+"]", flow || n.IsFlowStyle)
+		}
+	case *ast.AnchorNode:
+		s.node(n.Value, path, flow)
+	case *ast.StringNode:
+		s.scalar(n, path, flow)
+	case *ast.LiteralNode:
+		s.block(n, path)
+	}
+}
+
+// offset converts a token position, whose column counts characters from
+// 1, to a byte offset, or -1 when it is out of range
+func (s *yamlScanner) offset(line, column int) int {
+	if line < 1 || line > len(s.lineStarts) {
+		return -1
+	}
+	pos := s.lineStarts[line-1]
+	for i := 1; i < column && pos < len(s.data); i++ {
+		_, size := utf8.DecodeRune(s.data[pos:])
+		pos += size
+	}
+	return pos
+}
+
+// at reports whether raw is written at offset start
+func (s *yamlScanner) at(start int, raw string) bool {
+	return start >= 0 && start+len(raw) <= len(s.data) && string(s.data[start:start+len(raw)]) == raw
+}
+
+// scalar records a plain or quoted scalar. Values whose position cannot be
+// confirmed in the source are left alone.
+func (s *yamlScanner) scalar(n *ast.StringNode, path string, flow bool) {
+	raw := strings.TrimSpace(n.Token.Origin)
+	start := s.offset(n.Token.Position.Line, n.Token.Position.Column)
+	if !s.at(start, raw) {
+		return
+	}
+	encode := yamlDoubleQuote
+	switch n.Token.Type {
+	case token.SingleQuoteType:
+		encode = yamlSingleQuote
+	case token.StringType:
+		encode = func(value string) string {
+			if yamlPlainSafe(value, flow) {
+				return value
+			}
+			return yamlDoubleQuote(value)
+		}
+	}
+	s.entries = append(s.entries, entry{key: path, value: n.Value, start: start, end: start + len(raw), encode: encode})
+}
+
+// block records a literal (|) or folded (>) block scalar. Its content
+// lines are rewritten at their original indentation; the header, with its
+// chomping indicator, is kept.
+func (s *yamlScanner) block(n *ast.LiteralNode, path string) {
+	header := n.Start
+	if !s.at(s.offset(header.Position.Line, header.Position.Column), strings.TrimSpace(header.Origin)) || header.Position.Line >= len(s.lineStarts) {
+		return
+	}
+	raw := strings.TrimRight(n.Value.Token.Origin, " \t\n")
+	start := s.lineStarts[header.Position.Line]
+	if !s.at(start, raw) {
+		return
+	}
+
+	indent := -1
+	for _, line := range strings.Split(raw, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if n := len(line) - len(strings.TrimLeft(line, " ")); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	prefix := strings.Repeat(" ", max(indent, 0))
+	separator := "\n"
+	if strings.HasPrefix(header.Value, ">") {
+		// A folded scalar needs a blank line for each line break
+		separator = "\n\n"
+	}
+	encode := func(value string) string {
+		lines := strings.Split(strings.TrimRight(value, "\n"), "\n")
+		for i, line := range lines {
+			if line != "" {
+				lines[i] = prefix + line
+			}
+		}
+		return strings.Join(lines, separator)
+	}
+	s.entries = append(s.entries, entry{key: path, value: n.Value.Value, start: start, end: start + len(raw), encode: encode})
+}
+
+// yamlDoubleQuote writes a double-quoted YAML scalar
+func yamlDoubleQuote(value string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range value {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20:
+			fmt.Fprintf(&b, `\x%02x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// yamlSingleQuote writes a single-quoted YAML scalar, falling back to
+// double quotes for values that need escapes
+func yamlSingleQuote(value string) string {
+	if strings.ContainsFunc(value, func(r rune) bool { return r < 0x20 }) {
+		return yamlDoubleQuote(value)
+	}
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// yamlPlainSafe reports whether value can be written as a plain scalar
+// that reads back as the same string
+func yamlPlainSafe(value string, flow bool) bool {
+	if value == "" || value != strings.TrimSpace(value) || strings.ContainsAny(value, "\n\t\r") {
+		return false
+	}
+	if strings.ContainsRune("-?:,[]{}#&*!|>'\"%@`", rune(value[0])) {
+		return false
+	}
+	if strings.Contains(value, ": ") || strings.Contains(value, " #") || strings.HasSuffix(value, ":") {
+		return false
+	}
+	if flow && strings.ContainsAny(value, ",[]{}") {
+		return false
+	}
+	switch strings.ToLower(value) {
+	case "true", "false", "yes", "no", "on", "off", "y", "n", "null", "~":
+		return false
+	}
+	_, err := strconv.ParseFloat(value, 64)
+	return err != nil
+}
@@ -0,0 +1,384 @@
+// Package subtitle translates SubRip (.srt) and WebVTT (.vtt) subtitles.
+// Neighbouring cues are translated together so the translator sees the
+// surrounding dialogue, and every translation is written back onto the
+// timing of the cue it came from.
+package subtitle
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LouisLau-art/go-translator/formats"
+)
+
+// Format is a subtitle file format
+type Format int
+
+// Supported formats
+const (
+	SRT Format = iota
+	VTT
+)
+
+// Content types of the supported formats
+const (
+	SRTContentType = "application/x-subrip; charset=utf-8"
+	VTTContentType = "text/vtt; charset=utf-8"
+)
+
+// DefaultMaxLineLength is the usual broadcast limit of characters per line
+const DefaultMaxLineLength = 42
+
+// Context windows group neighbouring cues for translation. A window ends
+// at a pause in the dialogue or once it holds enough text.
+const (
+	maxWindowCues  = 8
+	maxWindowChars = 600
+	maxWindowGap   = 3 * time.Second
+)
+
+// ErrInvalid is returned for files that cannot be parsed as subtitles
+var ErrInvalid = errors.New("not a valid subtitle file")
+
+// Options controls how translations are written
+type Options struct {
+	// MaxLineLength wraps translated lines to at most this many
+	// characters; 0 disables wrapping
+	MaxLineLength int
+
+	// Bilingual keeps the original lines above the translation
+	Bilingual bool
+}
+
+// Cue is one timed subtitle
+type Cue struct {
+	ID     string // SRT sequence number or WebVTT identifier, may be empty
+	Timing string // timing line as written, including WebVTT cue settings
+	Start  time.Duration
+	End    time.Duration
+	Lines  []string
+}
+
+// block is a cue, or a WebVTT block written back verbatim such as the
+// header, NOTE, STYLE and REGION blocks
+type block struct {
+	cue *Cue
+	raw string
+}
+
+// File is a parsed subtitle file
+type File struct {
+	Format Format
+	blocks []block
+}
+
+// Cues returns the cues of f in file order
+func (f *File) Cues() []*Cue {
+	var cues []*Cue
+	for _, b := range f.blocks {
+		if b.cue != nil {
+			cues = append(cues, b.cue)
+		}
+	}
+	return cues
+}
+
+var blankLines = regexp.MustCompile(`\n(?:[ \t]*\n)+`)
+
+// Parse reads an SRT or WebVTT file
+func Parse(data []byte, format Format) (*File, error) {
+	text := string(bytes.TrimPrefix(data, []byte("\ufeff")))
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.Trim(text, "\n")
+
+	f := &File{Format: format}
+	chunks := blankLines.Split(text, -1)
+	if format == VTT {
+		if !strings.HasPrefix(chunks[0], "WEBVTT") {
+			return nil, fmt.Errorf("%w: missing WEBVTT header", ErrInvalid)
+		}
+		f.blocks = append(f.blocks, block{raw: chunks[0]})
+		chunks = chunks[1:]
+	}
+
+	for i, chunk := range chunks {
+		if chunk == "" {
+			continue
+		}
+		if format == VTT && vttVerbatim(chunk) {
+			f.blocks = append(f.blocks, block{raw: chunk})
+			continue
+		}
+		cue, err := parseCue(chunk, format)
+		if err != nil {
+			return nil, fmt.Errorf("%w: block %d: %v", ErrInvalid, i+1, err)
+		}
+		f.blocks = append(f.blocks, block{cue: cue})
+	}
+	if len(f.Cues()) == 0 && format == SRT {
+		return nil, fmt.Errorf("%w: no cues", ErrInvalid)
+	}
+	return f, nil
+}
+
+// vttVerbatim reports whether a WebVTT block is not a cue
+func vttVerbatim(chunk string) bool {
+	for _, keyword := range []string{"NOTE", "STYLE", "REGION"} {
+		if chunk == keyword || strings.HasPrefix(chunk, keyword+" ") || strings.HasPrefix(chunk, keyword+"\n") {
+			return true
+		}
+	}
+	return false
+}
+
+func parseCue(chunk string, format Format) (*Cue, error) {
+	lines := strings.Split(chunk, "\n")
+	cue := &Cue{}
+	if !strings.Contains(lines[0], "-->") {
+		cue.ID = lines[0]
+		lines = lines[1:]
+		if format == SRT {
+			if _, err := strconv.Atoi(strings.TrimSpace(cue.ID)); err != nil {
+				return nil, fmt.Errorf("invalid sequence number %q", cue.ID)
+			}
+		}
+	}
+	if len(lines) == 0 || !strings.Contains(lines[0], "-->") {
+		return nil, errors.New("missing timing line")
+	}
+	cue.Timing = lines[0]
+
+	start, rest, _ := strings.Cut(cue.Timing, "-->")
+	end := strings.Fields(rest)
+	if len(end) == 0 {
+		return nil, fmt.Errorf("invalid timing %q", cue.Timing)
+	}
+	var err error
+	if cue.Start, err = parseTimestamp(strings.TrimSpace(start)); err != nil {
+		return nil, err
+	}
+	if cue.End, err = parseTimestamp(end[0]); err != nil {
+		return nil, err
+	}
+	cue.Lines = lines[1:]
+	return cue, nil
+}
+
+// parseTimestamp parses "hh:mm:ss,mmm" (SRT) and "[hh:]mm:ss.mmm" (WebVTT)
+func parseTimestamp(s string) (time.Duration, error) {
+	clock, millis, ok := strings.Cut(strings.Replace(s, ",", ".", 1), ".")
+	if !ok {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+	parts := strings.Split(clock, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+	var d time.Duration
+	for _, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timestamp %q", s)
+		}
+		d = d*60 + time.Duration(n)
+	}
+	ms, err := strconv.Atoi(millis)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+	return d*time.Second + time.Duration(ms)*time.Millisecond, nil
+}
+
+// Bytes renders f in its format
+func (f *File) Bytes() []byte {
+	var b strings.Builder
+	for i, blk := range f.blocks {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		if blk.cue == nil {
+			b.WriteString(blk.raw)
+			continue
+		}
+		if blk.cue.ID != "" {
+			b.WriteString(blk.cue.ID + "\n")
+		}
+		b.WriteString(blk.cue.Timing)
+		for _, line := range blk.cue.Lines {
+			b.WriteString("\n" + line)
+		}
+	}
+	b.WriteString("\n")
+	return []byte(b.String())
+}
+
+// Translate translates the cues of an SRT or WebVTT file and returns the
+// file with the same blocks and timings
+func Translate(ctx context.Context, data []byte, format Format, translate formats.TranslateFunc, opts Options) ([]byte, error) {
+	f, err := Parse(data, format)
+	if err != nil {
+		return nil, err
+	}
+	cues := f.Cues()
+	units := make([][]string, len(cues))
+	for i, cue := range cues {
+		units[i] = cueUnits(cue)
+	}
+
+	translated, err := translateWindows(ctx, cues, units, translate)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, cue := range cues {
+		var lines []string
+		for _, unit := range translated[i] {
+			lines = append(lines, wrap(unit, opts.MaxLineLength)...)
+		}
+		if opts.Bilingual {
+			lines = append(append([]string{}, cue.Lines...), lines...)
+		}
+		cue.Lines = lines
+	}
+	return f.Bytes(), nil
+}
+
+// cueUnits is the text of a cue as translation units. Line breaks inside
+// a sentence are dropped, as lines are wrapped again after translation;
+// dialogue cues, where each line starts with a dash, keep a unit per
+// speaker.
+func cueUnits(cue *Cue) []string {
+	var nonEmpty []string
+	for _, line := range cue.Lines {
+		if line = strings.TrimSpace(line); line != "" {
+			nonEmpty = append(nonEmpty, line)
+		}
+	}
+	if len(nonEmpty) == 0 {
+		return nil
+	}
+	dialogue := len(nonEmpty) > 1
+	for _, line := range nonEmpty {
+		if !strings.HasPrefix(line, "-") {
+			dialogue = false
+		}
+	}
+	if dialogue {
+		return nonEmpty
+	}
+	return []string{strings.Join(nonEmpty, " ")}
+}
+
+// window is a run of neighbouring cues translated as one text
+type window struct {
+	first, last int // cue indexes, inclusive
+}
+
+// windows groups cues into context windows
+func windows(cues []*Cue, units [][]string) []window {
+	var out []window
+	size, chars := 0, 0
+	for i := range cues {
+		n := 0
+		for _, u := range units[i] {
+			n += len(u) + 1
+		}
+		startNew := len(out) == 0 ||
+			size >= maxWindowCues ||
+			chars+n > maxWindowChars ||
+			cues[i].Start-cues[i-1].End > maxWindowGap
+		if startNew {
+			out = append(out, window{first: i, last: i})
+			size, chars = 0, 0
+		}
+		out[len(out)-1].last = i
+		size++
+		chars += n
+	}
+	return out
+}
+
+// translateWindows translates every cue's units. Each window is sent as
+// one segment with a unit per line; a window whose translation comes back
+// with a different number of lines is translated again unit by unit.
+func translateWindows(ctx context.Context, cues []*Cue, units [][]string, translate formats.TranslateFunc) ([][]string, error) {
+	wins := windows(cues, units)
+	segments := make([]string, len(wins))
+	for i, w := range wins {
+		var lines []string
+		for c := w.first; c <= w.last; c++ {
+			lines = append(lines, units[c]...)
+		}
+		segments[i] = strings.Join(lines, "\n")
+	}
+
+	translations, err := translate(ctx, segments)
+	if err != nil {
+		return nil, err
+	}
+	if len(translations) != len(segments) {
+		return nil, fmt.Errorf("got %d translations for %d windows", len(translations), len(segments))
+	}
+
+	out := make([][]string, len(cues))
+	var retry []window
+	for i, w := range wins {
+		want := 0
+		for c := w.first; c <= w.last; c++ {
+			want += len(units[c])
+		}
+		lines := nonEmptyLines(translations[i])
+		if len(lines) != want {
+			retry = append(retry, w)
+			continue
+		}
+		for c := w.first; c <= w.last; c++ {
+			out[c], lines = lines[:len(units[c])], lines[len(units[c]):]
+		}
+	}
+	if len(retry) == 0 {
+		return out, nil
+	}
+
+	var single []string
+	for _, w := range retry {
+		for c := w.first; c <= w.last; c++ {
+			single = append(single, units[c]...)
+		}
+	}
+	translations, err = translate(ctx, single)
+	if err != nil {
+		return nil, err
+	}
+	if len(translations) != len(single) {
+		return nil, fmt.Errorf("got %d translations for %d lines", len(translations), len(single))
+	}
+	for _, w := range retry {
+		for c := w.first; c <= w.last; c++ {
+			n := len(units[c])
+			out[c] = make([]string, n)
+			for j := range n {
+				out[c][j] = strings.ReplaceAll(strings.TrimSpace(translations[j]), "\n", " ")
+			}
+			translations = translations[n:]
+		}
+	}
+	return out, nil
+}
+
+// nonEmptyLines splits text into trimmed lines, dropping blank ones
+func nonEmptyLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,149 @@
+package subtitle
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LouisLau-art/go-translator/formats/formatstest"
+)
+
+const testSRT = `1
+00:00:01,000 --> 00:00:02,500
+Hello there.
+
+2
+00:00:02,600 --> 00:00:04,000
+How are
+you today?
+
+3
+00:00:04,100 --> 00:00:05,000
+- Fine.
+- Me too.
+
+4
+00:01:00,000 --> 00:01:02,000
+Later that day.
+`
+
+func TestTranslateSRT(t *testing.T) {
+	var calls formatstest.Recorder
+	out, err := Translate(context.Background(), []byte(testSRT), SRT, calls.Upper(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The long pause before cue 4 starts a new window
+	wantSegments := []string{"Hello there.\nHow are you today?\n- Fine.\n- Me too.", "Later that day."}
+	if len(calls.Batches) != 1 || strings.Join(calls.Batches[0], "|") != strings.Join(wantSegments, "|") {
+		t.Fatalf("segments = %q, want %q", calls.Batches, wantSegments)
+	}
+
+	want := `1
+00:00:01,000 --> 00:00:02,500
+HELLO THERE.
+
+2
+00:00:02,600 --> 00:00:04,000
+HOW ARE YOU TODAY?
+
+3
+00:00:04,100 --> 00:00:05,000
+- FINE.
+- ME TOO.
+
+4
+00:01:00,000 --> 00:01:02,000
+LATER THAT DAY.
+`
+	if string(out) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestTranslateVTTBilingual(t *testing.T) {
+	input := "WEBVTT - demo\n\nNOTE kept as is\n\nintro\n00:01.000 --> 00:02.000 align:start\nHi\n"
+	var calls formatstest.Recorder
+	out, err := Translate(context.Background(), []byte(input), VTT, calls.Upper(), Options{Bilingual: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "WEBVTT - demo\n\nNOTE kept as is\n\nintro\n00:01.000 --> 00:02.000 align:start\nHi\nHI\n"
+	if string(out) != want {
+		t.Errorf("got:\n%q\nwant:\n%q", out, want)
+	}
+}
+
+func TestTranslateRetriesLinesWhenWindowMerged(t *testing.T) {
+	var calls [][]string
+	merge := func(_ context.Context, segments []string) ([]string, error) {
+		calls = append(calls, segments)
+		out := make([]string, len(segments))
+		for i, s := range segments {
+			// Joins the lines of a window into one, as a translator may
+			out[i] = strings.ToUpper(strings.ReplaceAll(s, "\n", " "))
+		}
+		return out, nil
+	}
+	out, err := Translate(context.Background(), []byte(testSRT), SRT, merge, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 2 || len(calls[1]) != 4 {
+		t.Fatalf("expected a second call with one segment per line, got %q", calls)
+	}
+	if !strings.Contains(string(out), "00:00:04,100 --> 00:00:05,000\n- FINE.\n- ME TOO.") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+}
+
+func TestParse(t *testing.T) {
+	f, err := Parse([]byte("\ufeff1\r\n01:02:03,004 --> 01:02:04,000\r\nText\r\n"), SRT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cues := f.Cues()
+	if len(cues) != 1 || cues[0].Start != time.Hour+2*time.Minute+3*time.Second+4*time.Millisecond {
+		t.Errorf("unexpected cues %+v", cues)
+	}
+
+	for _, bad := range []struct {
+		input  string
+		format Format
+	}{
+		{"", SRT},
+		{"1\nnot a timing\nText", SRT},
+		{"00:01.000 --> 00:02.000\nHi", VTT},
+	} {
+		if _, err := Parse([]byte(bad.input), bad.format); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", bad.input)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		text  string
+		limit int
+		want  []string
+	}{
+		{"Short line", 42, []string{"Short line"}},
+		{"The quick brown fox jumps over the lazy dog", 30, []string{"The quick brown fox", "jumps over the lazy dog"}},
+		{"我们明天早上八点在火车站见面，别迟到了", 10, []string{"我们明天早上八点在火", "车站见面，别迟到了"}},
+		{"Supercalifragilistic", 8, []string{"Supercal", "ifragili", "stic"}},
+		{"No limit at all here", 0, []string{"No limit at all here"}},
+	}
+	for _, tt := range tests {
+		got := wrap(tt.text, tt.limit)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("wrap(%q, %d) = %q, want %q", tt.text, tt.limit, got, tt.want)
+		}
+		for _, line := range got {
+			if tt.limit > 0 && len([]rune(line)) > tt.limit {
+				t.Errorf("wrap(%q, %d): line %q exceeds the limit", tt.text, tt.limit, line)
+			}
+		}
+	}
+}
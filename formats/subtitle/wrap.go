@@ -0,0 +1,130 @@
+package subtitle
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// token is a word, or a single character of a script written without
+// spaces, with whether a space separates it from the previous token
+type token struct {
+	text  string
+	space bool
+}
+
+func (t token) width() int {
+	return utf8.RuneCountInString(t.text)
+}
+
+// wrap breaks text into lines of at most limit characters, balancing the
+// line lengths. Words longer than limit are split. A limit of 0 or less
+// returns text as a single line.
+func wrap(text string, limit int) []string {
+	if limit <= 0 || utf8.RuneCountInString(text) <= limit {
+		return []string{text}
+	}
+	tokens := tokenize(text)
+
+	// Use the narrowest width that still needs no more lines than filling
+	// each line up to the limit, so the lines come out about equally long
+	total := utf8.RuneCountInString(text)
+	lines := fill(tokens, limit, limit)
+	for width := (total + len(lines) - 1) / len(lines); width < limit; width++ {
+		if balanced := fill(tokens, width, limit); len(balanced) <= len(lines) {
+			return balanced
+		}
+	}
+	return lines
+}
+
+// fill packs tokens greedily into lines of about target characters, never
+// exceeding limit
+func fill(tokens []token, target, limit int) []string {
+	var (
+		lines []string
+		line  strings.Builder
+		width int
+	)
+	flush := func() {
+		if width > 0 {
+			lines = append(lines, line.String())
+			line.Reset()
+			width = 0
+		}
+	}
+	for _, t := range tokens {
+		w := t.width()
+		sep := 0
+		if t.space && width > 0 {
+			sep = 1
+		}
+		if width > 0 && width+sep+w > target {
+			flush()
+			sep = 0
+		}
+		// A word that does not fit on a line of its own is split
+		for w > limit {
+			runes := []rune(t.text)
+			line.WriteString(string(runes[:limit]))
+			width = limit
+			flush()
+			t.text = string(runes[limit:])
+			w = t.width()
+		}
+		if sep > 0 {
+			line.WriteByte(' ')
+		}
+		line.WriteString(t.text)
+		width += sep + w
+	}
+	flush()
+	return lines
+}
+
+// tokenize splits text into words, treating each Han and kana character
+// as a word of its own so such text can break anywhere
+func tokenize(text string) []token {
+	var (
+		tokens []token
+		word   strings.Builder
+		space  bool
+	)
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, token{text: word.String(), space: space})
+			word.Reset()
+			space = false
+		}
+	}
+	for _, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+			flush()
+			space = len(tokens) > 0
+		case punctuation(r) && word.Len() > 0:
+			word.WriteRune(r)
+		case punctuation(r) && !space && len(tokens) > 0:
+			// Keep full-width punctuation off the start of a line
+			tokens[len(tokens)-1].text += string(r)
+		case wide(r):
+			flush()
+			tokens = append(tokens, token{text: string(r), space: space})
+			space = false
+		default:
+			word.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// wide reports whether r belongs to a script written without spaces
+func wide(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana) || punctuation(r)
+}
+
+// punctuation reports whether r is CJK or full-width punctuation
+func punctuation(r rune) bool {
+	return (r >= 0x3000 && r <= 0x303f) || (r >= 0xff00 && r <= 0xff65 && !unicode.IsLetter(r) && !unicode.IsDigit(r))
+}
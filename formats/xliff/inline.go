@@ -0,0 +1,187 @@
+package xliff
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// pairedTags are the inline elements that wrap translatable text: <g> and
+// <mrk> in XLIFF 1.2, <pc> and <mrk> in 2.0. They are sent to the
+// translator as <gN>…</gN> markers. Every other inline element, such as
+// <x/>, <ph> or <bpt>, holds native code and is sent as a <xN/> marker
+// that is restored byte for byte.
+var pairedTags = map[string]bool{"g": true, "pc": true, "mrk": true}
+
+// inlineTag is the original markup behind a marker
+type inlineTag struct {
+	open, close string // close is empty for standalone markers
+}
+
+// content is the translatable form of a <source> element's content
+type content struct {
+	text string // text with markers, surrounding whitespace trimmed
+	lead string
+	tail string
+	tags []inlineTag
+}
+
+// parseContent converts the raw XML content of a <source> element
+func parseContent(raw []byte) (*content, error) {
+	dec := xml.NewDecoder(bytes.NewReader(raw))
+	c := &content{}
+	var (
+		b     strings.Builder
+		stack []int // open paired markers
+	)
+	for {
+		start := int(dec.InputOffset())
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		end := int(dec.InputOffset())
+
+		switch t := tok.(type) {
+		case xml.CharData:
+			b.WriteString(string(t))
+		case xml.StartElement:
+			name := t.Name.Local
+			selfClosing := bytes.HasSuffix(raw[start:end], []byte("/>"))
+			if pairedTags[name] && !selfClosing && !protected(t) {
+				n := len(c.tags)
+				c.tags = append(c.tags, inlineTag{open: string(raw[start:end])})
+				stack = append(stack, n)
+				fmt.Fprintf(&b, "<g%d>", n)
+				continue
+			}
+			// Standalone marker for the whole element, content included
+			if err := skip(dec); err != nil {
+				return nil, err
+			}
+			end = int(dec.InputOffset())
+			fmt.Fprintf(&b, "<x%d/>", len(c.tags))
+			c.tags = append(c.tags, inlineTag{open: string(raw[start:end])})
+		case xml.EndElement:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("unexpected </%s>", t.Name.Local)
+			}
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			c.tags[n].close = string(raw[start:end])
+			fmt.Fprintf(&b, "</g%d>", n)
+		default:
+			// Comments and processing instructions are kept in place
+			fmt.Fprintf(&b, "<x%d/>", len(c.tags))
+			c.tags = append(c.tags, inlineTag{open: string(raw[start:end])})
+		}
+	}
+
+	text := b.String()
+	trimmed := strings.TrimSpace(text)
+	start := strings.Index(text, trimmed)
+	c.lead, c.text, c.tail = text[:start], trimmed, text[start+len(trimmed):]
+	return c, nil
+}
+
+// protected reports whether an inline element's content must not be
+// translated
+func protected(t xml.StartElement) bool {
+	for _, a := range t.Attr {
+		if (a.Name.Local == "translate" && a.Value == "no") || (a.Name.Local == "mtype" && a.Value == "protected") {
+			return true
+		}
+	}
+	return false
+}
+
+// translatable reports whether the content has any letters outside markers
+func (c *content) translatable() bool {
+	return strings.ContainsFunc(markerRe.ReplaceAllString(c.text, ""), func(r rune) bool {
+		return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || r > 0x7f
+	})
+}
+
+var (
+	markerRe    = regexp.MustCompile(`<(/?)([gx])(\d+)(/?)>`)
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+)
+
+// restore converts a translation back to XML content with the original
+// inline markup. It reports false unless every marker appears exactly
+// once and paired markers nest properly.
+func (c *content) restore(translation string) (string, bool) {
+	var (
+		b     strings.Builder
+		seen  = make([]bool, len(c.tags))
+		stack []int
+		pos   int
+	)
+	translation = strings.TrimSpace(translation)
+	b.WriteString(textEscaper.Replace(c.lead))
+	for _, m := range markerRe.FindAllStringSubmatchIndex(translation, -1) {
+		b.WriteString(textEscaper.Replace(translation[pos:m[0]]))
+		pos = m[1]
+
+		closing := m[3] > m[2]
+		kind := translation[m[4]:m[5]]
+		selfClosing := m[9] > m[8]
+		n, err := strconv.Atoi(translation[m[6]:m[7]])
+		if err != nil || n >= len(c.tags) {
+			return "", false
+		}
+		paired := c.tags[n].close != ""
+		switch {
+		case kind == "x" && selfClosing && !closing && !paired && !seen[n]:
+			seen[n] = true
+			b.WriteString(c.tags[n].open)
+		case kind == "g" && !selfClosing && !closing && paired && !seen[n]:
+			seen[n] = true
+			stack = append(stack, n)
+			b.WriteString(c.tags[n].open)
+		case kind == "g" && closing && len(stack) > 0 && stack[len(stack)-1] == n:
+			stack = stack[:len(stack)-1]
+			b.WriteString(c.tags[n].close)
+		default:
+			return "", false
+		}
+	}
+	b.WriteString(textEscaper.Replace(translation[pos:]))
+	b.WriteString(textEscaper.Replace(c.tail))
+
+	if len(stack) > 0 {
+		return "", false
+	}
+	for _, s := range seen {
+		if !s {
+			return "", false
+		}
+	}
+	return b.String(), true
+}
+
+// skip consumes the rest of the element whose start tag was just read,
+// including the end token RawToken synthesizes for a self-closing tag
+func skip(dec *xml.Decoder) error {
+	depth := 1
+	for depth > 0 {
+		tok, err := dec.RawToken()
+		if err != nil {
+			return err
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+	}
+	return nil
+}
@@ -0,0 +1,356 @@
+// Package xliff translates XLIFF 1.2 and 2.0 files. Every translatable
+// <source> gets a <target>; inline markup, the rest of the document and
+// units marked translate="no" are left as they were.
+package xliff
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/LouisLau-art/go-translator/formats"
+)
+
+// ContentType is the MIME type of XLIFF files
+const ContentType = "application/xliff+xml; charset=utf-8"
+
+// ErrInvalid is returned for files that cannot be parsed as XLIFF
+var ErrInvalid = errors.New("not a valid XLIFF file")
+
+// States written for machine translations: 1.2 targets ask for review,
+// 2.0 segments have no finer state than translated
+const (
+	machineState12 = "needs-review-translation"
+	machineState20 = "translated"
+)
+
+// version is the major XLIFF version of a document
+type version int
+
+const (
+	v1 version = 1
+	v2 version = 2
+)
+
+// span is a byte range of the document
+type span struct {
+	start, end int
+}
+
+// element is an element's start tag and content
+type element struct {
+	name     string // qualified name as written, such as "target" or "xlf:target"
+	startTag span
+	inner    span
+	end      int // end of the whole element
+}
+
+func (e *element) selfClosing() bool {
+	return e.inner.start == e.end
+}
+
+// unit is a 1.2 <trans-unit> or a 2.0 <segment>
+type unit struct {
+	translate bool
+	segment   span // 2.0 segment start tag, which carries the state
+	source    *element
+	target    *element
+}
+
+// document is a parsed XLIFF file
+type document struct {
+	data    []byte
+	version version
+	// langTags are the start tags declaring the target language: the 2.0
+	// root or the 1.2 <file> elements
+	langTags []span
+	units    []*unit
+}
+
+// frame is an open element while parsing
+type frame struct {
+	local     string
+	translate bool
+	elem      *element
+}
+
+func parse(data []byte) (*document, error) {
+	doc := &document{data: data}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+
+	var (
+		stack     []*frame
+		current   *unit
+		unitDepth int
+	)
+	for {
+		offset := int(dec.InputOffset())
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		end := int(dec.InputOffset())
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			f := &frame{local: t.Name.Local, translate: true}
+			if len(stack) > 0 {
+				f.translate = stack[len(stack)-1].translate
+			}
+			switch attr(t, "translate") {
+			case "no":
+				f.translate = false
+			case "yes":
+				f.translate = true
+			}
+			stack = append(stack, f)
+			tag := span{offset, end}
+
+			switch {
+			case len(stack) == 1:
+				if f.local != "xliff" {
+					return nil, fmt.Errorf("%w: root element is <%s>", ErrInvalid, f.local)
+				}
+				doc.version = v1
+				if strings.HasPrefix(attr(t, "version"), "2") || strings.Contains(attr(t, "xmlns"), ":2.") {
+					doc.version = v2
+					doc.langTags = append(doc.langTags, tag)
+				}
+			case f.local == "file" && doc.version == v1:
+				doc.langTags = append(doc.langTags, tag)
+			case current == nil && (f.local == "trans-unit" && doc.version == v1 || f.local == "segment" && doc.version == v2):
+				current = &unit{translate: f.translate, segment: tag}
+				unitDepth = len(stack)
+			case current != nil && len(stack) == unitDepth+1 && (f.local == "source" || f.local == "target"):
+				f.elem = &element{name: qualified(t.Name), startTag: tag, inner: span{end, end}}
+				if f.local == "source" {
+					current.source = f.elem
+				} else {
+					current.target = f.elem
+				}
+			}
+
+		case xml.EndElement:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("%w: unexpected </%s>", ErrInvalid, t.Name.Local)
+			}
+			f := stack[len(stack)-1]
+			if f.elem != nil {
+				f.elem.inner.end = offset
+				f.elem.end = end
+			}
+			if current != nil && len(stack) == unitDepth {
+				if current.source != nil {
+					doc.units = append(doc.units, current)
+				}
+				current = nil
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+	if doc.version == 0 {
+		return nil, fmt.Errorf("%w: missing <xliff> root", ErrInvalid)
+	}
+	return doc, nil
+}
+
+func attr(t xml.StartElement, name string) string {
+	for _, a := range t.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func qualified(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// needsTranslation reports whether u has no usable target yet
+func (d *document) needsTranslation(u *unit) bool {
+	if !u.translate {
+		return false
+	}
+	if u.target == nil || len(bytes.TrimSpace(d.bytes(u.target.inner))) == 0 {
+		return true
+	}
+	if d.version == v1 {
+		state := attrValue(d.bytes(u.target.startTag), "state")
+		return state == "new" || state == "needs-translation"
+	}
+	return false
+}
+
+func (d *document) bytes(s span) []byte {
+	return d.data[s.start:s.end]
+}
+
+// edit replaces a span of the document with text
+type edit struct {
+	span
+	text string
+}
+
+// Translate fills in the target of every translatable unit that has none,
+// sets the target language, and returns the document. Units whose inline
+// tags do not survive translation are left untranslated and counted as
+// skipped.
+func Translate(ctx context.Context, data []byte, target string, translate formats.TranslateFunc) ([]byte, formats.Stats, error) {
+	var stats formats.Stats
+	doc, err := parse(data)
+	if err != nil {
+		return nil, stats, err
+	}
+
+	var edits []edit
+	langAttr := "target-language"
+	if doc.version == v2 {
+		langAttr = "trgLang"
+	}
+	for _, tag := range doc.langTags {
+		edits = append(edits, edit{tag, setAttr(string(doc.bytes(tag)), langAttr, target)})
+	}
+
+	type pending struct {
+		unit    *unit
+		content *content
+		segment int // index into segments, or -1 when copied as is
+	}
+	var (
+		work     []pending
+		segments []string
+	)
+	for _, u := range doc.units {
+		if !doc.needsTranslation(u) {
+			continue
+		}
+		c, err := parseContent(doc.bytes(u.source.inner))
+		if err != nil {
+			return nil, stats, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		if c.text == "" {
+			continue
+		}
+		p := pending{unit: u, content: c, segment: -1}
+		if c.translatable() {
+			p.segment = len(segments)
+			segments = append(segments, c.text)
+		}
+		work = append(work, p)
+	}
+
+	var translations []string
+	if len(segments) > 0 {
+		if translations, err = translate(ctx, segments); err != nil {
+			return nil, stats, err
+		}
+		if len(translations) != len(segments) {
+			return nil, stats, fmt.Errorf("got %d translations for %d units", len(translations), len(segments))
+		}
+	}
+
+	for _, p := range work {
+		text := string(doc.bytes(p.unit.source.inner))
+		if p.segment >= 0 {
+			var ok bool
+			if text, ok = p.content.restore(translations[p.segment]); !ok {
+				stats.Skipped++
+				continue
+			}
+		}
+		edits = append(edits, doc.targetEdits(p.unit, text)...)
+		stats.Translated++
+	}
+
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+	var out bytes.Buffer
+	pos := 0
+	for _, e := range edits {
+		out.Write(data[pos:e.start])
+		out.WriteString(e.text)
+		pos = e.end
+	}
+	out.Write(data[pos:])
+	return out.Bytes(), stats, nil
+}
+
+// targetEdits writes text as the target of u and marks it machine
+// translated, keeping the target's other attributes
+func (d *document) targetEdits(u *unit, text string) []edit {
+	var edits []edit
+	state := machineState12
+	if d.version == v2 {
+		state = machineState20
+		edits = append(edits, edit{u.segment, setAttr(string(d.bytes(u.segment)), "state", state)})
+	}
+
+	if t := u.target; t != nil {
+		startTag := string(d.bytes(t.startTag))
+		if t.selfClosing() {
+			startTag = strings.TrimSuffix(strings.TrimSuffix(startTag, "/>"), " ") + ">"
+		}
+		if d.version == v1 {
+			startTag = setAttr(startTag, "state", state)
+		}
+		return append(edits, edit{span{t.startTag.start, t.end}, startTag + text + "</" + t.name + ">"})
+	}
+
+	// Insert the target after the source, on its own line if the source is
+	name := strings.TrimSuffix(u.source.name, "source") + "target"
+	startTag := "<" + name + ">"
+	if d.version == v1 {
+		startTag = "<" + name + ` state="` + state + `">`
+	}
+	return append(edits, edit{span{u.source.end, u.source.end}, d.indent(u.source.startTag.start) + startTag + text + "</" + name + ">"})
+}
+
+// indent returns a newline and the indentation of the line at offset, or
+// "" when something other than whitespace precedes offset on its line
+func (d *document) indent(offset int) string {
+	lineStart := bytes.LastIndexByte(d.data[:offset], '\n') + 1
+	prefix := d.data[lineStart:offset]
+	if len(bytes.TrimLeft(prefix, " \t")) > 0 {
+		return ""
+	}
+	return "\n" + string(prefix)
+}
+
+var attrValueRe = regexp.MustCompile(`\s([\w:.-]+)\s*=\s*("[^"]*"|'[^']*')`)
+
+// attrValue returns the raw value of an attribute in a start tag
+func attrValue(startTag []byte, name string) string {
+	for _, m := range attrValueRe.FindAllSubmatch(startTag, -1) {
+		if string(m[1]) == name {
+			return string(m[2][1 : len(m[2])-1])
+		}
+	}
+	return ""
+}
+
+// setAttr sets an attribute in a start tag, replacing its value if present
+func setAttr(startTag, name, value string) string {
+	quoted := `"` + textEscaper.Replace(value) + `"`
+	for _, m := range attrValueRe.FindAllStringSubmatchIndex(startTag, -1) {
+		if startTag[m[2]:m[3]] == name {
+			return startTag[:m[4]] + quoted + startTag[m[5]:]
+		}
+	}
+	end := len(startTag) - 1
+	if strings.HasSuffix(startTag, "/>") {
+		end--
+	}
+	return strings.TrimRight(startTag[:end], " \t\n") + " " + name + "=" + quoted + startTag[end:]
+}
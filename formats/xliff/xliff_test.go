@@ -0,0 +1,166 @@
+package xliff
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/LouisLau-art/go-translator/formats/formatstest"
+)
+
+const testXLIFF12 = `<?xml version="1.0" encoding="UTF-8"?>
+<xliff version="1.2" xmlns="urn:oasis:names:tc:xliff:document:1.2">
+  <file source-language="en" datatype="plaintext" original="app">
+    <body>
+      <trans-unit id="greeting">
+        <source>Click <g id="1">here</g> to <x id="2"/>continue &amp; save</source>
+      </trans-unit>
+      <trans-unit id="done">
+        <source>Done</source>
+        <target state="final">Fertig</target>
+      </trans-unit>
+      <trans-unit id="new" approved="no">
+        <source>Open</source>
+        <target xml:lang="de" state="new"/>
+      </trans-unit>
+      <trans-unit id="brand" translate="no">
+        <source>Acme</source>
+      </trans-unit>
+      <group translate="no">
+        <trans-unit id="code"><source>SELECT 1</source></trans-unit>
+      </group>
+      <trans-unit id="code-only">
+        <source><ph id="1">&lt;br/&gt;</ph> 42</source>
+      </trans-unit>
+    </body>
+  </file>
+</xliff>
+`
+
+const testXLIFF20 = `<?xml version="1.0" encoding="UTF-8"?>
+<xliff xmlns="urn:oasis:names:tc:xliff:document:2.0" version="2.0" srcLang="en">
+  <file id="f1">
+    <unit id="u1">
+      <segment>
+        <source>Hello <pc id="1">world</pc><ph id="2"/></source>
+      </segment>
+      <ignorable><source> </source></ignorable>
+      <segment state="initial">
+        <source>Bye</source>
+        <target></target>
+      </segment>
+    </unit>
+    <unit id="u2" translate="no">
+      <segment><source>Acme</source></segment>
+    </unit>
+  </file>
+</xliff>
+`
+
+func wellFormed(t *testing.T, data []byte) {
+	t.Helper()
+	dec := xml.NewDecoder(strings.NewReader(string(data)))
+	for {
+		if _, err := dec.Token(); err == io.EOF {
+			return
+		} else if err != nil {
+			t.Fatalf("output is not well-formed: %v\n%s", err, data)
+		}
+	}
+}
+
+func TestTranslate12(t *testing.T) {
+	var calls formatstest.Recorder
+	out, stats, err := Translate(context.Background(), []byte(testXLIFF12), "de", calls.Wrap("T[", "]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wellFormed(t, out)
+
+	wantCalls := []string{"Click <g0>here</g0> to <x1/>continue & save", "Open"}
+	if strings.Join(calls.Segments(), "|") != strings.Join(wantCalls, "|") {
+		t.Errorf("segments = %q, want %q", calls.Segments(), wantCalls)
+	}
+	if stats.Translated != 3 || stats.Skipped != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	for _, want := range []string{
+		`<file source-language="en" datatype="plaintext" original="app" target-language="de">`,
+		"continue &amp; save</source>\n        <target state=\"needs-review-translation\">T[Click <g id=\"1\">here</g> to <x id=\"2\"/>continue &amp; save]</target>",
+		`<target state="final">Fertig</target>`,
+		`<target xml:lang="de" state="needs-review-translation">T[Open]</target>`,
+		"<source>Acme</source>\n      </trans-unit>",
+		`<trans-unit id="code"><source>SELECT 1</source></trans-unit>`,
+		`<target state="needs-review-translation"><ph id="1">&lt;br/&gt;</ph> 42</target>`,
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestTranslate20(t *testing.T) {
+	var calls formatstest.Recorder
+	out, stats, err := Translate(context.Background(), []byte(testXLIFF20), "fr", calls.Wrap("T[", "]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wellFormed(t, out)
+
+	if strings.Join(calls.Segments(), "|") != "Hello <g0>world</g0><x1/>|Bye" || stats.Translated != 2 {
+		t.Errorf("segments = %q, stats = %+v", calls.Segments(), stats)
+	}
+	for _, want := range []string{
+		`version="2.0" srcLang="en" trgLang="fr">`,
+		"<segment state=\"translated\">\n        <source>Hello <pc id=\"1\">world</pc><ph id=\"2\"/></source>\n        <target>T[Hello <pc id=\"1\">world</pc><ph id=\"2\"/>]</target>",
+		"<segment state=\"translated\">\n        <source>Bye</source>\n        <target>T[Bye]</target>",
+		`<ignorable><source> </source></ignorable>`,
+		`<segment><source>Acme</source></segment>`,
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestTranslateSkipsLostTags(t *testing.T) {
+	input := `<xliff version="1.2"><file><body><trans-unit id="1"><source>A <g id="1">b</g></source></trans-unit></body></file></xliff>`
+	drop := func(_ context.Context, segments []string) ([]string, error) {
+		return []string{"A b"}, nil
+	}
+	out, stats, err := Translate(context.Background(), []byte(input), "de", drop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Skipped != 1 || strings.Contains(string(out), "<target") {
+		t.Errorf("stats = %+v, output:\n%s", stats, out)
+	}
+}
+
+func TestRestoreRejectsBadMarkers(t *testing.T) {
+	c, err := parseContent([]byte(`a <g id="1">b</g> <x id="2"/>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range []string{
+		"a b <x1/>",                // missing pair
+		"</g0>a<g0> b <x1/>",       // closed before opened
+		"<g0>a <x1/> b</g0> <x1/>", // duplicated
+		"<g0>a</g0> <g1>b</g1>",    // wrong kind
+	} {
+		if _, ok := c.restore(bad); ok {
+			t.Errorf("restore(%q) succeeded, want failure", bad)
+		}
+	}
+}
+
+func TestParseRejectsNonXLIFF(t *testing.T) {
+	for _, input := range []string{"<html></html>", "not xml <", ""} {
+		if _, err := parse([]byte(input)); err == nil {
+			t.Errorf("parse(%q) succeeded, want error", input)
+		}
+	}
+}
@@ -0,0 +1,509 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/LouisLau-art/go-translator/api"
+	"github.com/LouisLau-art/go-translator/formats"
+	"github.com/LouisLau-art/go-translator/formats/docx"
+	"github.com/LouisLau-art/go-translator/formats/html"
+	"github.com/LouisLau-art/go-translator/formats/pdf"
+	"github.com/LouisLau-art/go-translator/formats/po"
+	"github.com/LouisLau-art/go-translator/formats/resource"
+	"github.com/LouisLau-art/go-translator/formats/subtitle"
+	"github.com/LouisLau-art/go-translator/formats/xliff"
+	"github.com/LouisLau-art/go-translator/langdetect"
+	"github.com/LouisLau-art/go-translator/logging"
+	"github.com/LouisLau-art/go-translator/metrics"
+	"github.com/LouisLau-art/go-translator/pseudo"
+	"github.com/LouisLau-art/go-translator/tracing"
+)
+
+// DefaultMaxDocumentSize is the default upload limit for documents, in bytes
+const DefaultMaxDocumentSize = 10 << 20
+
+// documentConcurrency caps the segments of one document translated at once
+const documentConcurrency = 4
+
+// documentFormat is a file type accepted by the document endpoint. Its
+// translate func reads any format-specific settings from opts.
+type documentFormat struct {
+	contentType string
+	ext         string // extension of the translated file, if it differs
+	translate   func(ctx context.Context, opts *documentOptions, data []byte, translate formats.TranslateFunc) ([]byte, error)
+	// output, if set, picks the content type and extension of the
+	// translated file from the options instead
+	output func(opts *documentOptions) (contentType, ext string)
+}
+
+// documentOptions are the form fields and extra files sent with a
+// document, and the longest segment the handler accepts. Formats that
+// count the entries they touch report them in stats.
+type documentOptions struct {
+	fields    map[string]string
+	files     map[string][]byte
+	maxLength int
+	stats     *formats.Stats
+}
+
+// documentOptions returns fresh options for translating a document with
+// the given form fields and extra files
+func (h *TranslationHandler) documentOptions(fields map[string]string, files map[string][]byte) *documentOptions {
+	return &documentOptions{fields: fields, files: files, maxLength: h.settings.Load().maxLength}
+}
+
+// documentFormats maps lower-case file extensions to their formats
+var documentFormats = map[string]documentFormat{
+	".docx": {contentType: docx.ContentType, translate: func(ctx context.Context, _ *documentOptions, data []byte, translate formats.TranslateFunc) ([]byte, error) {
+		return docx.Translate(ctx, data, translate)
+	}},
+	".srt":   {contentType: subtitle.SRTContentType, translate: translateSubtitles(subtitle.SRT)},
+	".vtt":   {contentType: subtitle.VTTContentType, translate: translateSubtitles(subtitle.VTT)},
+	".po":    {contentType: po.ContentType, translate: withStats(po.Translate)},
+	".pot":   {contentType: po.ContentType, ext: ".po", translate: withStats(po.Translate)},
+	".html":  {contentType: html.ContentType, translate: withStats(html.Translate)},
+	".htm":   {contentType: html.ContentType, translate: withStats(html.Translate)},
+	".xlf":   {contentType: xliff.ContentType, translate: withStats(xliff.Translate)},
+	".xliff": {contentType: xliff.ContentType, translate: withStats(xliff.Translate)},
+	".pdf":   {translate: translatePDF, output: pdfOutput},
+
+	".json":    {contentType: resource.JSON.ContentType, translate: translateResource(resource.JSON)},
+	".yaml":    {contentType: resource.YAML.ContentType, translate: translateResource(resource.YAML)},
+	".yml":     {contentType: resource.YAML.ContentType, translate: translateResource(resource.YAML)},
+	".xml":     {contentType: resource.Android.ContentType, translate: translateResource(resource.Android)},
+	".strings": {contentType: resource.Strings.ContentType, translate: translateResource(resource.Strings)},
+}
+
+// statsTranslator is a format that translates into a target language and
+// counts the entries it touched
+type statsTranslator func(ctx context.Context, data []byte, target string, translate formats.TranslateFunc) ([]byte, formats.Stats, error)
+
+// withStats translates into the target field, reporting the entries
+// touched
+func withStats(fn statsTranslator) func(context.Context, *documentOptions, []byte, formats.TranslateFunc) ([]byte, error) {
+	return func(ctx context.Context, opts *documentOptions, data []byte, translate formats.TranslateFunc) ([]byte, error) {
+		out, stats, err := fn(ctx, data, opts.fields["target"], translate)
+		if err != nil {
+			return nil, err
+		}
+		opts.stats = &stats
+		return out, nil
+	}
+}
+
+// translateResource translates a localization file. An optional
+// "existing" file, the previous translation, limits the work to the keys
+// it is missing.
+func translateResource(format *resource.Format) func(context.Context, *documentOptions, []byte, formats.TranslateFunc) ([]byte, error) {
+	return func(ctx context.Context, opts *documentOptions, data []byte, translate formats.TranslateFunc) ([]byte, error) {
+		out, stats, err := resource.Translate(ctx, data, format, translate, opts.files["existing"])
+		if err != nil {
+			return nil, err
+		}
+		opts.stats = &stats
+		return out, nil
+	}
+}
+
+// setStatsHeaders reports the entries a format translated and skipped
+func setStatsHeaders(c *gin.Context, stats formats.Stats) {
+	c.Header("X-Translated-Entries", strconv.Itoa(stats.Translated))
+	c.Header("X-Skipped-Entries", strconv.Itoa(stats.Skipped))
+}
+
+// translateSubtitles translates subtitles with the bilingual and
+// max_line_length fields
+func translateSubtitles(format subtitle.Format) func(context.Context, *documentOptions, []byte, formats.TranslateFunc) ([]byte, error) {
+	return func(ctx context.Context, opts *documentOptions, data []byte, translate formats.TranslateFunc) ([]byte, error) {
+		settings := subtitle.Options{MaxLineLength: subtitle.DefaultMaxLineLength}
+		if v := opts.fields["bilingual"]; v != "" {
+			bilingual, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, &requestError{status: 400, message: "请求参数错误: bilingual 必须为布尔值"}
+			}
+			settings.Bilingual = bilingual
+		}
+		if v := opts.fields["max_line_length"]; v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return nil, &requestError{status: 400, message: "请求参数错误: max_line_length 必须为非负整数"}
+			}
+			settings.MaxLineLength = n
+		}
+		return subtitle.Translate(ctx, data, format, translate, settings)
+	}
+}
+
+// translatePDF extracts the text of a PDF and translates it into Markdown,
+// or plain text when the output field is "text", in pieces the segment
+// length limit accepts
+func translatePDF(ctx context.Context, opts *documentOptions, data []byte, translate formats.TranslateFunc) ([]byte, error) {
+	output, err := pdf.ParseOutput(opts.fields["output"])
+	if err != nil {
+		return nil, &requestError{status: 400, message: "请求参数错误: output 必须为 markdown 或 text"}
+	}
+	out, stats, err := pdf.Translate(ctx, data, output, opts.maxLength, translate)
+	if err != nil {
+		return nil, err
+	}
+	opts.stats = &stats
+	return out, nil
+}
+
+// pdfOutput is the type of a translated PDF, which translatePDF has
+// already validated
+func pdfOutput(opts *documentOptions) (string, string) {
+	if output, _ := pdf.ParseOutput(opts.fields["output"]); output == pdf.PlainText {
+		return pdf.TextContentType, ".txt"
+	}
+	return pdf.MarkdownContentType, ".md"
+}
+
+// translateDocument translates a document in a format, returning the
+// content type and file name of the translation
+func translateDocument(ctx context.Context, format documentFormat, filename string, data []byte, opts *documentOptions, translate formats.TranslateFunc) ([]byte, string, string, error) {
+	out, err := format.translate(ctx, opts, data, translate)
+	if err != nil {
+		return nil, "", "", err
+	}
+	contentType, ext := format.contentType, strings.ToLower(filepath.Ext(filename))
+	if format.ext != "" {
+		ext = format.ext
+	}
+	if format.output != nil {
+		contentType, ext = format.output(opts)
+	}
+	name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	return out, contentType, name + "." + opts.fields["target"] + ext, nil
+}
+
+// SetMaxDocumentSize changes the maximum accepted upload size at runtime
+func (h *TranslationHandler) SetMaxDocumentSize(size int) {
+	h.change(func(s *handlerSettings) { s.maxDocumentSize = int64(size) })
+}
+
+// HandleDocument translates an uploaded document and responds with the
+// translated file. The multipart form carries the document as "file" and
+// the source, target and model fields of a translation request.
+func (h *TranslationHandler) HandleDocument(c *gin.Context) {
+	ctx, span := tracing.Start(c.Request.Context(), "HandleDocument")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+
+	// A document is admitted like a single request; its segments then take
+	// tokens of their own as they are translated
+	if !h.limiter.Allow() {
+		metrics.RateLimitRejections.Inc()
+		respondError(c, 429, "请求过于频繁，请稍后再试")
+		return
+	}
+
+	upload, ok := h.readDocument(c)
+	if !ok {
+		return
+	}
+	span.SetAttributes(
+		attribute.String("document.format", upload.ext),
+		attribute.Int("document.size", len(upload.data)),
+	)
+	logger.Info("document translation request",
+		slog.String("format", upload.ext),
+		slog.Int("size", len(upload.data)),
+		slog.String("source", upload.req.Source),
+		slog.String("target", upload.req.Target))
+
+	var detected langdetect.Result
+	opts := h.documentOptions(upload.fields, upload.files)
+	out, contentType, filename, err := translateDocument(ctx, upload.format, upload.filename, upload.data, opts, h.translateSegments(upload.req, &detected, false, nil))
+	if err != nil {
+		var reqErr *requestError
+		if errors.As(err, &reqErr) {
+			reqErr.respond(c)
+			return
+		}
+		logger.Warn("document translation failed", slog.Any("error", err))
+		respondError(c, 400, "文件解析失败: "+err.Error())
+		return
+	}
+
+	if opts.stats != nil {
+		setStatsHeaders(c, *opts.stats)
+	}
+	if detected.Language != "" {
+		c.Header("X-Detected-Source", detected.Language)
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": filename,
+	}))
+	c.Data(200, contentType, out)
+}
+
+// documentUpload is a document read from a multipart form with its
+// translation settings
+type documentUpload struct {
+	filename string
+	ext      string
+	format   documentFormat
+	data     []byte
+	req      api.TranslateRequest
+	fields   map[string]string
+	files    map[string][]byte // extra files, such as "existing"
+}
+
+// readDocument reads and validates the document upload of c. It responds
+// with the error and returns false if the upload is not acceptable.
+func (h *TranslationHandler) readDocument(c *gin.Context) (*documentUpload, bool) {
+	logger := logging.FromContext(c.Request.Context())
+	maxSize := h.settings.Load().maxDocumentSize
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize)
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondError(c, 413, fmt.Sprintf("文件大小超过限制（最大%d字节）", maxSize))
+			return nil, false
+		}
+		logger.Warn("document upload error", slog.Any("error", err))
+		respondError(c, 400, "请上传文件: "+err.Error())
+		return nil, false
+	}
+	defer file.Close()
+
+	u := &documentUpload{filename: header.Filename, ext: strings.ToLower(filepath.Ext(header.Filename))}
+	var ok bool
+	if u.format, ok = documentFormats[u.ext]; !ok {
+		respondError(c, 400, "不支持的文件格式: "+u.ext)
+		return nil, false
+	}
+	if u.data, err = io.ReadAll(file); err == nil {
+		err = u.readForm(c.Request.MultipartForm)
+	}
+	if err != nil {
+		respondError(c, 400, "读取文件失败: "+err.Error())
+		return nil, false
+	}
+
+	u.req = api.TranslateRequest{
+		Source: u.fields["source"],
+		Target: u.fields["target"],
+		Model:  u.fields["model"],
+	}
+	if u.req.Target == "" {
+		respondError(c, 400, "请求格式错误: 缺少目标语言 target")
+		return nil, false
+	}
+	if _, ok := h.resolveModel(u.req.Model); !ok {
+		respondError(c, 400, "不支持的模型: "+u.req.Model)
+		return nil, false
+	}
+	return u, true
+}
+
+// readForm collects the fields of a multipart form and its files other
+// than the document itself
+func (u *documentUpload) readForm(form *multipart.Form) error {
+	u.fields, u.files = map[string]string{}, map[string][]byte{}
+	for name, values := range form.Value {
+		if len(values) > 0 {
+			u.fields[name] = values[0]
+		}
+	}
+	for name, headers := range form.File {
+		if name == "file" || len(headers) == 0 {
+			continue
+		}
+		file, err := headers[0].Open()
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+		u.files[name] = data
+	}
+	return nil
+}
+
+// SegmentTranslator returns a TranslateFunc that runs segments through the
+// same cache, chunking and provider chain as HTTP requests, for documents
+// translated from the command line
+func (h *TranslationHandler) SegmentTranslator(req api.TranslateRequest) formats.TranslateFunc {
+	var detected langdetect.Result
+	return h.translateSegments(req, &detected, false, nil)
+}
+
+// IsDocument reports whether filename has the extension of a document
+// format
+func IsDocument(filename string) bool {
+	_, ok := documentFormats[strings.ToLower(filepath.Ext(filename))]
+	return ok
+}
+
+// FileResult is the translation of a file
+type FileResult struct {
+	Data []byte
+	// Filename names the translation after the file and target language
+	Filename string
+	// Stats counts the entries of document formats that track them
+	Stats *formats.Stats
+}
+
+// TranslateFile translates a file from the command line through the same
+// cache, chunking and provider chain as HTTP requests. Files with a
+// document extension are translated like uploads to the document
+// endpoint, with fields holding the form fields of their format; other
+// files are translated as text, or as HTML when req.Format is "html".
+func (h *TranslationHandler) TranslateFile(ctx context.Context, req api.TranslateRequest, filename string, data []byte, fields map[string]string) (FileResult, error) {
+	asHTML := req.Format == "html"
+	req.Format = ""
+	translate := h.SegmentTranslator(req)
+
+	ext := strings.ToLower(filepath.Ext(filename))
+	if format, ok := documentFormats[ext]; ok {
+		opts := h.documentOptions(map[string]string{}, nil)
+		maps.Copy(opts.fields, fields)
+		opts.fields["source"], opts.fields["target"], opts.fields["model"] = req.Source, req.Target, req.Model
+		out, _, name, err := translateDocument(ctx, format, filename, data, opts, translate)
+		if err != nil {
+			return FileResult{}, err
+		}
+		return FileResult{Data: out, Filename: name, Stats: opts.stats}, nil
+	}
+
+	out, _, err := translateLongText(ctx, data, req.Target, asHTML, translate)
+	if err != nil {
+		return FileResult{}, err
+	}
+	name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	return FileResult{Data: out, Filename: name + "." + req.Target + filepath.Ext(filename)}, nil
+}
+
+// translateSegments returns a TranslateFunc that translates the segments
+// of a document with the settings of req. A source left to detection is
+// detected once, from the first batch of segments, and reported through
+// detected. A message context set on ctx is passed on with the batch.
+// Each segment is held to the text length limit and takes a token from
+// the rate limiter. With wait set, as for jobs, it waits for each token;
+// otherwise the whole batch must fit in the tokens available now, so a
+// synchronous request fails fast with 429 instead of holding the
+// connection. If onSegment is not nil it is called as each segment is done.
+func (h *TranslationHandler) translateSegments(req api.TranslateRequest, detected *langdetect.Result, wait bool, onSegment func()) formats.TranslateFunc {
+	var detectOnce sync.Once
+	source := req.Source
+	return func(ctx context.Context, segments []string) ([]string, error) {
+		detectOnce.Do(func() {
+			if req.Target != pseudo.Target && (source == "" || source == "auto") {
+				source, *detected = h.detectSource(ctx, strings.Join(segments, "\n"))
+			}
+		})
+		batchReq := req
+		batchReq.Source = source
+		if message := formats.MessageContext(ctx); message != "" {
+			batchReq.Context = message
+		}
+		metrics.TranslationChunks.Observe(float64(len(segments)))
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		var (
+			wg       sync.WaitGroup
+			once     sync.Once
+			firstErr error
+		)
+		// fail records the first segment to fail and stops the rest
+		fail := func(i int, reqErr *requestError) {
+			once.Do(func() {
+				reqErr.message = fmt.Sprintf("第%d段: %s", i+1, reqErr.message)
+				firstErr = reqErr
+				cancel()
+			})
+		}
+		results := make([]string, len(segments))
+		maxLength := h.settings.Load().maxLength
+		pending := 0
+		for i, segment := range segments {
+			if strings.TrimSpace(segment) == "" {
+				continue
+			}
+			if len(segment) > maxLength {
+				fail(i, &requestError{status: 400, message: fmt.Sprintf("文本长度超过限制（最大%d字符）", maxLength)})
+				return nil, firstErr
+			}
+			pending++
+		}
+		if !wait && !h.limiter.AllowN(time.Now(), pending) {
+			metrics.RateLimitRejections.Inc()
+			return nil, &requestError{status: 429, message: fmt.Sprintf("请求过于频繁：%d段待翻译，超出当前限流额度，请稍后再试或改用 /api/jobs 异步翻译", pending)}
+		}
+
+		sem := make(chan struct{}, documentConcurrency)
+		for i, segment := range segments {
+			if strings.TrimSpace(segment) == "" {
+				results[i] = segment
+				if onSegment != nil {
+					onSegment()
+				}
+				continue
+			}
+			if wait {
+				if err := h.limiter.Wait(ctx); err != nil {
+					if ctx.Err() == nil {
+						metrics.RateLimitRejections.Inc()
+						fail(i, &requestError{status: 429, message: "请求过于频繁，请稍后再试"})
+					}
+					break
+				}
+			}
+			sem <- struct{}{}
+			if ctx.Err() != nil {
+				<-sem
+				break
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				segReq := batchReq
+				segReq.Text = segment
+				out, reqErr := h.translateText(ctx, segReq)
+				if reqErr != nil {
+					fail(i, reqErr)
+					return
+				}
+				results[i] = out.Text
+				if onSegment != nil {
+					onSegment()
+				}
+			}()
+		}
+		wg.Wait()
+
+		if firstErr != nil {
+			return nil, firstErr
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return results, nil
+	}
+}
@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+
+	"github.com/LouisLau-art/go-translator/api"
+	"github.com/LouisLau-art/go-translator/cache"
+	"github.com/LouisLau-art/go-translator/langdetect"
+)
+
+// formFile is an additional file of a multipart upload
+type formFile struct {
+	field, filename string
+	data            []byte
+}
+
+// documentRequest builds a multipart upload of file with the given fields
+// and any extra files
+func documentRequest(t *testing.T, filename string, file []byte, fields map[string]string, extra ...formFile) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, v := range fields {
+		if err := mw.WriteField(k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range append([]formFile{{"file", filename, file}}, extra...) {
+		fw, err := mw.CreateFormFile(f.field, f.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(f.data)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/documents", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func testDocx(t *testing.T, paragraphs ...string) []byte {
+	t.Helper()
+	var document strings.Builder
+	document.WriteString(`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>`)
+	for _, p := range paragraphs {
+		document.WriteString(`<w:p><w:r><w:t>` + p + `</w:t></w:r></w:p>`)
+	}
+	document.WriteString(`</w:body></w:document>`)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("word/document.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(document.String()))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestHandleDocumentDocx(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := newTestTranslationHandler(t, "http://127.0.0.1:0")
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = documentRequest(t, "report.docx", testDocx(t, "Save", "Open"), map[string]string{"target": "pseudo"})
+	h.HandleDocument(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Disposition"); got != `attachment; filename=report.pseudo.docx` {
+		t.Errorf("Unexpected Content-Disposition %q", got)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("Response is not a zip: %v", err)
+	}
+	rc, err := zr.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	document, _ := io.ReadAll(rc)
+	rc.Close()
+	for _, want := range []string{"[Šáṽé", "[Öþéñ"} {
+		if !strings.Contains(string(document), want) {
+			t.Errorf("Expected %q in translated document: %s", want, document)
+		}
+	}
+}
+
+func TestHandleDocumentRejects(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := newTestTranslationHandler(t, "http://127.0.0.1:0")
+	h.SetMaxDocumentSize(1024)
+
+	tests := []struct {
+		name     string
+		filename string
+		file     []byte
+		status   int
+	}{
+		{"unsupported format", "notes.exe", []byte("MZ"), http.StatusBadRequest},
+		{"corrupt file", "report.docx", []byte("not a zip"), http.StatusBadRequest},
+		{"corrupt pdf", "scan.pdf", []byte("%PDF-1.4 truncated"), http.StatusBadRequest},
+		{"too large", "report.docx", bytes.Repeat([]byte("x"), 4096), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = documentRequest(t, tt.filename, tt.file, map[string]string{"target": "zh"})
+			h.HandleDocument(c)
+			if w.Code != tt.status {
+				t.Errorf("Expected %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleDocumentSubtitles(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := newTestTranslationHandler(t, "http://127.0.0.1:0")
+
+	srt := "1\n00:00:01,000 --> 00:00:02,000\nSave\n"
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = documentRequest(t, "movie.srt", []byte(srt), map[string]string{"target": "pseudo", "bilingual": "true"})
+	h.HandleDocument(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	want := "1\n00:00:01,000 --> 00:00:02,000\nSave\n[Šáṽé ~]\n"
+	if w.Body.String() != want {
+		t.Errorf("Expected %q, got %q", want, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "application/x-subrip") {
+		t.Errorf("Unexpected Content-Type %q", got)
+	}
+}
+
+func TestHandleDocumentResourceIncremental(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := newTestTranslationHandler(t, "http://127.0.0.1:0")
+
+	source := `{"save": "Save", "open": "Open"}`
+	existing := formFile{"existing", "de.json", []byte(`{"open": "Öffnen"}`)}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = documentRequest(t, "en.json", []byte(source), map[string]string{"target": "pseudo"}, existing)
+	h.HandleDocument(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if want := `{"save": "[Šáṽé ~]", "open": "Öffnen"}`; w.Body.String() != want {
+		t.Errorf("Expected %s, got %s", want, w.Body.String())
+	}
+	if got := w.Header().Get("X-Translated-Entries"); got != "1" {
+		t.Errorf("Expected 1 translated entry, got %q", got)
+	}
+}
+
+// sourceRecorder echoes texts and records the source language and
+// message context of each
+type sourceRecorder struct {
+	mu       sync.Mutex
+	sources  map[string]string
+	contexts map[string]string
+}
+
+func (r *sourceRecorder) Name() string { return "recorder" }
+
+func (r *sourceRecorder) Translate(_ context.Context, text, source, _ string, opts api.Options) (*api.Result, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sources[text] = source
+	if r.contexts != nil {
+		r.contexts[text] = opts.Context
+	}
+	return &api.Result{Text: text, Provider: "recorder"}, nil
+}
+
+func TestHandleDocumentPOMessageContext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c := cache.NewTranslatorCache(time.Hour, 100)
+	t.Cleanup(c.StopCleanup)
+	recorder := &sourceRecorder{sources: map[string]string{}, contexts: map[string]string{}}
+	h := NewTranslationHandler(recorder, c, rate.NewLimiter(rate.Inf, 1), 5000)
+
+	catalog := "#. Toolbar button\nmsgctxt \"verb\"\nmsgid \"Close\"\nmsgstr \"\"\n\n" +
+		"msgid \"Save\"\nmsgstr \"\"\n"
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = documentRequest(t, "messages.po", []byte(catalog), map[string]string{"source": "en", "target": "zh"})
+	h.HandleDocument(ctx)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// msgctxt and extracted comments reach the provider as message context
+	if got := recorder.contexts["Close"]; got != "verb\nToolbar button" {
+		t.Errorf("Expected msgctxt and the extracted comment as context, got %q", got)
+	}
+	if got, ok := recorder.contexts["Save"]; !ok || got != "" {
+		t.Errorf("Expected no context for a plain message, got %q (sent: %v)", got, ok)
+	}
+}
+
+func TestTranslateSegmentsDetectsOnce(t *testing.T) {
+	c := cache.NewTranslatorCache(time.Hour, 100)
+	t.Cleanup(c.StopCleanup)
+	recorder := &sourceRecorder{sources: map[string]string{}}
+	h := NewTranslationHandler(recorder, c, rate.NewLimiter(rate.Inf, 1), 5000)
+
+	var detected langdetect.Result
+	translate := h.translateSegments(api.TranslateRequest{Target: "zh"}, &detected, false, nil)
+	batches := [][]string{
+		{"Please save your changes before you leave."},
+		{"Bitte speichern Sie Ihre Änderungen, bevor Sie gehen."},
+	}
+	for _, batch := range batches {
+		if _, err := translate(context.Background(), batch); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first batch settles the source for the whole document
+	if detected.Language != "en" {
+		t.Errorf("Expected English to be detected, got %+v", detected)
+	}
+	for _, batch := range batches {
+		if got := recorder.sources[batch[0]]; got != "en" {
+			t.Errorf("Expected %q to be sent with source en, got %q", batch[0], got)
+		}
+	}
+}
+
+func TestHandleDocumentLimitsSegments(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c := cache.NewTranslatorCache(time.Hour, 100)
+	t.Cleanup(c.StopCleanup)
+
+	// Segments are held to the text length limit
+	h := NewTranslationHandler(api.NewOfflineClient(), c, rate.NewLimiter(rate.Inf, 1), 10)
+	w := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(w)
+	ctx.Request = documentRequest(t, "report.docx", testDocx(t, "Save", "This paragraph is too long"), map[string]string{"target": "pseudo"})
+	h.HandleDocument(ctx)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "第2段") {
+		t.Errorf("Expected the long segment to be rejected, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Each segment takes a token; the document itself took the first, and
+	// a synchronous request is turned away at once when the rest do not fit
+	h = NewTranslationHandler(api.NewOfflineClient(), c, rate.NewLimiter(rate.Every(time.Hour), 3), 5000)
+	w = httptest.NewRecorder()
+	ctx, _ = gin.CreateTestContext(w)
+	ctx.Request = documentRequest(t, "report.docx", testDocx(t, "Save", "Open", "Close"), map[string]string{"target": "pseudo"})
+	h.HandleDocument(ctx)
+	if w.Code != http.StatusTooManyRequests || !strings.Contains(w.Body.String(), "/api/jobs") {
+		t.Errorf("Expected the document to be rate limited, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// The tokens were left for a document that fits
+	w = httptest.NewRecorder()
+	ctx, _ = gin.CreateTestContext(w)
+	ctx.Request = documentRequest(t, "report.docx", testDocx(t, "Save"), map[string]string{"target": "pseudo"})
+	h.HandleDocument(ctx)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected the short document to be translated, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestTranslateSegmentsWaitsForJobs(t *testing.T) {
+	c := cache.NewTranslatorCache(time.Hour, 100)
+	t.Cleanup(c.StopCleanup)
+	h := NewTranslationHandler(api.NewOfflineClient(), c, rate.NewLimiter(rate.Every(10*time.Millisecond), 1), 5000)
+
+	// Jobs wait for tokens rather than failing the batch
+	var detected langdetect.Result
+	translate := h.translateSegments(api.TranslateRequest{Source: "en", Target: "pseudo"}, &detected, true, nil)
+	out, err := translate(context.Background(), []string{"Save", "Open", "Close"})
+	if err != nil || len(out) != 3 {
+		t.Fatalf("Expected the batch to be translated, got %q, %v", out, err)
+	}
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Component status values reported by the readiness probe
+const (
+	statusOK       = "ok"
+	statusFail     = "fail"
+	statusDegraded = "degraded"
+)
+
+// Degraded marks a check error as one that is reported, with status
+// "degraded", without making the instance unready
+func Degraded(err error) error {
+	if err == nil {
+		return nil
+	}
+	return degradedError{err}
+}
+
+type degradedError struct{ error }
+
+func (e degradedError) Unwrap() error { return e.error }
+
+// Checker reports whether a dependency is usable
+type Checker interface {
+	Ping(ctx context.Context) error
+}
+
+// RouteChecker is an upstream made of several routes, such as a fallback
+// chain, that are probed separately. Results are keyed by route name.
+type RouteChecker interface {
+	PingRoutes(ctx context.Context) map[string]error
+}
+
+// CheckerFunc adapts a function to the Checker interface
+type CheckerFunc func(ctx context.Context) error
+
+// Ping calls f(ctx)
+func (f CheckerFunc) Ping(ctx context.Context) error {
+	return f(ctx)
+}
+
+// ConfigChecker returns the readiness check for the configuration. status
+// reports the API keys missing for providers in use and the error of the
+// last rejected reload, if any. A rejected reload fails the component; a
+// missing key only degrades it, as local OpenAI-compatible servers run
+// without one.
+func ConfigChecker(status func() (missingKeys []string, reloadErr error)) Checker {
+	return CheckerFunc(func(context.Context) error {
+		missingKeys, reloadErr := status()
+		var missing error
+		if len(missingKeys) > 0 {
+			missing = fmt.Errorf("no API key configured: %s", strings.Join(missingKeys, ", "))
+		}
+		if reloadErr != nil {
+			return errors.Join(fmt.Errorf("last reload rejected, previous configuration still live: %w", reloadErr), missing)
+		}
+		return Degraded(missing)
+	})
+}
+
+// componentStatus is the per-component entry in a readiness response
+type componentStatus struct {
+	Status    string     `json:"status"`
+	Error     string     `json:"error,omitempty"`
+	LatencyMS int64      `json:"latency_ms"`
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+	Cached    bool       `json:"cached,omitempty"`
+}
+
+// HealthHandler serves liveness and readiness probes
+type HealthHandler struct {
+	version   string
+	startedAt time.Time
+	checks    []namedCheck
+	upstream  *cachedCheck
+	breakers  func() map[string]string
+}
+
+type namedCheck struct {
+	name    string
+	checker Checker
+}
+
+// NewHealthHandler creates a health handler. The upstream checker is
+// probed at most once per probeTTL; other checks run on every request. An
+// upstream that is a RouteChecker also reports each route, as
+// "upstream:<route>", and fails when any of them does.
+func NewHealthHandler(version string, upstream Checker, probeTTL time.Duration) *HealthHandler {
+	h := &HealthHandler{
+		version:   version,
+		startedAt: time.Now(),
+	}
+	if upstream != nil {
+		h.upstream = &cachedCheck{checker: upstream, ttl: probeTTL, timeout: 5 * time.Second}
+	}
+	return h
+}
+
+// AddCheck registers a dependency that is checked on every readiness probe
+func (h *HealthHandler) AddCheck(name string, checker Checker) {
+	h.checks = append(h.checks, namedCheck{name: name, checker: checker})
+}
+
+// SetBreakerStates reports circuit breaker states in readiness responses.
+// An open breaker does not make the instance unready: every replica shares
+// the same upstream, so taking them out of rotation would not help.
+func (h *HealthHandler) SetBreakerStates(states func() map[string]string) {
+	h.breakers = states
+}
+
+// HandleLive reports that the process is up and serving requests. The
+// status stays "healthy", as /api/health always answered, for existing
+// healthchecks.
+func (h *HealthHandler) HandleLive(c *gin.Context) {
+	c.JSON(200, gin.H{
+		"status":         "healthy",
+		"version":        h.version,
+		"uptime_seconds": int64(time.Since(h.startedAt).Seconds()),
+		"time":           time.Now().Unix(),
+	})
+}
+
+// HandleReady reports whether every dependency is usable, with a
+// per-component breakdown. It responds 503 when any component fails.
+func (h *HealthHandler) HandleReady(c *gin.Context) {
+	ctx := c.Request.Context()
+	components := make(map[string]componentStatus, len(h.checks)+1)
+	ready := true
+
+	for _, check := range h.checks {
+		start := time.Now()
+		err := check.checker.Ping(ctx)
+		status := componentStatus{Status: statusOK, LatencyMS: time.Since(start).Milliseconds()}
+		if err != nil {
+			status.Status = statusFail
+			status.Error = err.Error()
+			if errors.As(err, new(degradedError)) {
+				status.Status = statusDegraded
+			} else {
+				ready = false
+			}
+		}
+		components[check.name] = status
+	}
+
+	if h.upstream != nil {
+		status, routes := h.upstream.check(ctx)
+		if status.Status != statusOK {
+			ready = false
+		}
+		components["upstream"] = status
+		for name, route := range routes {
+			components["upstream:"+name] = route
+		}
+	}
+
+	code, overall := 200, "ready"
+	if !ready {
+		code, overall = 503, "not_ready"
+	}
+
+	body := gin.H{
+		"status":         overall,
+		"version":        h.version,
+		"go_version":     runtime.Version(),
+		"started_at":     h.startedAt.UTC().Format(time.RFC3339),
+		"uptime_seconds": int64(time.Since(h.startedAt).Seconds()),
+		"components":     components,
+	}
+	if h.breakers != nil {
+		body["circuit_breakers"] = h.breakers()
+	}
+	c.JSON(code, body)
+}
+
+// cachedCheck runs an expensive check at most once per ttl and shares the
+// result between concurrent callers
+type cachedCheck struct {
+	checker Checker
+	ttl     time.Duration
+	timeout time.Duration
+
+	mu     sync.Mutex
+	last   componentStatus
+	routes map[string]componentStatus
+	at     time.Time
+}
+
+// check returns the status of the checker and, for a RouteChecker, of
+// each route
+func (cc *cachedCheck) check(ctx context.Context) (componentStatus, map[string]componentStatus) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if !cc.at.IsZero() && time.Since(cc.at) < cc.ttl {
+		status := cc.last
+		status.Cached = true
+		routes := make(map[string]componentStatus, len(cc.routes))
+		for name, route := range cc.routes {
+			route.Cached = true
+			routes[name] = route
+		}
+		return status, routes
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cc.timeout)
+	defer cancel()
+
+	start := time.Now()
+	var (
+		err    error
+		routes map[string]componentStatus
+	)
+	if rc, ok := cc.checker.(RouteChecker); ok {
+		results := rc.PingRoutes(ctx)
+		routes = make(map[string]componentStatus, len(results))
+		var errs []error
+		for _, name := range slices.Sorted(maps.Keys(results)) {
+			routes[name] = newComponentStatus(results[name], start)
+			if results[name] != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, results[name]))
+			}
+		}
+		err = errors.Join(errs...)
+	} else {
+		err = cc.checker.Ping(ctx)
+	}
+	status := newComponentStatus(err, start)
+
+	cc.last, cc.routes, cc.at = status, routes, *status.CheckedAt
+	return status, routes
+}
+
+// newComponentStatus reports the outcome of a check that began at start
+func newComponentStatus(err error, start time.Time) componentStatus {
+	now := time.Now()
+	status := componentStatus{Status: statusOK, LatencyMS: now.Sub(start).Milliseconds(), CheckedAt: &now}
+	if err != nil {
+		status.Status = statusFail
+		status.Error = err.Error()
+	}
+	return status
+}
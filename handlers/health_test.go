@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type readyResponse struct {
+	Status     string                     `json:"status"`
+	Version    string                     `json:"version"`
+	Components map[string]componentStatus `json:"components"`
+}
+
+func serveReady(t *testing.T, h *HealthHandler) (int, readyResponse) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/readyz", h.HandleReady)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	var resp readyResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return w.Code, resp
+}
+
+func TestReadyReportsComponents(t *testing.T) {
+	probes := 0
+	upstream := CheckerFunc(func(context.Context) error {
+		probes++
+		return nil
+	})
+
+	h := NewHealthHandler("1.2.3", upstream, time.Minute)
+	h.AddCheck("config", CheckerFunc(func(context.Context) error { return nil }))
+
+	code, resp := serveReady(t, h)
+	if code != 200 || resp.Status != "ready" {
+		t.Fatalf("Expected 200 ready, got %d %s", code, resp.Status)
+	}
+	if resp.Version != "1.2.3" {
+		t.Errorf("Expected version 1.2.3, got %s", resp.Version)
+	}
+	if resp.Components["config"].Status != statusOK || resp.Components["upstream"].Status != statusOK {
+		t.Errorf("Expected all components ok, got %+v", resp.Components)
+	}
+
+	// Second probe within the TTL reuses the cached upstream result
+	_, resp = serveReady(t, h)
+	if probes != 1 {
+		t.Errorf("Expected upstream to be probed once, got %d", probes)
+	}
+	if !resp.Components["upstream"].Cached {
+		t.Error("Expected cached upstream result")
+	}
+}
+
+func TestReadyReportsConfig(t *testing.T) {
+	var (
+		missingKeys []string
+		reloadErr   error
+	)
+	h := NewHealthHandler("dev", nil, time.Minute)
+	h.AddCheck("config", ConfigChecker(func() ([]string, error) { return missingKeys, reloadErr }))
+
+	if code, resp := serveReady(t, h); code != 200 || resp.Components["config"].Status != statusOK {
+		t.Fatalf("Expected config to be ok, got %d %+v", code, resp.Components)
+	}
+
+	// A rejected reload keeps the instance out of rotation until fixed
+	reloadErr = errors.New("MAX_TEXT_LENGTH: must be greater than 0, got -5")
+	code, resp := serveReady(t, h)
+	if code != 503 || !strings.Contains(resp.Components["config"].Error, "MAX_TEXT_LENGTH") {
+		t.Errorf("Expected the rejected reload to be reported, got %d %+v", code, resp.Components["config"])
+	}
+
+	// A provider in use without an API key is reported as degraded
+	reloadErr, missingKeys = nil, []string{"OPENAI_API_KEY"}
+	code, resp = serveReady(t, h)
+	config := resp.Components["config"]
+	if code != 200 || config.Status != statusDegraded || !strings.Contains(config.Error, "OPENAI_API_KEY") {
+		t.Errorf("Expected the missing key to degrade config, got %d %+v", code, config)
+	}
+}
+
+func TestReadyFailsWhenComponentFails(t *testing.T) {
+	upstream := CheckerFunc(func(context.Context) error { return errors.New("401 unauthorized") })
+	h := NewHealthHandler("dev", upstream, time.Minute)
+	h.AddCheck("cache", CheckerFunc(func(context.Context) error { return nil }))
+
+	code, resp := serveReady(t, h)
+	if code != 503 || resp.Status != "not_ready" {
+		t.Fatalf("Expected 503 not_ready, got %d %s", code, resp.Status)
+	}
+	if resp.Components["upstream"].Error == "" {
+		t.Error("Expected upstream error to be reported")
+	}
+	if resp.Components["cache"].Status != statusOK {
+		t.Error("Expected cache to remain ok")
+	}
+}
+
+// routeChecker reports fixed results per route
+type routeChecker map[string]error
+
+func (r routeChecker) Ping(context.Context) error { return nil }
+
+func (r routeChecker) PingRoutes(context.Context) map[string]error { return r }
+
+func TestReadyReportsEachUpstreamRoute(t *testing.T) {
+	h := NewHealthHandler("dev", routeChecker{"doubao": errors.New("401 unauthorized"), "offline": nil}, time.Minute)
+
+	for range 2 {
+		code, resp := serveReady(t, h)
+		if code != 503 || resp.Status != "not_ready" {
+			t.Fatalf("Expected 503 not_ready while a route fails, got %d %s", code, resp.Status)
+		}
+		if resp.Components["upstream:doubao"].Status != statusFail || resp.Components["upstream:offline"].Status != statusOK {
+			t.Errorf("Unexpected route components %+v", resp.Components)
+		}
+	}
+}
+
+func TestLiveKeepsHealthyStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/health", NewHealthHandler("dev", nil, time.Minute).HandleLive)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/health", nil))
+	var body struct {
+		Status string `json:"status"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if w.Code != 200 || body.Status != "healthy" {
+		t.Errorf("Expected 200 healthy, got %d %q", w.Code, body.Status)
+	}
+}
@@ -0,0 +1,382 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/LouisLau-art/go-translator/api"
+	"github.com/LouisLau-art/go-translator/formats"
+	"github.com/LouisLau-art/go-translator/formats/html"
+	"github.com/LouisLau-art/go-translator/jobs"
+	"github.com/LouisLau-art/go-translator/langdetect"
+	"github.com/LouisLau-art/go-translator/logging"
+	"github.com/LouisLau-art/go-translator/metrics"
+	"github.com/LouisLau-art/go-translator/webhook"
+)
+
+// jobSpec is what a background job was submitted with. The text or
+// document to translate is kept as the job's input.
+type jobSpec struct {
+	Request api.TranslateRequest `json:"request"`
+	// Filename and the form fields and files are set for documents
+	Filename string            `json:"filename,omitempty"`
+	Fields   map[string]string `json:"fields,omitempty"`
+	Files    map[string][]byte `json:"files,omitempty"`
+}
+
+// JobHandler serves the background job API
+type JobHandler struct {
+	translations *TranslationHandler
+	jobs         *jobs.Manager
+	webhooks     *webhook.Sender
+}
+
+// NewJobHandler creates a job handler. The manager should run jobs with
+// translations.RunJob and notify callbacks with JobNotifier(webhooks);
+// callback URLs are refused when webhooks is nil.
+func NewJobHandler(translations *TranslationHandler, manager *jobs.Manager, webhooks *webhook.Sender) *JobHandler {
+	return &JobHandler{translations: translations, jobs: manager, webhooks: webhooks}
+}
+
+// HandleSubmit queues a translation and responds with the job. A JSON body
+// is a text translation request; a multipart form is a document, with the
+// same fields as the document endpoint. Either may set callback_url to be
+// notified when the job succeeds or fails.
+func (j *JobHandler) HandleSubmit(c *gin.Context) {
+	h := j.translations
+	logger := logging.FromContext(c.Request.Context())
+
+	if !h.limiter.Allow() {
+		metrics.RateLimitRejections.Inc()
+		respondError(c, 429, "请求过于频繁，请稍后再试")
+		return
+	}
+
+	var (
+		spec     jobSpec
+		input    []byte
+		callback string
+	)
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		upload, ok := h.readDocument(c)
+		if !ok {
+			return
+		}
+		spec = jobSpec{Request: upload.req, Filename: upload.filename, Fields: upload.fields, Files: upload.files}
+		input = upload.data
+		callback = c.PostForm("callback_url")
+	} else {
+		maxSize := h.settings.Load().maxDocumentSize
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize)
+		var body struct {
+			api.TranslateRequest
+			CallbackURL string `json:"callback_url"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				respondError(c, 413, fmt.Sprintf("文本长度超过限制（最大%d字节）", maxSize))
+				return
+			}
+			logger.Warn("request bind error", slog.Any("error", err))
+			respondError(c, 400, "请求格式错误: "+err.Error())
+			return
+		}
+		req := body.TranslateRequest
+		callback = body.CallbackURL
+		if req.Format != "" && req.Format != "text" && req.Format != "html" {
+			respondError(c, 400, "不支持的格式: "+req.Format)
+			return
+		}
+		if _, ok := h.resolveModel(req.Model); !ok {
+			respondError(c, 400, "不支持的模型: "+req.Model)
+			return
+		}
+		if err := api.ValidateExtraOptions(req.Options); err != nil {
+			respondError(c, 400, "请求参数错误: "+err.Error())
+			return
+		}
+		input = []byte(req.Text)
+		req.Text = ""
+		spec = jobSpec{Request: req}
+	}
+
+	if callback != "" {
+		if j.webhooks == nil {
+			respondError(c, 400, "服务器未配置回调签名密钥 (WEBHOOK_SECRET)，不支持 callback_url")
+			return
+		}
+		if err := j.webhooks.ValidateURL(c.Request.Context(), callback); err != nil {
+			respondError(c, 400, "回调地址无效: "+err.Error())
+			return
+		}
+	}
+
+	raw, err := json.Marshal(spec)
+	if err == nil {
+		var job jobs.Job
+		if job, err = j.jobs.Submit(raw, input, callback); err == nil {
+			logger.Info("job submitted",
+				slog.String("job_id", job.ID),
+				slog.Int("size", len(input)),
+				slog.String("target", spec.Request.Target),
+				slog.Bool("callback", callback != ""))
+			c.Header("Location", "/api/jobs/"+job.ID)
+			c.JSON(202, gin.H{"success": true, "job": jobView(job)})
+			return
+		}
+	}
+	if errors.Is(err, jobs.ErrQueueFull) {
+		respondError(c, 503, "任务队列已满，请稍后再试")
+		return
+	}
+	logger.Error("failed to submit job", slog.Any("error", err))
+	respondError(c, 500, "创建任务失败")
+}
+
+// HandleGet reports the status and progress of a job
+func (j *JobHandler) HandleGet(c *gin.Context) {
+	job, err := j.jobs.Get(c.Param("id"))
+	if err != nil {
+		respondError(c, 404, "任务不存在")
+		return
+	}
+	c.JSON(200, gin.H{"success": true, "job": jobView(job)})
+}
+
+// HandleResult downloads the translation of a job that succeeded
+func (j *JobHandler) HandleResult(c *gin.Context) {
+	job, data, err := j.jobs.Result(c.Param("id"))
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		respondError(c, 404, "任务不存在")
+		return
+	case errors.Is(err, jobs.ErrNoResult):
+		respondError(c, 409, "任务尚未成功完成，当前状态: "+string(job.Status))
+		return
+	case err != nil:
+		logging.FromContext(c.Request.Context()).Error("failed to read job result", slog.String("job_id", job.ID), slog.Any("error", err))
+		respondError(c, 500, "读取任务结果失败")
+		return
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": job.Result.Filename,
+	}))
+	c.Data(200, job.Result.ContentType, data)
+}
+
+// HandleCancel cancels a queued or running job
+func (j *JobHandler) HandleCancel(c *gin.Context) {
+	job, err := j.jobs.Cancel(c.Param("id"))
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		respondError(c, 404, "任务不存在")
+	case errors.Is(err, jobs.ErrFinished):
+		respondError(c, 409, "任务已结束，当前状态: "+string(job.Status))
+	default:
+		c.JSON(200, gin.H{"success": true, "job": jobView(job)})
+	}
+}
+
+// HandleDeliveries lists the attempts to notify the callback of a job.
+// The callback URL itself is not shown: anyone who knows the job ID can
+// read this.
+func (j *JobHandler) HandleDeliveries(c *gin.Context) {
+	job, err := j.jobs.Get(c.Param("id"))
+	if err != nil {
+		respondError(c, 404, "任务不存在")
+		return
+	}
+	deliveries := job.Deliveries
+	if deliveries == nil {
+		deliveries = []webhook.Attempt{}
+	}
+	c.JSON(200, gin.H{
+		"success":    true,
+		"notified":   job.Notified,
+		"deliveries": deliveries,
+	})
+}
+
+// JobNotifier posts the outcome of a job to its callback URL through
+// sender, as {"event": "job.succeeded" or "job.failed", "job": ...} with
+// the job as the status endpoint shows it. It returns nil, disabling
+// callbacks, when sender is nil.
+func JobNotifier(sender *webhook.Sender) jobs.Notifier {
+	if sender == nil {
+		return nil
+	}
+	return func(ctx context.Context, job jobs.Job, record func(webhook.Attempt)) error {
+		event := "job." + string(job.Status)
+		body, err := json.Marshal(gin.H{"event": event, "job": jobView(job)})
+		if err != nil {
+			return err
+		}
+		return sender.Send(ctx, job.Callback, webhook.Event{ID: job.ID, Name: event, Body: body}, record)
+	}
+}
+
+// jobView is the public representation of a job
+func jobView(job jobs.Job) gin.H {
+	percent := 0
+	if job.Status == jobs.Succeeded {
+		percent = 100
+	} else if job.Progress.Total > 0 {
+		percent = job.Progress.Done * 100 / job.Progress.Total
+	}
+	view := gin.H{
+		"id":     job.ID,
+		"status": job.Status,
+		"progress": gin.H{
+			"done":    job.Progress.Done,
+			"total":   job.Progress.Total,
+			"percent": percent,
+		},
+		"created_at": job.CreatedAt,
+		"updated_at": job.UpdatedAt,
+	}
+	if job.FinishedAt != nil {
+		view["finished_at"] = job.FinishedAt
+	}
+	if job.Error != "" {
+		view["error"] = job.Error
+	}
+	if job.Callback != "" {
+		// The URL may carry a token of the receiver, so only its use is shown
+		view["callback"] = true
+	}
+	if job.Result != nil {
+		result := gin.H{
+			"content_type": job.Result.ContentType,
+			"filename":     job.Result.Filename,
+			"size":         job.Result.Size,
+			"url":          "/api/jobs/" + job.ID + "/result",
+		}
+		for k, v := range job.Result.Meta {
+			result[k] = v
+		}
+		view["result"] = result
+	}
+	return view
+}
+
+// RunJob does the work of a background job through the same cache,
+// chunking and provider chain as HTTP requests. Progress counts segments:
+// the chunks of a text, or the segments a document format sends.
+func (h *TranslationHandler) RunJob(ctx context.Context, job jobs.Job, input []byte, report func(jobs.Progress)) (jobs.Result, []byte, error) {
+	var spec jobSpec
+	if err := json.Unmarshal(job.Spec, &spec); err != nil {
+		return jobs.Result{}, nil, fmt.Errorf("invalid job: %w", err)
+	}
+	req := spec.Request
+	asHTML := req.Format == "html"
+	req.Format = ""
+
+	var (
+		mu       sync.Mutex
+		progress jobs.Progress
+		detected langdetect.Result
+	)
+	step := func(done, total int) {
+		mu.Lock()
+		defer mu.Unlock()
+		progress.Done += done
+		progress.Total += total
+		report(progress)
+	}
+	segments := h.translateSegments(req, &detected, true, func() { step(1, 0) })
+	translate := func(ctx context.Context, s []string) ([]string, error) {
+		step(0, len(s))
+		return segments(ctx, s)
+	}
+
+	result := jobs.Result{Meta: map[string]string{}}
+	var out []byte
+	if spec.Filename != "" {
+		format, ok := documentFormats[strings.ToLower(filepath.Ext(spec.Filename))]
+		if !ok {
+			return jobs.Result{}, nil, fmt.Errorf("不支持的文件格式: %s", filepath.Ext(spec.Filename))
+		}
+		opts := h.documentOptions(spec.Fields, spec.Files)
+		var err error
+		out, result.ContentType, result.Filename, err = translateDocument(ctx, format, spec.Filename, input, opts, translate)
+		if err != nil {
+			return jobs.Result{}, nil, err
+		}
+		if opts.stats != nil {
+			result.Meta["translated_entries"] = strconv.Itoa(opts.stats.Translated)
+			result.Meta["skipped_entries"] = strconv.Itoa(opts.stats.Skipped)
+		}
+	} else {
+		var err error
+		if out, result.ContentType, err = translateLongText(ctx, input, req.Target, asHTML, translate); err != nil {
+			return jobs.Result{}, nil, err
+		}
+		ext := ".txt"
+		if asHTML {
+			ext = ".html"
+		}
+		result.Filename = "translation." + req.Target + ext
+	}
+	if detected.Language != "" {
+		result.Meta["detected_source"] = detected.Language
+	}
+	return result, out, nil
+}
+
+// translateLongText translates text of any length in chunks, or as HTML.
+// The text between chunks, such as paragraph breaks, is kept as it was.
+func translateLongText(ctx context.Context, text []byte, target string, asHTML bool, translate formats.TranslateFunc) ([]byte, string, error) {
+	if asHTML {
+		out, _, err := html.Translate(ctx, text, target, translate)
+		var reqErr *requestError
+		if err != nil && !errors.As(err, &reqErr) && ctx.Err() == nil {
+			err = fmt.Errorf("HTML 解析失败: %w", err)
+		}
+		return out, html.ContentType, err
+	}
+	chunks, gaps := splitKeepingGaps(string(text), 800)
+	translated, err := translate(ctx, chunks)
+	if err != nil {
+		return nil, "", err
+	}
+	var b strings.Builder
+	b.WriteString(gaps[0])
+	for i, chunk := range translated {
+		b.WriteString(chunk)
+		b.WriteString(gaps[i+1])
+	}
+	return []byte(b.String()), "text/plain; charset=utf-8", nil
+}
+
+// splitKeepingGaps splits text like smartSplit and also returns the text
+// around the chunks: gaps[0] comes before the first chunk and gaps[i+1]
+// after chunk i, so joining them back in turn rebuilds text
+func splitKeepingGaps(text string, maxChars int) ([]string, []string) {
+	chunks := smartSplit(text, maxChars)
+	gaps := make([]string, len(chunks)+1)
+	pos := 0
+	for i, chunk := range chunks {
+		start := strings.Index(text[pos:], chunk)
+		if start < 0 {
+			// smartSplit only returns pieces of text, in order
+			gaps[i] = "\n"
+			continue
+		}
+		gaps[i] = text[pos : pos+start]
+		pos += start + len(chunk)
+	}
+	gaps[len(chunks)] = text[pos:]
+	return chunks, gaps
+}
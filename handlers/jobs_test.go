@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/LouisLau-art/go-translator/jobs"
+	"github.com/LouisLau-art/go-translator/webhook"
+)
+
+// jobBody mirrors the job payload of the job endpoints
+type jobBody struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"`
+	Error    string `json:"error"`
+	Progress struct {
+		Done    int `json:"done"`
+		Total   int `json:"total"`
+		Percent int `json:"percent"`
+	} `json:"progress"`
+	Result map[string]any `json:"result"`
+}
+
+// newJobRouter serves the job endpoints of h with a manager in a
+// temporary directory, sending callbacks through webhooks
+func newJobRouter(t *testing.T, h *TranslationHandler, webhooks *webhook.Sender) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	m, err := jobs.Open(jobs.Options{Dir: t.TempDir(), Workers: 1, Notify: JobNotifier(webhooks)}, h.RunJob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Close(context.Background()) })
+	j := NewJobHandler(h, m, webhooks)
+	r := gin.New()
+	r.POST("/api/jobs", j.HandleSubmit)
+	r.GET("/api/jobs/:id", j.HandleGet)
+	r.GET("/api/jobs/:id/result", j.HandleResult)
+	r.POST("/api/jobs/:id/cancel", j.HandleCancel)
+	r.GET("/api/jobs/:id/deliveries", j.HandleDeliveries)
+	return r
+}
+
+// jobDocumentRequest builds a multipart document upload to the job endpoint
+func jobDocumentRequest(t *testing.T, filename string, file []byte, fields map[string]string) *http.Request {
+	t.Helper()
+	req := documentRequest(t, filename, file, fields)
+	req.URL.Path = "/api/jobs"
+	return req
+}
+
+func serveJob(t *testing.T, r *gin.Engine, req *http.Request, status int) (jobBody, *httptest.ResponseRecorder) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != status {
+		t.Fatalf("%s %s: expected %d, got %d: %s", req.Method, req.URL, status, w.Code, w.Body.String())
+	}
+	var body struct {
+		Job jobBody `json:"job"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	return body.Job, w
+}
+
+// waitJob polls a job until it reaches status
+func waitJob(t *testing.T, r *gin.Engine, id, status string) jobBody {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, _ := serveJob(t, r, httptest.NewRequest(http.MethodGet, "/api/jobs/"+id, nil), http.StatusOK)
+		if job.Status == status {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s is %s, want %s", id, job.Status, status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestJobTranslatesDocument(t *testing.T) {
+	r := newJobRouter(t, newTestTranslationHandler(t, "http://127.0.0.1:0"), nil)
+
+	srt := "1\n00:00:01,000 --> 00:00:02,000\nSave\n\n2\n00:00:03,000 --> 00:00:04,000\nOpen\n"
+	req := jobDocumentRequest(t, "movie.srt", []byte(srt), map[string]string{"target": "pseudo"})
+	job, w := serveJob(t, r, req, http.StatusAccepted)
+	if job.ID == "" || w.Header().Get("Location") != "/api/jobs/"+job.ID {
+		t.Fatalf("Unexpected submit response: %s", w.Body.String())
+	}
+
+	job = waitJob(t, r, job.ID, "succeeded")
+	if job.Progress.Done != job.Progress.Total || job.Progress.Total == 0 || job.Progress.Percent != 100 {
+		t.Errorf("Unexpected progress %+v", job.Progress)
+	}
+	if job.Result["filename"] != "movie.pseudo.srt" || job.Result["url"] != "/api/jobs/"+job.ID+"/result" {
+		t.Errorf("Unexpected result %v", job.Result)
+	}
+
+	_, w = serveJob(t, r, httptest.NewRequest(http.MethodGet, "/api/jobs/"+job.ID+"/result", nil), http.StatusOK)
+	if !strings.Contains(w.Body.String(), "[Šáṽé ~]") || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/x-subrip") {
+		t.Errorf("Unexpected result %q (%s)", w.Body.String(), w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Header().Get("Content-Disposition"), "movie.pseudo.srt") {
+		t.Errorf("Unexpected Content-Disposition %q", w.Header().Get("Content-Disposition"))
+	}
+
+	// A finished job cannot be cancelled
+	serveJob(t, r, httptest.NewRequest(http.MethodPost, "/api/jobs/"+job.ID+"/cancel", nil), http.StatusConflict)
+}
+
+func TestJobTranslatesHTMLText(t *testing.T) {
+	r := newJobRouter(t, newTestTranslationHandler(t, "http://127.0.0.1:0"), nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/jobs", strings.NewReader(`{"text":"<p>Save <b>all</b></p>","target":"pseudo","format":"html"}`))
+	req.Header.Set("Content-Type", "application/json")
+	job, _ := serveJob(t, r, req, http.StatusAccepted)
+	waitJob(t, r, job.ID, "succeeded")
+
+	_, w := serveJob(t, r, httptest.NewRequest(http.MethodGet, "/api/jobs/"+job.ID+"/result", nil), http.StatusOK)
+	if body := w.Body.String(); !strings.HasPrefix(body, "<p>") || !strings.Contains(body, "<b>") || strings.Contains(body, "Save") {
+		t.Errorf("Unexpected result %q", body)
+	}
+}
+
+func TestJobCancel(t *testing.T) {
+	// The upstream holds every request until the client gives up
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server only notices the client going away once the body is read
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	defer upstream.Close()
+	r := newJobRouter(t, newTestTranslationHandler(t, upstream.URL), nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/jobs", strings.NewReader(`{"text":"Hello","source":"en","target":"de"}`))
+	req.Header.Set("Content-Type", "application/json")
+	job, _ := serveJob(t, r, req, http.StatusAccepted)
+	waitJob(t, r, job.ID, "running")
+
+	job, _ = serveJob(t, r, httptest.NewRequest(http.MethodPost, "/api/jobs/"+job.ID+"/cancel", nil), http.StatusOK)
+	if job.Status != "cancelled" {
+		t.Errorf("Expected cancelled job, got %+v", job)
+	}
+	serveJob(t, r, httptest.NewRequest(http.MethodGet, "/api/jobs/"+job.ID+"/result", nil), http.StatusConflict)
+	serveJob(t, r, httptest.NewRequest(http.MethodGet, "/api/jobs/missing", nil), http.StatusNotFound)
+}
+
+func TestJobRejectsInvalidRequests(t *testing.T) {
+	r := newJobRouter(t, newTestTranslationHandler(t, "http://127.0.0.1:0"), nil)
+
+	for _, body := range []string{
+		`{"text":"Hello"}`,
+		`{"text":"Hello","target":"de","format":"pdf"}`,
+		`{"text":"Hello","target":"de","model":"unknown"}`,
+		// Callbacks need a webhook secret
+		`{"text":"Hello","target":"de","callback_url":"http://cms.example/hook"}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/jobs", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		serveJob(t, r, req, http.StatusBadRequest)
+	}
+	serveJob(t, r, jobDocumentRequest(t, "notes.exe", []byte("MZ"), map[string]string{"target": "de"}), http.StatusBadRequest)
+}
+
+func TestJobCallback(t *testing.T) {
+	const secret = "hook-secret"
+	type delivery struct {
+		event string
+		body  struct {
+			Event string  `json:"event"`
+			Job   jobBody `json:"job"`
+		}
+	}
+	deliveries := make(chan delivery, 4)
+	// The receiver turns the first delivery away to exercise the retry
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		if err := webhook.Verify(secret, r.Header, raw, time.Minute); err != nil {
+			t.Errorf("Invalid signature: %v", err)
+		}
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		d := delivery{event: r.Header.Get(webhook.EventHeader)}
+		json.Unmarshal(raw, &d.body)
+		deliveries <- d
+	}))
+	defer receiver.Close()
+
+	sender := webhook.New(webhook.Settings{Secret: secret, MaxAttempts: 3, Backoff: time.Millisecond, AllowedHosts: []string{"127.0.0.1"}})
+	r := newJobRouter(t, newTestTranslationHandler(t, "http://127.0.0.1:0"), sender)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/jobs", strings.NewReader(`{"text":"Save","target":"pseudo","callback_url":"`+receiver.URL+`/hook"}`))
+	req.Header.Set("Content-Type", "application/json")
+	job, _ := serveJob(t, r, req, http.StatusAccepted)
+
+	var d delivery
+	select {
+	case d = <-deliveries:
+	case <-time.After(5 * time.Second):
+		t.Fatal("No callback received")
+	}
+	if d.event != "job.succeeded" || d.body.Event != "job.succeeded" || d.body.Job.ID != job.ID || d.body.Job.Status != "succeeded" {
+		t.Errorf("Unexpected callback %+v", d)
+	}
+	if d.body.Job.Result["url"] != "/api/jobs/"+job.ID+"/result" {
+		t.Errorf("Unexpected callback result %v", d.body.Job.Result)
+	}
+
+	var log struct {
+		Notified   bool              `json:"notified"`
+		Deliveries []webhook.Attempt `json:"deliveries"`
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !log.Notified && time.Now().Before(deadline) {
+		_, w := serveJob(t, r, httptest.NewRequest(http.MethodGet, "/api/jobs/"+job.ID+"/deliveries", nil), http.StatusOK)
+		json.Unmarshal(w.Body.Bytes(), &log)
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !log.Notified || len(log.Deliveries) != 2 || log.Deliveries[0].StatusCode != 503 || !log.Deliveries[1].Success {
+		t.Errorf("Unexpected delivery log %+v", log)
+	}
+
+	// The callback URL is not shown to whoever knows the job ID
+	_, w := serveJob(t, r, httptest.NewRequest(http.MethodGet, "/api/jobs/"+job.ID+"/deliveries", nil), http.StatusOK)
+	if strings.Contains(w.Body.String(), receiver.URL) || strings.Contains(w.Body.String(), "callback_url") {
+		t.Errorf("Delivery log leaks the callback URL: %s", w.Body.String())
+	}
+	_, w = serveJob(t, r, httptest.NewRequest(http.MethodGet, "/api/jobs/"+job.ID, nil), http.StatusOK)
+	if strings.Contains(w.Body.String(), receiver.URL) {
+		t.Errorf("Job leaks the callback URL: %s", w.Body.String())
+	}
+
+	// Callback URLs must be absolute http(s) URLs of public or allowed hosts
+	for _, callback := range []string{"/hook", "http://10.0.0.8/hook", "http://169.254.169.254/latest/meta-data"} {
+		req = httptest.NewRequest(http.MethodPost, "/api/jobs", strings.NewReader(`{"text":"Save","target":"pseudo","callback_url":"`+callback+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		serveJob(t, r, req, http.StatusBadRequest)
+	}
+}
+
+func TestTranslateLongTextKeepsParagraphBreaks(t *testing.T) {
+	// Paragraphs long enough to land in different chunks
+	paragraph := strings.Repeat("word ", 100)
+	text := "\n" + paragraph + "\n\n" + paragraph + "\n\n\n" + paragraph + "\n"
+	var chunks []string
+	upper := func(_ context.Context, segments []string) ([]string, error) {
+		chunks = segments
+		out := make([]string, len(segments))
+		for i, s := range segments {
+			out[i] = strings.ToUpper(s)
+		}
+		return out, nil
+	}
+
+	out, _, err := translateLongText(context.Background(), []byte(text), "zh", false, upper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) < 2 {
+		t.Fatalf("Expected several chunks, got %d", len(chunks))
+	}
+	if want := strings.ToUpper(text); string(out) != want {
+		t.Errorf("Expected the breaks between chunks to survive, got %q", out)
+	}
+}
@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/LouisLau-art/go-translator/logging"
+)
+
+// respondError writes the standard error payload including the request ID
+func respondError(c *gin.Context, status int, message string) {
+	c.JSON(status, gin.H{
+		"success":    false,
+		"error":      message,
+		"request_id": logging.RequestIDFromContext(c.Request.Context()),
+	})
+}
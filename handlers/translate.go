@@ -1,114 +1,504 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"math"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/time/rate"
 
 	"github.com/LouisLau-art/go-translator/api"
 	"github.com/LouisLau-art/go-translator/cache"
+	"github.com/LouisLau-art/go-translator/formats/html"
+	"github.com/LouisLau-art/go-translator/langdetect"
+	"github.com/LouisLau-art/go-translator/logging"
+	"github.com/LouisLau-art/go-translator/metrics"
+	"github.com/LouisLau-art/go-translator/pseudo"
+	"github.com/LouisLau-art/go-translator/tracing"
 )
 
 // TranslationHandler handles translation requests
 type TranslationHandler struct {
-	apiClient *api.DoubaoClient
-	cache     *cache.TranslatorCache
-	limiter   *rate.Limiter
-	maxLength int
+	translator api.Translator
+	cache      *cache.TranslatorCache
+	limiter    *rate.Limiter
+
+	// settings is replaced as a whole, so a reload never shows requests
+	// a mix of old and new values
+	settingsMu sync.Mutex
+	settings   atomic.Pointer[handlerSettings]
+}
+
+// Settings are the handler settings that can change at runtime
+type Settings struct {
+	MaxLength       int
+	MaxDocumentSize int
+	DefaultModel    string
+	// AllowedModels are the models requests may select besides the
+	// default, which is always allowed
+	AllowedModels []string
+	// PseudoExpansion is how much longer, in percent, pseudo-localized
+	// text is made
+	PseudoExpansion int
+}
+
+// handlerSettings is a published snapshot of Settings
+type handlerSettings struct {
+	maxLength       int
+	maxDocumentSize int64
+	models          modelPolicy
+	pseudoExpansion int
+}
+
+// modelPolicy is the default model plus the models a request may select
+type modelPolicy struct {
+	defaultModel string
+	allowed      []string
 }
 
 // NewTranslationHandler creates a new translation handler
-func NewTranslationHandler(apiClient *api.DoubaoClient, cache *cache.TranslatorCache, limiter *rate.Limiter, maxLength int) *TranslationHandler {
-	return &TranslationHandler{
-		apiClient: apiClient,
-		cache:     cache,
-		limiter:   limiter,
-		maxLength: maxLength,
+func NewTranslationHandler(translator api.Translator, cache *cache.TranslatorCache, limiter *rate.Limiter, maxLength int) *TranslationHandler {
+	h := &TranslationHandler{
+		translator: translator,
+		cache:      cache,
+		limiter:    limiter,
+	}
+	h.Update(Settings{
+		MaxLength:       maxLength,
+		MaxDocumentSize: DefaultMaxDocumentSize,
+		DefaultModel:    api.DefaultModel,
+		PseudoExpansion: pseudo.DefaultExpansion,
+	})
+	return h
+}
+
+// Update publishes new settings all at once
+func (h *TranslationHandler) Update(settings Settings) {
+	h.settingsMu.Lock()
+	defer h.settingsMu.Unlock()
+	h.settings.Store(&handlerSettings{
+		maxLength:       settings.MaxLength,
+		maxDocumentSize: int64(settings.MaxDocumentSize),
+		models:          newModelPolicy(settings.DefaultModel, settings.AllowedModels),
+		pseudoExpansion: settings.PseudoExpansion,
+	})
+}
+
+// change publishes a copy of the current settings with one change
+func (h *TranslationHandler) change(fn func(*handlerSettings)) {
+	h.settingsMu.Lock()
+	defer h.settingsMu.Unlock()
+	next := *h.settings.Load()
+	fn(&next)
+	h.settings.Store(&next)
+}
+
+// SetPseudoExpansion sets how much longer, in percent, pseudo-localized
+// text is made
+func (h *TranslationHandler) SetPseudoExpansion(percent int) {
+	h.change(func(s *handlerSettings) { s.pseudoExpansion = percent })
+}
+
+// SetModels sets the default model and the models requests may override it
+// with. The default model is always allowed.
+func (h *TranslationHandler) SetModels(defaultModel string, allowed []string) {
+	h.change(func(s *handlerSettings) { s.models = newModelPolicy(defaultModel, allowed) })
+}
+
+func newModelPolicy(defaultModel string, allowed []string) modelPolicy {
+	models := []string{defaultModel}
+	for _, m := range allowed {
+		if m != "" && m != defaultModel {
+			models = append(models, m)
+		}
+	}
+	return modelPolicy{defaultModel: defaultModel, allowed: models}
+}
+
+// resolveModel returns the model to use for a request, or false when the
+// requested model is not allowed
+func (h *TranslationHandler) resolveModel(requested string) (string, bool) {
+	policy := h.settings.Load().models
+	if requested == "" {
+		return policy.defaultModel, true
 	}
+	for _, m := range policy.allowed {
+		if m == requested {
+			return requested, true
+		}
+	}
+	return "", false
+}
+
+// HandleModels lists the models a translation request may select
+func (h *TranslationHandler) HandleModels(c *gin.Context) {
+	policy := h.settings.Load().models
+	c.JSON(200, gin.H{
+		"success": true,
+		"default": policy.defaultModel,
+		"models":  policy.allowed,
+	})
+}
+
+// SetMaxLength changes the maximum accepted text length at runtime
+func (h *TranslationHandler) SetMaxLength(maxLength int) {
+	h.change(func(s *handlerSettings) { s.maxLength = maxLength })
+}
+
+// maxBatchItems caps how many texts one batch request may contain
+const maxBatchItems = 100
+
+// minDetectConfidence is the confidence at which a detected source
+// language is used instead of leaving detection to the provider
+const minDetectConfidence = 0.8
+
+// translation is the outcome of translating one text
+type translation struct {
+	Text     string `json:"text"`
+	Model    string `json:"model"`
+	Provider string `json:"provider,omitempty"`
+	Fallback bool   `json:"fallback"`
+	Cached   bool   `json:"cached"`
+
+	// DetectedSource and Confidence are set when the source was left to
+	// automatic detection
+	DetectedSource string  `json:"detected_source,omitempty"`
+	Confidence     float64 `json:"confidence,omitempty"`
+}
+
+// requestError is a failed translation with the status to respond with
+type requestError struct {
+	status     int
+	message    string
+	retryAfter time.Duration
+}
+
+func (e *requestError) Error() string {
+	return e.message
+}
+
+func (e *requestError) respond(c *gin.Context) {
+	if e.retryAfter > 0 {
+		c.Header("Retry-After", retryAfterSeconds(e.retryAfter))
+	}
+	respondError(c, e.status, e.message)
 }
 
 // HandleTranslate processes translation requests
 func (h *TranslationHandler) HandleTranslate(c *gin.Context) {
+	ctx, span := tracing.Start(c.Request.Context(), "HandleTranslate")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+
 	// Check rate limit
 	if !h.limiter.Allow() {
-		c.JSON(429, gin.H{
-			"success": false,
-			"error":   "请求过于频繁，请稍后再试",
-		})
+		metrics.RateLimitRejections.Inc()
+		respondError(c, 429, "请求过于频繁，请稍后再试")
 		return
 	}
 
 	// Parse request
 	var req api.TranslateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		log.Printf("Request bind error: %v", err)
-		c.JSON(400, gin.H{
-			"success": false,
-			"error":   "请求格式错误: " + err.Error(),
-		})
+		logger.Warn("request bind error", slog.Any("error", err))
+		respondError(c, 400, "请求格式错误: "+err.Error())
 		return
 	}
 
-	log.Printf("Translation request: text length=%d, source=%s, target=%s",
-		len(req.Text), req.Source, req.Target)
+	result, reqErr := h.translate(ctx, req)
+	if reqErr != nil {
+		reqErr.respond(c)
+		return
+	}
 
-	// Validate text length
-	if len(req.Text) > h.maxLength {
-		c.JSON(400, gin.H{
-			"success": false,
-			"error":   fmt.Sprintf("文本长度超过限制（最大%d字符）", h.maxLength),
-		})
+	resp := gin.H{
+		"success":  true,
+		"text":     result.Text,
+		"model":    result.Model,
+		"provider": result.Provider,
+		"fallback": result.Fallback,
+		"cached":   result.Cached,
+	}
+	if result.DetectedSource != "" {
+		resp["detected_source"] = result.DetectedSource
+		resp["confidence"] = result.Confidence
+	}
+	c.JSON(200, resp)
+}
+
+// HandleTranslateBatch translates several texts with the same languages,
+// model and options. It fails as a whole if any text fails.
+func (h *TranslationHandler) HandleTranslateBatch(c *gin.Context) {
+	ctx, span := tracing.Start(c.Request.Context(), "HandleTranslateBatch")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+
+	// A batch counts as a single request against the rate limit
+	if !h.limiter.Allow() {
+		metrics.RateLimitRejections.Inc()
+		respondError(c, 429, "请求过于频繁，请稍后再试")
 		return
 	}
 
-	// Check cache
-	cacheKey := cache.GetCacheKey(req.Text, req.Source, req.Target)
-	if cached, ok := h.cache.Get(cacheKey); ok {
-		log.Printf("Cache hit for key: %s", cacheKey)
-		c.JSON(200, gin.H{
-			"success": true,
-			"text":    cached,
-			"cached":  true,
-		})
+	var req api.BatchTranslateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Warn("request bind error", slog.Any("error", err))
+		respondError(c, 400, "请求格式错误: "+err.Error())
+		return
+	}
+	if len(req.Texts) > maxBatchItems {
+		respondError(c, 400, fmt.Sprintf("批量条数超过限制（最多%d条）", maxBatchItems))
 		return
 	}
+	span.SetAttributes(attribute.Int("translation.batch_size", len(req.Texts)))
+
+	results := make([]*translation, len(req.Texts))
+	for i, text := range req.Texts {
+		result, reqErr := h.translate(ctx, api.TranslateRequest{
+			Text:    text,
+			Source:  req.Source,
+			Target:  req.Target,
+			Model:   req.Model,
+			Options: req.Options,
+			Format:  req.Format,
+		})
+		if reqErr != nil {
+			reqErr.message = fmt.Sprintf("第%d条: %s", i+1, reqErr.message)
+			reqErr.respond(c)
+			return
+		}
+		results[i] = result
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"results": results,
+	})
+}
+
+// translate validates one text, detects its source language if needed and
+// translates it
+func (h *TranslationHandler) translate(ctx context.Context, req api.TranslateRequest) (*translation, *requestError) {
+	span := trace.SpanFromContext(ctx)
+	logger := logging.FromContext(ctx)
+
+	logger.Info("translation request",
+		slog.Int("text_length", len(req.Text)),
+		slog.String("source", req.Source),
+		slog.String("target", req.Target))
+	logger.Debug("translation input", logging.Text("text", req.Text))
+	span.SetAttributes(
+		attribute.Int("translation.text_length", len(req.Text)),
+		attribute.String("translation.source_language", req.Source),
+		attribute.String("translation.target_language", req.Target),
+	)
+
+	// Validate text length
+	if maxLength := h.settings.Load().maxLength; len(req.Text) > maxLength {
+		return nil, &requestError{status: 400, message: fmt.Sprintf("文本长度超过限制（最大%d字符）", maxLength)}
+	}
+	switch req.Format {
+	case "", "text":
+	case "html":
+		return h.translateHTML(ctx, req)
+	default:
+		return nil, &requestError{status: 400, message: "不支持的格式: " + req.Format}
+	}
+
+	// Detect the source language locally. A confident guess is used like
+	// an explicit source, so cache entries are shared with such requests.
+	var detected langdetect.Result
+	if req.Target != pseudo.Target && (req.Source == "" || req.Source == "auto") {
+		req.Source, detected = h.detectSource(ctx, req.Text)
+	}
+
+	out, reqErr := h.translateText(ctx, req)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	out.DetectedSource, out.Confidence = detected.Language, detected.Confidence
+	return out, nil
+}
+
+// translateHTML translates the text and attributes of HTML markup,
+// sentence by sentence, through the document segment path
+func (h *TranslationHandler) translateHTML(ctx context.Context, req api.TranslateRequest) (*translation, *requestError) {
+	model, ok := h.resolveModel(req.Model)
+	if !ok {
+		return nil, &requestError{status: 400, message: "不支持的模型: " + req.Model}
+	}
+	req.Format = ""
+
+	var detected langdetect.Result
+	out, _, err := html.Translate(ctx, []byte(req.Text), req.Target, h.translateSegments(req, &detected, false, nil))
+	if err != nil {
+		var reqErr *requestError
+		if errors.As(err, &reqErr) {
+			return nil, reqErr
+		}
+		return nil, &requestError{status: 400, message: "HTML 解析失败: " + err.Error()}
+	}
+	return &translation{
+		Text:           string(out),
+		Model:          model,
+		DetectedSource: detected.Language,
+		Confidence:     detected.Confidence,
+	}, nil
+}
+
+// detectSource detects the language of text, returning the source to
+// translate from: the detected language, or "" when it is uncertain
+func (h *TranslationHandler) detectSource(ctx context.Context, text string) (string, langdetect.Result) {
+	span := trace.SpanFromContext(ctx)
+	detected := langdetect.Detect(text)
+	span.SetAttributes(
+		attribute.String("translation.detected_source", detected.Language),
+		attribute.Float64("translation.detection_confidence", detected.Confidence),
+	)
+	logging.FromContext(ctx).Debug("detected source language",
+		slog.String("language", detected.Language),
+		slog.Float64("confidence", detected.Confidence))
+	if detected.Confidence >= minDetectConfidence {
+		return detected.Language, detected
+	}
+	return "", detected
+}
+
+// translateText translates a text whose length and source language have
+// already been settled, through the cache, chunking and the provider chain
+func (h *TranslationHandler) translateText(ctx context.Context, req api.TranslateRequest) (*translation, *requestError) {
+	span := trace.SpanFromContext(ctx)
+	logger := logging.FromContext(ctx)
+
+	// Pseudo-localization is computed locally and never cached
+	if req.Target == pseudo.Target {
+		return &translation{
+			Text:     pseudo.Localize(req.Text, h.settings.Load().pseudoExpansion),
+			Model:    pseudo.Target,
+			Provider: pseudo.Target,
+		}, nil
+	}
+
+	// Resolve model and options
+	model, ok := h.resolveModel(req.Model)
+	if !ok {
+		return nil, &requestError{status: 400, message: "不支持的模型: " + req.Model}
+	}
+	if err := api.ValidateExtraOptions(req.Options); err != nil {
+		return nil, &requestError{status: 400, message: "请求参数错误: " + err.Error()}
+	}
+	span.SetAttributes(attribute.String("translation.model", model))
+
+	// Check cache
+	variant := []string{model, canonicalOptions(req.Options)}
+	if req.Context != "" {
+		// Identical texts in different contexts may translate differently
+		variant = append(variant, req.Context)
+	}
+	cacheKey := cache.GetCacheKey(req.Text, req.Source, req.Target, variant...)
+	if cached, ok := h.cacheGet(ctx, cacheKey); ok {
+		logger.Debug("cache hit", slog.String("cache_key", cacheKey))
+		span.SetAttributes(attribute.Bool("translation.cached", true))
+		return &translation{Text: cached, Model: model, Cached: true}, nil
+	}
 
 	// Split text into chunks for long documents
+	_, splitSpan := tracing.Start(ctx, "smartSplit")
 	chunks := smartSplit(req.Text, 800)
-	log.Printf("Split text into %d chunks", len(chunks))
+	splitSpan.SetAttributes(attribute.Int("translation.chunks", len(chunks)))
+	splitSpan.End()
+	logger.Debug("split text into chunks", slog.Int("chunks", len(chunks)))
+	metrics.TranslationChunks.Observe(float64(len(chunks)))
+	span.SetAttributes(attribute.Int("translation.chunks", len(chunks)))
 
 	results := make([]string, len(chunks))
+	out := &translation{Model: model}
 
 	// Process each chunk
 	for i, chunk := range chunks {
-		result, err := h.apiClient.Translate(chunk, req.Source, req.Target)
+		result, err := h.translator.Translate(ctx, chunk, req.Source, req.Target, api.Options{Model: req.Model, Extra: req.Options, Context: req.Context})
 		if err != nil {
-			log.Printf("API call error for chunk %d: %v", i, err)
-			c.JSON(500, gin.H{
-				"success": false,
-				"error":   "翻译失败: " + err.Error(),
-			})
-			return
+			logger.Error("translation failed", slog.Int("chunk", i), slog.Any("error", err))
+			tracing.RecordError(span, err)
+			var unavailable *api.UnavailableError
+			if errors.As(err, &unavailable) {
+				return nil, &requestError{status: 503, message: "翻译服务暂时不可用，请稍后再试", retryAfter: unavailable.RetryAfter}
+			}
+			return nil, &requestError{status: 500, message: "翻译失败: " + err.Error()}
 		}
-		results[i] = result
+		results[i] = result.Text
+		out.Provider = result.Provider
+		if result.Model != "" {
+			out.Model = result.Model
+		}
+		out.Fallback = out.Fallback || result.Fallback
 	}
+	span.SetAttributes(attribute.String("translation.provider", out.Provider))
 
 	// Combine results
-	finalText := strings.Join(results, "\n")
+	out.Text = strings.Join(results, "\n")
 
-	// Save to cache
-	if err := h.cache.Set(cacheKey, finalText); err != nil {
-		log.Printf("Cache set error: %v", err)
+	// Save to cache, unless a fallback provider stood in for the one
+	// requested so the primary's translation is used once it recovers
+	if !out.Fallback {
+		if err := h.cacheSet(ctx, cacheKey, out.Text); err != nil {
+			logger.Warn("cache set error", slog.Any("error", err))
+		}
 	}
+	return out, nil
+}
 
-	c.JSON(200, gin.H{
-		"success": true,
-		"text":    finalText,
-		"cached":  false,
-	})
+// retryAfterSeconds formats d for a Retry-After header, rounding up
+func retryAfterSeconds(d time.Duration) string {
+	seconds := int64(math.Ceil(d.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.FormatInt(seconds, 10)
+}
+
+// canonicalOptions renders request options deterministically for cache keys
+func canonicalOptions(options map[string]interface{}) string {
+	if len(options) == 0 {
+		return ""
+	}
+	// encoding/json sorts map keys, so equal options encode equally
+	data, err := json.Marshal(options)
+	if err != nil {
+		return fmt.Sprint(options)
+	}
+	return string(data)
+}
+
+// cacheGet looks up key in the cache inside its own span
+func (h *TranslationHandler) cacheGet(ctx context.Context, key string) (string, bool) {
+	_, span := tracing.Start(ctx, "cache.Get", trace.WithAttributes(attribute.String("cache.key", key)))
+	defer span.End()
+
+	value, ok := h.cache.Get(key)
+	span.SetAttributes(attribute.Bool("cache.hit", ok))
+	return value, ok
+}
+
+// cacheSet stores value in the cache inside its own span
+func (h *TranslationHandler) cacheSet(ctx context.Context, key, value string) error {
+	_, span := tracing.Start(ctx, "cache.Set", trace.WithAttributes(attribute.String("cache.key", key)))
+	defer span.End()
+
+	err := h.cache.Set(key, value)
+	tracing.RecordError(span, err)
+	return err
 }
 
 // smartSplit splits text into chunks, trying to preserve paragraph boundaries
@@ -158,4 +548,4 @@ func smartSplit(text string, maxChars int) []string {
 	}
 
 	return chunks
-}
\ No newline at end of file
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+
+	"github.com/LouisLau-art/go-translator/api"
+	"github.com/LouisLau-art/go-translator/breaker"
+	"github.com/LouisLau-art/go-translator/cache"
+)
+
+func newTestTranslationHandler(t *testing.T, apiURL string) *TranslationHandler {
+	t.Helper()
+	c := cache.NewTranslatorCache(time.Hour, 100)
+	t.Cleanup(c.StopCleanup)
+	client := api.NewDoubaoClient("test-key", apiURL, api.WithMaxRetries(0))
+	return NewTranslationHandler(client, c, rate.NewLimiter(rate.Inf, 1), 5000)
+}
+
+func TestHandleModelsListsAllowedModels(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := newTestTranslationHandler(t, "http://127.0.0.1:0")
+	h.SetModels("model-a", []string{"model-b", "model-a"})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	h.HandleModels(c)
+
+	var body struct {
+		Default string   `json:"default"`
+		Models  []string `json:"models"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body.Default != "model-a" || strings.Join(body.Models, ",") != "model-a,model-b" {
+		t.Errorf("Unexpected models response: %s", w.Body.String())
+	}
+}
+
+func TestHandleTranslateRejectsUnknownModel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	called := false
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer upstream.Close()
+
+	h := newTestTranslationHandler(t, upstream.URL)
+	h.SetModels("model-a", nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/translate",
+		strings.NewReader(`{"text":"hello","source":"en","target":"zh","model":"model-x"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	h.HandleTranslate(c)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+	if called {
+		t.Error("Expected upstream not to be called for a disallowed model")
+	}
+}
+
+func TestHandleTranslateFailsFastWhenBreakerOpen(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	calls := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer upstream.Close()
+
+	c := cache.NewTranslatorCache(time.Hour, 100)
+	t.Cleanup(c.StopCleanup)
+	client := api.NewDoubaoClient("test-key", upstream.URL, api.WithMaxRetries(0))
+	router := api.NewRouter([]api.Route{{Translator: client}},
+		api.WithBreaker(breaker.Settings{FailureThreshold: 1, Cooldown: 90 * time.Second}))
+	h := NewTranslationHandler(router, c, rate.NewLimiter(rate.Inf, 1), 5000)
+
+	translate := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/api/translate",
+			strings.NewReader(`{"text":"hello","source":"en","target":"zh"}`))
+		ctx.Request.Header.Set("Content-Type", "application/json")
+		h.HandleTranslate(ctx)
+		return w
+	}
+
+	if w := translate(); w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected first failure to return 500, got %d", w.Code)
+	}
+
+	w := translate()
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 while the breaker is open, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Retry-After"); got != "90" {
+		t.Errorf("Expected Retry-After of 90 seconds, got %q", got)
+	}
+	if calls != 1 {
+		t.Errorf("Expected open breaker to skip upstream, got %d calls", calls)
+	}
+}
+
+func TestHandleTranslateBatchPseudo(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	called := false
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer upstream.Close()
+
+	h := newTestTranslationHandler(t, upstream.URL)
+	h.SetPseudoExpansion(0)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/translate/batch",
+		strings.NewReader(`{"texts":["Save","Hello {name}"],"target":"pseudo"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	h.HandleTranslateBatch(c)
+
+	var body struct {
+		Results []translation `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 with results, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(body.Results) != 2 || body.Results[0].Text != "[Šáṽé]" || body.Results[1].Text != "[Ĥéļļö {name}]" {
+		t.Errorf("Unexpected pseudo results: %+v", body.Results)
+	}
+	if called {
+		t.Error("Expected pseudo-localization not to call the upstream")
+	}
+}
+
+func TestHandleTranslateHTML(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := newTestTranslationHandler(t, "http://127.0.0.1:0")
+	h.SetPseudoExpansion(0)
+
+	for _, tc := range []struct {
+		body   string
+		status int
+		want   string
+	}{
+		{`{"text":"<p title=\"Tip\">Save <b>all</b></p>","target":"pseudo","format":"html"}`, 200, `<p title="[Ţíþ]">[Šáṽé <b>áļļ</b>]</p>`},
+		{`{"text":"<p>Save</p>","target":"pseudo","format":"rtf"}`, 400, "不支持的格式: rtf"},
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/translate", strings.NewReader(tc.body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		h.HandleTranslate(c)
+
+		var body struct {
+			Text  string `json:"text"`
+			Error string `json:"error"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		if w.Code != tc.status || body.Text+body.Error != tc.want {
+			t.Errorf("Expected %d with %s, got %d: %s", tc.status, tc.want, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestHandleTranslateDetectsSource(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c := cache.NewTranslatorCache(time.Hour, 100)
+	t.Cleanup(c.StopCleanup)
+	h := NewTranslationHandler(api.NewOfflineClient(), c, rate.NewLimiter(rate.Inf, 1), 5000)
+
+	translate := func(source string) map[string]interface{} {
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/api/translate",
+			strings.NewReader(`{"text":"Please save your changes before you leave.","source":"`+source+`","target":"zh"}`))
+		ctx.Request.Header.Set("Content-Type", "application/json")
+		h.HandleTranslate(ctx)
+
+		var body map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return body
+	}
+
+	body := translate("")
+	if body["detected_source"] != "en" || body["confidence"].(float64) < minDetectConfidence {
+		t.Fatalf("Expected English to be detected confidently, got %v", body)
+	}
+
+	// The detected code is part of the cache key, so an explicit request
+	// for the same source hits the entry written by the automatic one
+	body = translate("en")
+	if body["cached"] != true {
+		t.Errorf("Expected explicit source to share the cache entry, got %v", body)
+	}
+	if _, ok := body["detected_source"]; ok {
+		t.Errorf("Expected no detection for an explicit source, got %v", body)
+	}
+}
+
+func TestHandleTranslateCachesPerContext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c := cache.NewTranslatorCache(time.Hour, 100)
+	t.Cleanup(c.StopCleanup)
+	h := NewTranslationHandler(api.NewOfflineClient(), c, rate.NewLimiter(rate.Inf, 1), 5000)
+
+	cached := func(context string) interface{} {
+		w := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(w)
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/api/translate",
+			strings.NewReader(`{"text":"Hello","source":"en","target":"zh","context":"`+context+`"}`))
+		ctx.Request.Header.Set("Content-Type", "application/json")
+		h.HandleTranslate(ctx)
+
+		var body map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return body["cached"]
+	}
+
+	if cached("greeting") != false || cached("menu") != false {
+		t.Error("Expected each context to get its own translation")
+	}
+	if cached("greeting") != true {
+		t.Error("Expected a repeated context to hit the cache")
+	}
+}
@@ -0,0 +1,470 @@
+// Package jobs runs long translations in the background. Jobs wait in a
+// queue for a fixed pool of workers, report their progress while they run
+// and are kept in a directory with their input and result, so they
+// survive restarts: jobs that were queued or running when the server
+// stopped are queued again when it starts. Jobs submitted with a callback
+// URL notify it when they succeed or fail.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/LouisLau-art/go-translator/metrics"
+	"github.com/LouisLau-art/go-translator/webhook"
+)
+
+// Status is the stage a job is at
+type Status string
+
+const (
+	Queued    Status = "queued"
+	Running   Status = "running"
+	Succeeded Status = "succeeded"
+	Failed    Status = "failed"
+	Cancelled Status = "cancelled"
+)
+
+// Finished reports whether a job with this status will not run again
+func (s Status) Finished() bool {
+	return s == Succeeded || s == Failed || s == Cancelled
+}
+
+var (
+	// ErrNotFound is returned for unknown or expired jobs
+	ErrNotFound = errors.New("job not found")
+	// ErrFinished is returned when cancelling a job that already ended
+	ErrFinished = errors.New("job already finished")
+	// ErrNoResult is returned for the result of a job that did not succeed
+	ErrNoResult = errors.New("job has no result")
+	// ErrQueueFull is returned when no more jobs can be queued
+	ErrQueueFull = errors.New("job queue is full")
+)
+
+// Causes a running job's context is cancelled with
+var (
+	errCancelled = errors.New("job cancelled")
+	errShutdown  = errors.New("job manager shutting down")
+)
+
+// Progress counts the units of work of a job, such as chunks or segments.
+// Total grows as the runner discovers more work.
+type Progress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// Job is a background translation
+type Job struct {
+	ID       string   `json:"id"`
+	Status   Status   `json:"status"`
+	Progress Progress `json:"progress"`
+	Error    string   `json:"error,omitempty"`
+
+	// Spec describes the work for the runner, which defines its layout
+	Spec json.RawMessage `json:"spec"`
+	// Result describes the output of a job that succeeded
+	Result *Result `json:"result,omitempty"`
+
+	// Callback is the URL notified when the job succeeds or fails
+	Callback string `json:"callback,omitempty"`
+	// Deliveries logs the attempts to notify Callback
+	Deliveries []webhook.Attempt `json:"deliveries,omitempty"`
+	// Notified is set once the notification was delivered or given up on
+	Notified bool `json:"notified,omitempty"`
+
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Result describes the output of a job; the output itself is stored
+// separately
+type Result struct {
+	ContentType string `json:"content_type"`
+	Filename    string `json:"filename"`
+	Size        int    `json:"size"`
+	// Meta holds runner-specific details, such as a detected language
+	Meta map[string]string `json:"meta,omitempty"`
+}
+
+// Runner does the work of a job: it translates the input, calls report as
+// units of work complete and returns the output. It must return once ctx
+// is cancelled.
+type Runner func(ctx context.Context, job Job, input []byte, report func(Progress)) (Result, []byte, error)
+
+// Notifier tells the callback of a job that succeeded or failed, passing
+// each delivery attempt to record. It must return once ctx is cancelled.
+type Notifier func(ctx context.Context, job Job, record func(webhook.Attempt)) error
+
+// Options configures a Manager
+type Options struct {
+	// Dir is the directory jobs are kept in
+	Dir string
+	// Workers is how many jobs run at once
+	Workers int
+	// QueueSize caps the jobs waiting for a worker
+	QueueSize int
+	// Retention is how long finished jobs are kept; 0 keeps them
+	Retention time.Duration
+	// Notify delivers callbacks; without it callbacks are not sent
+	Notify Notifier
+}
+
+// DefaultQueueSize is the queue size used when Options leaves it unset
+const DefaultQueueSize = 1000
+
+// Manager queues, runs and keeps jobs
+type Manager struct {
+	store     *store
+	run       Runner
+	notify    Notifier
+	retention time.Duration
+	queue     chan string
+
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	dirty   map[string]bool // jobs whose progress has not been saved
+	cancels map[string]context.CancelCauseFunc
+
+	// ctx lives until Close and bounds the workers and callbacks
+	ctx  context.Context
+	stop context.CancelCauseFunc
+	wg   sync.WaitGroup
+}
+
+// Open loads the jobs kept in opts.Dir, queues again those that had not
+// finished, resumes callbacks that were not delivered and starts the
+// workers
+func Open(opts Options, run Runner) (*Manager, error) {
+	s, err := openStore(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	loaded, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(loaded, func(a, b *Job) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	queueSize := opts.QueueSize
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+	m := &Manager{
+		store:     s,
+		run:       run,
+		notify:    opts.Notify,
+		retention: opts.Retention,
+		queue:     make(chan string, max(queueSize, len(loaded))),
+		jobs:      make(map[string]*Job, len(loaded)),
+		dirty:     make(map[string]bool),
+		cancels:   make(map[string]context.CancelCauseFunc),
+	}
+	for _, job := range loaded {
+		m.jobs[job.ID] = job
+		if job.Status.Finished() {
+			continue
+		}
+		// Work done before the restart is redone; the translation cache
+		// makes that cheap
+		job.Status, job.Progress = Queued, Progress{}
+		m.save(job)
+		m.queue <- job.ID
+	}
+	m.prune(time.Now())
+	if resumed := len(m.queue); resumed > 0 {
+		slog.Info("resuming unfinished jobs", slog.Int("jobs", resumed))
+	}
+
+	m.ctx, m.stop = context.WithCancelCause(context.Background())
+	m.mu.Lock()
+	for _, job := range m.jobs {
+		if m.awaitingCallback(job) {
+			// Retried from the first attempt
+			m.notifyCallback(job)
+		}
+	}
+	m.mu.Unlock()
+	for range max(opts.Workers, 1) {
+		m.wg.Add(1)
+		go m.work(m.ctx)
+	}
+	return m, nil
+}
+
+// Submit stores a new job and queues it. When callback is not empty it is
+// notified once the job succeeds or fails.
+func (m *Manager) Submit(spec json.RawMessage, input []byte, callback string) (Job, error) {
+	id, err := newID()
+	if err != nil {
+		return Job{}, err
+	}
+	now := time.Now()
+	job := &Job{ID: id, Status: Queued, Spec: spec, Callback: callback, CreatedAt: now, UpdatedAt: now}
+	if err := m.store.write(id, inputBlob, input); err != nil {
+		return Job{}, fmt.Errorf("store job input: %w", err)
+	}
+	if err := m.store.save(job); err != nil {
+		m.store.remove(id)
+		return Job{}, fmt.Errorf("store job: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	select {
+	case m.queue <- id:
+	default:
+		m.store.remove(id)
+		return Job{}, ErrQueueFull
+	}
+	m.jobs[id] = job
+	m.prune(now)
+	return *job, nil
+}
+
+// Get returns a job
+func (m *Manager) Get(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	return *job, nil
+}
+
+// Result returns a job that succeeded along with its output
+func (m *Manager) Result(id string) (Job, []byte, error) {
+	job, err := m.Get(id)
+	if err != nil {
+		return job, nil, err
+	}
+	if job.Status != Succeeded {
+		return job, nil, ErrNoResult
+	}
+	data, err := m.store.readBlob(id, resultBlob)
+	return job, data, err
+}
+
+// Cancel stops a queued or running job
+func (m *Manager) Cancel(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	if job.Status.Finished() {
+		return *job, ErrFinished
+	}
+	if cancel, ok := m.cancels[id]; ok {
+		cancel(errCancelled)
+	}
+	m.finish(job, Cancelled, "")
+	return *job, nil
+}
+
+// Close stops the workers and callbacks, waiting for them until ctx is
+// done. Jobs interrupted by the shutdown are saved as queued and resume on
+// the next Open, as do callbacks not yet delivered; the progress of the
+// other jobs is saved.
+func (m *Manager) Close(ctx context.Context) error {
+	m.stop(errShutdown)
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("waiting for running jobs: %w", ctx.Err())
+	}
+	return errors.Join(err, m.Flush())
+}
+
+// Flush saves the progress of running jobs
+func (m *Manager) Flush() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var errs []error
+	for id := range m.dirty {
+		if job, ok := m.jobs[id]; ok {
+			errs = append(errs, m.store.save(job))
+		}
+		delete(m.dirty, id)
+	}
+	return errors.Join(errs...)
+}
+
+// work runs queued jobs until ctx is cancelled
+func (m *Manager) work(ctx context.Context) {
+	defer m.wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-m.queue:
+			m.process(ctx, id)
+		}
+	}
+}
+
+// process runs one job and records its outcome
+func (m *Manager) process(parent context.Context, id string) {
+	m.mu.Lock()
+	job, ok := m.jobs[id]
+	if !ok || job.Status != Queued {
+		// Cancelled or expired while waiting
+		m.mu.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancelCause(parent)
+	defer cancel(nil)
+	m.cancels[id] = cancel
+	job.Status, job.UpdatedAt = Running, time.Now()
+	m.save(job)
+	snapshot := *job
+	m.mu.Unlock()
+
+	logger := slog.With(slog.String("job_id", id))
+	logger.Info("job started")
+	report := func(p Progress) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if job.Status == Running {
+			job.Progress, job.UpdatedAt = p, time.Now()
+			m.dirty[id] = true
+		}
+	}
+
+	var (
+		result Result
+		data   []byte
+	)
+	input, err := m.store.readBlob(id, inputBlob)
+	if err == nil {
+		result, data, err = m.run(ctx, snapshot, input, report)
+	}
+	if err == nil {
+		result.Size = len(data)
+		err = m.store.write(id, resultBlob, data)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.cancels, id)
+	cause := context.Cause(ctx)
+	switch {
+	case job.Status == Cancelled:
+		logger.Info("job cancelled")
+	case err != nil && errors.Is(cause, errShutdown):
+		// Left for the next start
+		job.Status, job.Progress, job.UpdatedAt = Queued, Progress{}, time.Now()
+		m.save(job)
+		logger.Info("job interrupted by shutdown")
+	case err != nil:
+		logger.Warn("job failed", slog.Any("error", err))
+		m.finish(job, Failed, err.Error())
+	default:
+		job.Result = &result
+		job.Progress.Done = job.Progress.Total
+		logger.Info("job succeeded", slog.Int("size", result.Size))
+		m.finish(job, Succeeded, "")
+	}
+}
+
+// finish records the final status of a job. m.mu must be held.
+func (m *Manager) finish(job *Job, status Status, message string) {
+	now := time.Now()
+	job.Status, job.Error = status, message
+	job.UpdatedAt, job.FinishedAt = now, &now
+	m.save(job)
+	metrics.JobsFinished.WithLabelValues(string(status)).Inc()
+	if m.awaitingCallback(job) {
+		m.notifyCallback(job)
+	}
+}
+
+// awaitingCallback reports whether a finished job still has to notify its
+// callback. Cancelled jobs do not notify: whoever cancelled them knows.
+func (m *Manager) awaitingCallback(job *Job) bool {
+	return m.notify != nil && job.Callback != "" && !job.Notified &&
+		(job.Status == Succeeded || job.Status == Failed)
+}
+
+// notifyCallback delivers the callback of a job in the background. m.mu
+// must be held once the workers have started.
+func (m *Manager) notifyCallback(job *Job) {
+	snapshot := *job
+	record := func(attempt webhook.Attempt) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		job.Deliveries = append(job.Deliveries, attempt)
+		m.save(job)
+	}
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		logger := slog.With(slog.String("job_id", job.ID))
+		err := m.notify(m.ctx, snapshot, record)
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if err != nil && errors.Is(context.Cause(m.ctx), errShutdown) {
+			// Left for the next start
+			return
+		}
+		if err != nil {
+			logger.Warn("job callback failed", slog.Any("error", err))
+		} else {
+			logger.Info("job callback delivered")
+		}
+		job.Notified = true
+		m.save(job)
+	}()
+}
+
+// save writes a job to the store, logging failures: the job carries on
+// in memory and is saved again at its next change. m.mu must be held.
+func (m *Manager) save(job *Job) {
+	delete(m.dirty, job.ID)
+	if err := m.store.save(job); err != nil {
+		slog.Warn("failed to save job", slog.String("job_id", job.ID), slog.Any("error", err))
+	}
+}
+
+// prune deletes finished jobs older than the retention. m.mu must be held
+// once the workers have started.
+func (m *Manager) prune(now time.Time) {
+	if m.retention <= 0 {
+		return
+	}
+	for id, job := range m.jobs {
+		if job.FinishedAt != nil && now.Sub(*job.FinishedAt) > m.retention && !m.awaitingCallback(job) {
+			delete(m.jobs, id)
+			if err := m.store.remove(id); err != nil {
+				slog.Warn("failed to remove expired job", slog.String("job_id", id), slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// newID returns a random job id
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
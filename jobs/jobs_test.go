@@ -0,0 +1,238 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LouisLau-art/go-translator/webhook"
+)
+
+// upper is a runner that upper-cases the input a byte at a time
+func upper(ctx context.Context, job Job, input []byte, report func(Progress)) (Result, []byte, error) {
+	for i := range input {
+		report(Progress{Done: i + 1, Total: len(input)})
+	}
+	return Result{ContentType: "text/plain", Filename: job.ID + ".txt"}, []byte(strings.ToUpper(string(input))), nil
+}
+
+// blocking is a runner that waits until its context is cancelled,
+// signalling started once it runs
+func blocking(started chan<- string) Runner {
+	return func(ctx context.Context, job Job, input []byte, report func(Progress)) (Result, []byte, error) {
+		report(Progress{Done: 1, Total: 3})
+		started <- job.ID
+		<-ctx.Done()
+		return Result{}, nil, ctx.Err()
+	}
+}
+
+func open(t *testing.T, opts Options, run Runner) *Manager {
+	t.Helper()
+	m, err := Open(opts, run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Close(context.Background()) })
+	return m
+}
+
+// wait polls a job until it reaches status
+func wait(t *testing.T, m *Manager, id string, status Status) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := m.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status == status {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s is %s, want %s", id, job.Status, status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestJobSucceeds(t *testing.T) {
+	m := open(t, Options{Dir: t.TempDir(), Workers: 2}, upper)
+	job, err := m.Submit([]byte(`{"target":"de"}`), []byte("hello"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != Queued || job.ID == "" {
+		t.Fatalf("submitted job = %+v", job)
+	}
+
+	job = wait(t, m, job.ID, Succeeded)
+	if job.Progress != (Progress{Done: 5, Total: 5}) || job.FinishedAt == nil || string(job.Spec) != `{"target":"de"}` {
+		t.Errorf("finished job = %+v", job)
+	}
+	job, data, err := m.Result(job.ID)
+	if err != nil || string(data) != "HELLO" {
+		t.Fatalf("result = %q, %v", data, err)
+	}
+	if job.Result.Size != 5 || job.Result.Filename != job.ID+".txt" {
+		t.Errorf("result = %+v", job.Result)
+	}
+	if _, err := m.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(missing) error = %v", err)
+	}
+}
+
+func TestJobFails(t *testing.T) {
+	m := open(t, Options{Dir: t.TempDir()}, func(context.Context, Job, []byte, func(Progress)) (Result, []byte, error) {
+		return Result{}, nil, errors.New("upstream down")
+	})
+	job, _ := m.Submit(nil, []byte("x"), "")
+	job = wait(t, m, job.ID, Failed)
+	if job.Error != "upstream down" {
+		t.Errorf("error = %q", job.Error)
+	}
+	if _, _, err := m.Result(job.ID); !errors.Is(err, ErrNoResult) {
+		t.Errorf("Result error = %v, want ErrNoResult", err)
+	}
+}
+
+func TestCancelJob(t *testing.T) {
+	started := make(chan string, 1)
+	m := open(t, Options{Dir: t.TempDir(), Workers: 1}, blocking(started))
+	running, _ := m.Submit(nil, []byte("a"), "")
+	queued, _ := m.Submit(nil, []byte("b"), "")
+	<-started
+
+	if job := wait(t, m, running.ID, Running); job.Progress != (Progress{Done: 1, Total: 3}) {
+		t.Errorf("progress = %+v", job.Progress)
+	}
+	for _, id := range []string{queued.ID, running.ID} {
+		if job, err := m.Cancel(id); err != nil || job.Status != Cancelled {
+			t.Fatalf("Cancel(%s) = %+v, %v", id, job, err)
+		}
+	}
+	if _, err := m.Cancel(running.ID); !errors.Is(err, ErrFinished) {
+		t.Errorf("second Cancel error = %v, want ErrFinished", err)
+	}
+
+	// The worker is free again and the cancelled job stays cancelled
+	next, _ := m.Submit(nil, []byte("c"), "")
+	if id := <-started; id != next.ID {
+		t.Errorf("worker ran %s, want %s", id, next.ID)
+	}
+	wait(t, m, running.ID, Cancelled)
+}
+
+func TestJobsSurviveRestart(t *testing.T) {
+	dir := t.TempDir()
+	started := make(chan string, 1)
+	m, err := Open(Options{Dir: dir, Workers: 1}, blocking(started))
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, _ := m.Submit(nil, []byte("done"), "")
+	<-started
+	m.Cancel(done.ID)
+	interrupted, _ := m.Submit(nil, []byte("interrupted"), "")
+	<-started
+	waiting, _ := m.Submit(nil, []byte("waiting"), "")
+	if err := m.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	m = open(t, Options{Dir: dir, Workers: 1}, upper)
+	if job := wait(t, m, done.ID, Cancelled); job.FinishedAt == nil {
+		t.Errorf("cancelled job = %+v", job)
+	}
+	for id, want := range map[string]string{interrupted.ID: "INTERRUPTED", waiting.ID: "WAITING"} {
+		wait(t, m, id, Succeeded)
+		if _, data, err := m.Result(id); err != nil || string(data) != want {
+			t.Errorf("result of %s = %q, %v", id, data, err)
+		}
+	}
+}
+
+func TestFinishedJobsExpire(t *testing.T) {
+	dir := t.TempDir()
+	m, err := Open(Options{Dir: dir}, upper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, _ := m.Submit(nil, []byte("x"), "")
+	wait(t, m, job.ID, Succeeded)
+	m.Close(context.Background())
+
+	m = open(t, Options{Dir: dir, Retention: time.Nanosecond}, upper)
+	if _, err := m.Get(job.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expired job error = %v, want ErrNotFound", err)
+	}
+}
+
+// notifier is a Notifier that fails the first failures attempts of each
+// job and sends the jobs it delivered on delivered
+func notifier(failures int, delivered chan<- Job) Notifier {
+	return func(ctx context.Context, job Job, record func(webhook.Attempt)) error {
+		for n := 1; n <= failures; n++ {
+			record(webhook.Attempt{Number: n, StatusCode: 500})
+		}
+		record(webhook.Attempt{Number: failures + 1, StatusCode: 200, Success: true})
+		delivered <- job
+		return nil
+	}
+}
+
+func TestJobNotifiesCallback(t *testing.T) {
+	delivered := make(chan Job, 2)
+	m := open(t, Options{Dir: t.TempDir(), Notify: notifier(1, delivered)}, upper)
+	job, _ := m.Submit(nil, []byte("x"), "http://cms.example/hook")
+	plain, _ := m.Submit(nil, []byte("y"), "")
+
+	if got := <-delivered; got.ID != job.ID || got.Status != Succeeded || got.Result == nil {
+		t.Fatalf("notified job = %+v", got)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, _ = m.Get(job.ID)
+		if job.Notified || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !job.Notified || len(job.Deliveries) != 2 || job.Deliveries[0].Success || !job.Deliveries[1].Success {
+		t.Errorf("delivery log = %+v (notified %v)", job.Deliveries, job.Notified)
+	}
+	wait(t, m, plain.ID, Succeeded)
+	select {
+	case got := <-delivered:
+		t.Errorf("job without callback notified: %+v", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestCallbackResumesAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	attempted := make(chan struct{}, 1)
+	m, err := Open(Options{Dir: dir, Notify: func(ctx context.Context, job Job, record func(webhook.Attempt)) error {
+		record(webhook.Attempt{Number: 1, Error: "connection refused"})
+		attempted <- struct{}{}
+		<-ctx.Done()
+		return ctx.Err()
+	}}, upper)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, _ := m.Submit(nil, []byte("x"), "http://cms.example/hook")
+	<-attempted
+	m.Close(context.Background())
+
+	delivered := make(chan Job, 1)
+	m = open(t, Options{Dir: dir, Notify: notifier(0, delivered)}, upper)
+	if got := <-delivered; got.ID != job.ID {
+		t.Fatalf("notified %s, want %s", got.ID, job.ID)
+	}
+	if job, _ = m.Get(job.ID); len(job.Deliveries) < 1 || job.Deliveries[0].Error != "connection refused" {
+		t.Errorf("delivery log = %+v", job.Deliveries)
+	}
+}
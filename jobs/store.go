@@ -0,0 +1,102 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Blob names of the files kept next to a job
+const (
+	inputBlob  = "input"
+	resultBlob = "result"
+)
+
+// store keeps each job in a directory as <id>.json, next to its
+// <id>.input and, once it succeeded, <id>.result
+type store struct {
+	dir string
+}
+
+func openStore(dir string) (*store, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create job store: %w", err)
+	}
+	return &store{dir: dir}, nil
+}
+
+func (s *store) path(id, ext string) string {
+	return filepath.Join(s.dir, id+"."+ext)
+}
+
+// load reads every job in the store. Files that cannot be read are
+// logged and left alone.
+func (s *store) load() ([]*Job, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read job store: %w", err)
+	}
+	var jobs []*Job
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, e.Name()))
+		if err != nil {
+			slog.Warn("skipping unreadable job", slog.String("file", e.Name()), slog.Any("error", err))
+			continue
+		}
+		var job Job
+		if err := json.Unmarshal(data, &job); err != nil || job.ID+".json" != e.Name() {
+			slog.Warn("skipping malformed job", slog.String("file", e.Name()), slog.Any("error", err))
+			continue
+		}
+		jobs = append(jobs, &job)
+	}
+	return jobs, nil
+}
+
+// save writes the job, replacing the previous version atomically
+func (s *store) save(job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return s.write(job.ID, "json", data)
+}
+
+func (s *store) readBlob(id, name string) ([]byte, error) {
+	return os.ReadFile(s.path(id, name))
+}
+
+// write replaces a file through a temporary file in the same directory
+func (s *store) write(id, ext string, data []byte) error {
+	tmp, err := os.CreateTemp(s.dir, id+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(id, ext))
+}
+
+// remove deletes a job and its blobs
+func (s *store) remove(id string) error {
+	var errs []error
+	for _, ext := range []string{"json", inputBlob, resultBlob} {
+		if err := os.Remove(s.path(id, ext)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
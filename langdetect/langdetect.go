@@ -0,0 +1,169 @@
+// Package langdetect guesses the language of a text locally, from the
+// writing system first and then from character trigrams for languages
+// that share the Latin alphabet.
+package langdetect
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Result is a detected language with a confidence between 0 and 1
+type Result struct {
+	Language   string
+	Confidence float64
+}
+
+// profile is the smoothed trigram log-probability table of one language
+type profile struct {
+	logProb map[string]float64
+	unseen  float64
+}
+
+var profiles = buildProfiles()
+
+func buildProfiles() map[string]*profile {
+	built := make(map[string]*profile, len(samples))
+	for lang, text := range samples {
+		counts := make(map[string]int)
+		total := 0
+		for _, g := range trigrams(text) {
+			counts[g]++
+			total++
+		}
+
+		// Add-one smoothing over the sample's own vocabulary
+		denom := float64(total + len(counts) + 1)
+		p := &profile{logProb: make(map[string]float64, len(counts)), unseen: math.Log(1 / denom)}
+		for g, n := range counts {
+			p.logProb[g] = math.Log(float64(n+1) / denom)
+		}
+		built[lang] = p
+	}
+	return built
+}
+
+// Detect returns the most likely language of text, or an empty Result
+// when text has no letters
+func Detect(text string) Result {
+	var latin, han, kana, hangul, cyrillic, arabic, thai, total int
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Arabic, r):
+			arabic++
+		case unicode.Is(unicode.Thai, r):
+			thai++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		default:
+			continue
+		}
+		total++
+	}
+	if total == 0 {
+		return Result{}
+	}
+
+	share := func(n int) float64 { return float64(n) / float64(total) }
+	switch {
+	// Japanese mixes kanji with kana; any real amount of kana decides it
+	case kana > 0 && share(kana+han) >= 0.5 && (kana >= 2 || han == 0):
+		return Result{Language: "ja", Confidence: round(share(kana + han))}
+	case share(han) >= 0.5:
+		return Result{Language: chineseVariant(text), Confidence: round(share(han))}
+	case share(hangul) >= 0.5:
+		return Result{Language: "ko", Confidence: round(share(hangul))}
+	case share(cyrillic) >= 0.5:
+		return Result{Language: "ru", Confidence: round(share(cyrillic))}
+	case share(arabic) >= 0.5:
+		return Result{Language: "ar", Confidence: round(share(arabic))}
+	case share(thai) >= 0.5:
+		return Result{Language: "th", Confidence: round(share(thai))}
+	case latin > 0:
+		return detectLatin(text, share(latin))
+	}
+	return Result{}
+}
+
+// chineseVariant tells Traditional from Simplified Chinese by counting
+// characters that only one of them uses
+func chineseVariant(text string) string {
+	traditional, simplified := 0, 0
+	for _, r := range text {
+		if strings.ContainsRune(traditionalOnly, r) {
+			traditional++
+		} else if strings.ContainsRune(simplifiedOnly, r) {
+			simplified++
+		}
+	}
+	if traditional > simplified {
+		return "zh-Hant"
+	}
+	return "zh"
+}
+
+// detectLatin scores text against every trigram profile
+func detectLatin(text string, scriptShare float64) Result {
+	grams := trigrams(text)
+	if len(grams) == 0 {
+		return Result{}
+	}
+
+	type score struct {
+		lang string
+		avg  float64
+	}
+	scores := make([]score, 0, len(profiles))
+	for lang, p := range profiles {
+		sum := 0.0
+		for _, g := range grams {
+			if lp, ok := p.logProb[g]; ok {
+				sum += lp
+			} else {
+				sum += p.unseen
+			}
+		}
+		scores = append(scores, score{lang, sum / float64(len(grams))})
+	}
+	sort.Slice(scores, func(i, j int) bool { return scores[i].avg > scores[j].avg })
+
+	// Turn the average log-likelihoods into a probability for the winner.
+	// The evidence grows with the number of trigrams, but is capped so a
+	// long text cannot make a near tie look certain.
+	weight := math.Min(float64(len(grams)), 40)
+	norm := 0.0
+	for _, s := range scores {
+		norm += math.Exp((s.avg - scores[0].avg) * weight)
+	}
+	confidence := scriptShare / norm
+	return Result{Language: scores[0].lang, Confidence: round(confidence)}
+}
+
+// trigrams lowercases text and returns its letter trigrams, with word
+// boundaries marked by spaces
+func trigrams(text string) []string {
+	var grams []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	}) {
+		runes := []rune(" " + word + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			grams = append(grams, string(runes[i:i+3]))
+		}
+	}
+	return grams
+}
+
+func round(f float64) float64 {
+	return math.Round(f*100) / 100
+}
@@ -0,0 +1,50 @@
+package langdetect
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"你好，今天天气怎么样？我们一起去学校吧。", "zh"},
+		{"這個問題我們明天再說，謝謝你的幫忙。", "zh-Hant"},
+		{"今日はとても良い天気ですね。一緒に学校へ行きましょう。", "ja"},
+		{"안녕하세요, 오늘 날씨가 정말 좋네요.", "ko"},
+		{"Привет, как у тебя дела сегодня?", "ru"},
+		{"مرحبا، كيف حالك اليوم؟", "ar"},
+		{"สวัสดีครับ วันนี้อากาศดีมาก", "th"},
+		{"Please review the pull request before merging it into the main branch.", "en"},
+		{"Bitte lesen Sie die Anleitung, bevor Sie das Gerät einschalten.", "de"},
+		{"Merci beaucoup pour votre aide, nous vous contacterons bientôt.", "fr"},
+		{"Gracias por tu ayuda, te llamaremos mañana por la tarde.", "es"},
+		{"Grazie per il tuo aiuto, ci vediamo domani pomeriggio.", "it"},
+		{"Obrigado pela sua ajuda, vamos ligar para você amanhã à tarde.", "pt"},
+		{"Cảm ơn bạn rất nhiều vì sự giúp đỡ của bạn.", "vi"},
+	}
+
+	for _, tt := range tests {
+		got := Detect(tt.text)
+		if got.Language != tt.want {
+			t.Errorf("Detect(%q) = %s (%.2f), want %s", tt.text, got.Language, got.Confidence, tt.want)
+		}
+		if got.Confidence <= 0 || got.Confidence > 1 {
+			t.Errorf("Detect(%q) confidence %.2f out of range", tt.text, got.Confidence)
+		}
+	}
+}
+
+func TestDetectConfidence(t *testing.T) {
+	if got := Detect("12345 !!!"); got.Language != "" {
+		t.Errorf("Expected no language for text without letters, got %+v", got)
+	}
+
+	long := Detect("The weather is nice today and we are going to walk to the park with the children.")
+	short := Detect("ok")
+	if long.Confidence < 0.9 {
+		t.Errorf("Expected high confidence for a long English sentence, got %.2f", long.Confidence)
+	}
+	if short.Confidence >= long.Confidence {
+		t.Errorf("Expected a two-letter text to be less certain (%.2f) than a sentence (%.2f)", short.Confidence, long.Confidence)
+	}
+}
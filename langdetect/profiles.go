@@ -0,0 +1,61 @@
+package langdetect
+
+// samples are short passages in each Latin-script language the detector
+// tells apart. Trigram profiles are built from them at start-up; they only
+// need to be representative, not large.
+var samples = map[string]string{
+	"en": `The quick brown fox jumps over the lazy dog. This is a simple sentence that shows how
+English text usually looks. We would like to translate the document into other languages so that
+everyone in the team can read it. Please check the settings and save your changes before you leave.
+It was the best of times, it was the worst of times. What are you doing there? I think that we should
+have done it with them, because they were not able to find the way to the other side of the river.
+There is nothing more important than the things which you have learned from your own experience.`,
+
+	"de": `Der schnelle braune Fuchs springt über den faulen Hund. Das ist ein einfacher Satz, der zeigt,
+wie deutscher Text normalerweise aussieht. Wir möchten das Dokument in andere Sprachen übersetzen,
+damit jeder im Team es lesen kann. Bitte überprüfen Sie die Einstellungen und speichern Sie Ihre
+Änderungen, bevor Sie gehen. Ich glaube, dass wir es mit ihnen hätten machen sollen, weil sie nicht
+in der Lage waren, den Weg auf die andere Seite des Flusses zu finden. Es gibt nichts Wichtigeres als
+die Dinge, die man aus seiner eigenen Erfahrung gelernt hat. Die Zeit ist nicht auf unserer Seite.`,
+
+	"fr": `Le renard brun rapide saute par-dessus le chien paresseux. C'est une phrase simple qui montre à
+quoi ressemble habituellement un texte en français. Nous voudrions traduire le document dans d'autres
+langues afin que tout le monde dans l'équipe puisse le lire. Veuillez vérifier les paramètres et
+enregistrer vos modifications avant de partir. Je pense que nous aurions dû le faire avec eux, parce
+qu'ils n'étaient pas capables de trouver le chemin vers l'autre côté de la rivière. Il n'y a rien de
+plus important que les choses que vous avez apprises de votre propre expérience. Où est la gare ?`,
+
+	"es": `El rápido zorro marrón salta sobre el perro perezoso. Esta es una oración sencilla que muestra
+cómo suele ser un texto en español. Nos gustaría traducir el documento a otros idiomas para que todos
+en el equipo puedan leerlo. Por favor, revise la configuración y guarde sus cambios antes de salir.
+Creo que deberíamos haberlo hecho con ellos, porque no fueron capaces de encontrar el camino hacia el
+otro lado del río. No hay nada más importante que las cosas que has aprendido de tu propia
+experiencia. ¿Dónde está la estación? Mañana vamos a la playa con los niños y la familia.`,
+
+	"it": `La veloce volpe marrone salta sopra il cane pigro. Questa è una frase semplice che mostra come
+appare di solito un testo in italiano. Vorremmo tradurre il documento in altre lingue in modo che
+tutti nel gruppo possano leggerlo. Per favore, controlla le impostazioni e salva le modifiche prima di
+uscire. Penso che avremmo dovuto farlo con loro, perché non erano in grado di trovare la strada verso
+l'altra parte del fiume. Non c'è niente di più importante delle cose che hai imparato dalla tua
+esperienza. Dov'è la stazione? Domani andiamo al mare con i bambini e gli amici della famiglia.`,
+
+	"pt": `A rápida raposa marrom pula sobre o cão preguiçoso. Esta é uma frase simples que mostra como
+um texto em português costuma ser. Gostaríamos de traduzir o documento para outras línguas para que
+todos na equipe possam lê-lo. Por favor, verifique as configurações e salve suas alterações antes de
+sair. Acho que deveríamos ter feito isso com eles, porque não conseguiram encontrar o caminho para o
+outro lado do rio. Não há nada mais importante do que as coisas que você aprendeu com a sua própria
+experiência. Onde fica a estação? Amanhã vamos à praia com as crianças e a família toda.`,
+
+	"vi": `Con cáo nâu nhanh nhẹn nhảy qua con chó lười biếng. Đây là một câu đơn giản cho thấy văn bản
+tiếng Việt thường trông như thế nào. Chúng tôi muốn dịch tài liệu này sang các ngôn ngữ khác để mọi
+người trong nhóm đều có thể đọc được. Vui lòng kiểm tra cài đặt và lưu các thay đổi của bạn trước khi
+rời đi. Tôi nghĩ rằng chúng ta nên làm điều đó cùng với họ, bởi vì họ không thể tìm được đường sang
+bên kia sông. Không có gì quan trọng hơn những điều mà bạn đã học được từ kinh nghiệm của chính mình.`,
+}
+
+// traditionalOnly and simplifiedOnly are common characters written
+// differently in Traditional and Simplified Chinese
+const (
+	traditionalOnly = "這們個說時來會對國學話為們開關門問間經過當無與號體點機發現還後從見長實種應書車東買賣電動錢頭臺灣讓麼樣紅請謝親愛歡讀寫語詞譯"
+	simplifiedOnly  = "这们个说时来会对国学话为们开关门问间经过当无与号体点机发现还后从见长实种应书车东买卖电动钱头台湾让么样红请谢亲爱欢读写语词译"
+)
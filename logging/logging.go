@@ -0,0 +1,96 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+var redactText atomic.Bool
+
+func init() {
+	redactText.Store(true)
+}
+
+// Options controls how the application logger is built
+type Options struct {
+	Level      string
+	Format     string
+	RedactText bool
+}
+
+// New builds a slog logger writing to w according to opts
+func New(w io.Writer, opts Options) (*slog.Logger, error) {
+	level, err := ParseLevel(opts.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch strings.ToLower(opts.Format) {
+	case "", "text":
+		handler = slog.NewTextHandler(w, handlerOpts)
+	case "json":
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q (expected text or json)", opts.Format)
+	}
+
+	return slog.New(handler), nil
+}
+
+// Setup builds the logger and installs it as the slog default
+func Setup(w io.Writer, opts Options) error {
+	logger, err := New(w, opts)
+	if err != nil {
+		return err
+	}
+
+	redactText.Store(opts.RedactText)
+	slog.SetDefault(logger)
+	return nil
+}
+
+// ParseLevel converts a level name such as "info" into a slog.Level
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "debug":
+		return slog.LevelDebug, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("unknown log level %q", name)
+	}
+}
+
+// Text returns an attribute for user-supplied text, redacted unless disabled
+func Text(key, value string) slog.Attr {
+	if redactText.Load() {
+		return slog.String(key, fmt.Sprintf("[redacted %d bytes]", len(value)))
+	}
+	return slog.String(key, value)
+}
+
+// FromContext returns the default logger annotated with the request ID
+// and trace ID carried by ctx
+func FromContext(ctx context.Context) *slog.Logger {
+	logger := slog.Default()
+	if id := RequestIDFromContext(ctx); id != "" {
+		logger = logger.With("request_id", id)
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		logger = logger.With("trace_id", sc.TraceID().String())
+	}
+	return logger
+}
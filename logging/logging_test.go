@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestID())
+
+	var seen string
+	r.GET("/", func(c *gin.Context) {
+		seen = RequestIDFromContext(c.Request.Context())
+	})
+
+	// Generated when absent
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if seen == "" {
+		t.Fatal("Expected a generated request ID")
+	}
+	if got := w.Header().Get(RequestIDHeader); got != seen {
+		t.Errorf("Expected response header %q, got %q", seen, got)
+	}
+
+	// Reused when well-formed
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if seen != "abc-123" {
+		t.Errorf("Expected incoming request ID to be reused, got %q", seen)
+	}
+
+	// Replaced when malformed
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "bad id\n")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if seen == "bad id\n" {
+		t.Error("Expected malformed request ID to be replaced")
+	}
+}
+
+func TestTextRedaction(t *testing.T) {
+	defer redactText.Store(true)
+
+	redactText.Store(true)
+	if attr := Text("text", "secret"); attr.Value.String() == "secret" {
+		t.Error("Expected text to be redacted")
+	}
+
+	redactText.Store(false)
+	if attr := Text("text", "secret"); attr.Value.String() != "secret" {
+		t.Errorf("Expected raw text, got %q", attr.Value.String())
+	}
+}
+
+func TestNewRejectsUnknownSettings(t *testing.T) {
+	if _, err := New(nil, Options{Level: "verbose"}); err == nil {
+		t.Error("Expected error for unknown level")
+	}
+	if _, err := New(nil, Options{Format: "xml"}); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
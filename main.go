@@ -1,24 +1,78 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"golang.org/x/time/rate"
 
-	"github.com/LouisLau-art/go-translator/api"
 	"github.com/LouisLau-art/go-translator/cache"
 	"github.com/LouisLau-art/go-translator/config"
 	"github.com/LouisLau-art/go-translator/handlers"
+	"github.com/LouisLau-art/go-translator/jobs"
+	"github.com/LouisLau-art/go-translator/logging"
+	"github.com/LouisLau-art/go-translator/metrics"
+	"github.com/LouisLau-art/go-translator/tracing"
+	"github.com/LouisLau-art/go-translator/webhook"
 )
 
+// version is set at build time via -ldflags "-X main.version=..."
+var version = "dev"
+
 func main() {
+	if err := runCommand(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		fmt.Fprintf(os.Stderr, "translator: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runServe wires up the application and serves until SIGINT or SIGTERM
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("translator", flag.ContinueOnError)
+	config.RegisterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
 	// Load configuration
-	cfg, err := config.Load()
+	configOpts := config.Options{Flags: fs}
+	cfg, err := config.LoadWithOptions(configOpts)
 	if err != nil {
-		log.Fatal("Failed to load configuration:", err)
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	// Configure structured logging
+	if err := logging.Setup(os.Stderr, logging.Options{
+		Level:      cfg.LogLevel,
+		Format:     cfg.LogFormat,
+		RedactText: cfg.LogRedactText,
+	}); err != nil {
+		return fmt.Errorf("failed to configure logging: %w", err)
+	}
+
+	// Configure tracing
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:    cfg.TracingExporter,
+		Endpoint:    cfg.TracingEndpoint,
+		SampleRatio: cfg.TracingSampleRatio,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to configure tracing: %w", err)
 	}
 
 	// Set Gin mode
@@ -26,15 +80,99 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	app, err := newApplication(cfg, configOpts)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           app.handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	closers := append(app.closers, closer{"tracing", shutdownTracing})
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go app.reload.Run(ctx)
+
+	return serve(ctx, srv, cfg.ShutdownTimeout, closers)
+}
+
+// application is the HTTP handler and the components it owns
+type application struct {
+	handler http.Handler
+	reload  *reloader
+	closers []closer
+}
+
+// newApplication wires the translation components and routes for cfg
+func newApplication(cfg *config.Config, configOpts config.Options) (*application, error) {
 	// Initialize components
-	doubaoClient := api.NewDoubaoClient(cfg.APIKey, cfg.APIURL)
+	clients := newProviders(cfg)
 	translatorCache := cache.NewTranslatorCache(cfg.CacheTTL, cfg.CacheMaxSize)
-	limiter := rate.NewLimiter(rate.Every(2*time.Second), cfg.RateLimitBurst)
-	translationHandler := handlers.NewTranslationHandler(doubaoClient, translatorCache, limiter, cfg.MaxTextLength)
+	limiter := rate.NewLimiter(rateLimit(cfg.RateLimitRPM), cfg.RateLimitBurst)
+	translator := clients.router(cfg)
+	translationHandler := handlers.NewTranslationHandler(translator, translatorCache, limiter, cfg.MaxTextLength)
+	translationHandler.Update(handlerSettings(cfg))
+	metrics.RegisterCacheSize(translatorCache.Size)
+	var webhooks *webhook.Sender
+	if cfg.WebhookSecret != "" {
+		webhooks = webhook.New(webhook.Settings{
+			Secret:       cfg.WebhookSecret,
+			MaxAttempts:  cfg.WebhookMaxAttempts,
+			Backoff:      cfg.WebhookBackoff,
+			Timeout:      cfg.WebhookTimeout,
+			AllowedHosts: cfg.WebhookAllowedHosts,
+		})
+	}
+	jobManager, err := jobs.Open(jobs.Options{
+		Dir:       cfg.JobsDir,
+		Workers:   cfg.JobWorkers,
+		Retention: cfg.JobRetention,
+		Notify:    handlers.JobNotifier(webhooks),
+	}, translationHandler.RunJob)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open job store: %w", err)
+	}
+	jobHandler := handlers.NewJobHandler(translationHandler, jobManager, webhooks)
+
+	// Settings that can change without a restart
+	reload := newReloader(configOpts, cfg, func(old, next *config.Config) {
+		limiter.SetLimit(rateLimit(next.RateLimitRPM))
+		limiter.SetBurst(next.RateLimitBurst)
+		translationHandler.Update(handlerSettings(next))
+		translatorCache.SetTTL(next.CacheTTL)
+		translatorCache.SetMaxSize(next.CacheMaxSize)
+		clients.update(next)
+		if err := logging.Setup(os.Stderr, logging.Options{
+			Level:      next.LogLevel,
+			Format:     next.LogFormat,
+			RedactText: next.LogRedactText,
+		}); err != nil {
+			slog.Warn("failed to reconfigure logging", slog.Any("error", err))
+		}
+		warnRestartRequired(old, next)
+	})
+
+	healthHandler := handlers.NewHealthHandler(version, translator, cfg.ReadinessProbeTTL)
+	healthHandler.SetBreakerStates(translator.BreakerStates)
+	healthHandler.AddCheck("config", handlers.ConfigChecker(func() ([]string, error) {
+		return reload.Current().MissingAPIKeys(), reload.LastError()
+	}))
+	healthHandler.AddCheck("cache", handlers.CheckerFunc(func(context.Context) error { return translatorCache.Ping() }))
 
 	// Create router
-	r := gin.Default()
-	r.Use(cors.Default())
+	r := gin.New()
+	r.Use(logging.RequestID())
+	r.Use(tracing.Middleware())
+	r.Use(logging.AccessLog())
+	r.Use(gin.Recovery())
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowAllOrigins = true
+	corsConfig.AddAllowHeaders(logging.RequestIDHeader)
+	corsConfig.AddExposeHeaders(logging.RequestIDHeader)
+	r.Use(cors.New(corsConfig))
+	r.Use(metrics.Middleware())
 
 	// Serve static files
 	r.Static("/static", "./static")
@@ -45,17 +183,129 @@ func main() {
 	apiGroup := r.Group("/api")
 	{
 		apiGroup.POST("/translate", translationHandler.HandleTranslate)
+		apiGroup.POST("/translate/batch", translationHandler.HandleTranslateBatch)
+		apiGroup.POST("/documents", translationHandler.HandleDocument)
+		apiGroup.POST("/jobs", jobHandler.HandleSubmit)
+		apiGroup.GET("/jobs/:id", jobHandler.HandleGet)
+		apiGroup.GET("/jobs/:id/result", jobHandler.HandleResult)
+		apiGroup.POST("/jobs/:id/cancel", jobHandler.HandleCancel)
+		apiGroup.GET("/jobs/:id/deliveries", jobHandler.HandleDeliveries)
 		apiGroup.GET("/languages", getLanguages)
-		apiGroup.GET("/health", healthCheck)
+		apiGroup.GET("/models", translationHandler.HandleModels)
+		apiGroup.GET("/health", healthHandler.HandleLive)
 	}
 
-	// Start server
-	log.Printf("Server starting on port %s...", cfg.Port)
-	if err := r.Run(":" + cfg.Port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	// Liveness and readiness probes
+	r.GET("/livez", healthHandler.HandleLive)
+	r.GET("/readyz", healthHandler.HandleReady)
+
+	// Prometheus metrics
+	r.GET("/metrics", metrics.Handler())
+
+	return &application{
+		handler: r,
+		reload:  reload,
+		// Components flushed or stopped once the server has drained, in order
+		closers: []closer{
+			{"job store", jobManager.Close},
+			{"cache cleanup", func(context.Context) error { translatorCache.StopCleanup(); return nil }},
+		},
+	}, nil
+}
+
+// handlerSettings are the translation handler's runtime settings in cfg
+func handlerSettings(cfg *config.Config) handlers.Settings {
+	return handlers.Settings{
+		MaxLength:       cfg.MaxTextLength,
+		MaxDocumentSize: cfg.MaxDocumentSize,
+		DefaultModel:    cfg.DefaultModel(),
+		AllowedModels:   cfg.AllowedModels,
+		PseudoExpansion: cfg.PseudoExpansion,
 	}
 }
 
+// rateLimit converts a requests-per-minute budget into a limiter rate
+func rateLimit(rpm int) rate.Limit {
+	return rate.Every(time.Minute / time.Duration(rpm))
+}
+
+// warnRestartRequired logs settings that changed but only take effect
+// after a restart
+func warnRestartRequired(old, next *config.Config) {
+	changed := func(name string, differs bool) {
+		if differs {
+			slog.Warn("setting changed but requires a restart", slog.String("setting", name))
+		}
+	}
+	changed("PORT", old.Port != next.Port)
+	changed("GIN_MODE", old.GinMode != next.GinMode)
+	changed("SHUTDOWN_TIMEOUT", old.ShutdownTimeout != next.ShutdownTimeout)
+	changed("READINESS_PROBE_TTL", old.ReadinessProbeTTL != next.ReadinessProbeTTL)
+	changed("TRACING_EXPORTER", old.TracingExporter != next.TracingExporter)
+	changed("TRACING_ENDPOINT", old.TracingEndpoint != next.TracingEndpoint)
+	changed("TRACING_SAMPLE_RATIO", old.TracingSampleRatio != next.TracingSampleRatio)
+	changed("PROVIDERS", strings.Join(old.Providers, ",") != strings.Join(next.Providers, ","))
+	changed("BREAKER_FAILURE_THRESHOLD", old.BreakerFailureThreshold != next.BreakerFailureThreshold)
+	changed("BREAKER_COOLDOWN", old.BreakerCooldown != next.BreakerCooldown)
+	changed("BREAKER_HALF_OPEN_PROBES", old.BreakerHalfOpenProbes != next.BreakerHalfOpenProbes)
+	changed("JOBS_DIR", old.JobsDir != next.JobsDir)
+	changed("JOB_WORKERS", old.JobWorkers != next.JobWorkers)
+	changed("JOB_RETENTION", old.JobRetention != next.JobRetention)
+	changed("WEBHOOK_SECRET", old.WebhookSecret != next.WebhookSecret)
+	changed("WEBHOOK_MAX_ATTEMPTS", old.WebhookMaxAttempts != next.WebhookMaxAttempts)
+	changed("WEBHOOK_BACKOFF", old.WebhookBackoff != next.WebhookBackoff)
+	changed("WEBHOOK_TIMEOUT", old.WebhookTimeout != next.WebhookTimeout)
+	changed("WEBHOOK_ALLOWED_HOSTS", !slices.Equal(old.WebhookAllowedHosts, next.WebhookAllowedHosts))
+}
+
+// closer is a named shutdown step run after the HTTP server has drained
+type closer struct {
+	name string
+	fn   func(context.Context) error
+}
+
+// serve runs srv until ctx is cancelled, then drains in-flight requests for
+// at most timeout before running closers, which get a timeout of their own
+func serve(ctx context.Context, srv *http.Server, timeout time.Duration, closers []closer) error {
+	errCh := make(chan error, 1)
+	go func() {
+		slog.Info("server starting", slog.String("addr", srv.Addr))
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+		close(errCh)
+	}()
+
+	var serveErr error
+	select {
+	case err := <-errCh:
+		serveErr = err
+	case <-ctx.Done():
+		slog.Info("shutdown signal received, draining connections", slog.Duration("timeout", timeout))
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("graceful shutdown timed out, closing remaining connections", slog.Any("error", err))
+		_ = srv.Close()
+	}
+
+	// A slow drain may have used up the shutdown deadline; the closers
+	// still need time to flush
+	closeCtx, cancelClose := context.WithTimeout(context.Background(), timeout)
+	defer cancelClose()
+	for _, c := range closers {
+		if err := c.fn(closeCtx); err != nil {
+			slog.Warn("shutdown step failed", slog.String("step", c.name), slog.Any("error", err))
+		}
+	}
+
+	slog.Info("server stopped")
+	return serveErr
+}
+
 // Language map for API response
 var languageMap = map[string]string{
 	"zh":      "中文（简体）",
@@ -72,6 +322,7 @@ var languageMap = map[string]string{
 	"th":      "泰语",
 	"vi":      "越南语",
 	"ar":      "阿拉伯语",
+	"pseudo":  "伪本地化（测试用）",
 }
 
 // getLanguages returns supported languages
@@ -81,11 +332,3 @@ func getLanguages(c *gin.Context) {
 		"languages": languageMap,
 	})
 }
-
-// healthCheck returns server health status
-func healthCheck(c *gin.Context) {
-	c.JSON(200, gin.H{
-		"status": "healthy",
-		"time":   time.Now().Unix(),
-	})
-}
\ No newline at end of file
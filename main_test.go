@@ -0,0 +1,457 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/LouisLau-art/go-translator/config"
+)
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to reserve port: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	started := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte("done"))
+	})
+	srv := &http.Server{Addr: addr, Handler: mux}
+
+	closed := false
+	closers := []closer{{"test", func(context.Context) error { closed = true; return nil }}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	serveDone := make(chan error, 1)
+	go func() { serveDone <- serve(ctx, srv, 5*time.Second, closers) }()
+
+	// Wait for the listener to come up
+	for i := 0; i < 50; i++ {
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			conn.Close()
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	respCh := make(chan *http.Response, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/slow")
+		if err != nil {
+			t.Errorf("In-flight request failed: %v", err)
+		}
+		respCh <- resp
+	}()
+
+	<-started
+	cancel()
+
+	resp := <-respCh
+	if resp == nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected in-flight request to complete with 200, got %v", resp)
+	}
+	resp.Body.Close()
+
+	if err := <-serveDone; err != nil {
+		t.Fatalf("Expected clean shutdown, got %v", err)
+	}
+	if !closed {
+		t.Error("Expected shutdown closers to run")
+	}
+}
+
+func TestServeClosersOutliveDrainTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to reserve port: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stuck", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	srv := &http.Server{Addr: addr, Handler: mux}
+
+	var closeErr error
+	closers := []closer{{"test", func(ctx context.Context) error { closeErr = ctx.Err(); return nil }}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	serveDone := make(chan error, 1)
+	go func() { serveDone <- serve(ctx, srv, 50*time.Millisecond, closers) }()
+
+	for i := 0; i < 50; i++ {
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			conn.Close()
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	go http.Get("http://" + addr + "/stuck")
+
+	<-started
+	cancel()
+	if err := <-serveDone; err != nil {
+		t.Fatalf("Expected clean shutdown, got %v", err)
+	}
+	// The drain used up its deadline; the closers still get theirs
+	if closeErr != nil {
+		t.Errorf("Expected closers to get a live context, got %v", closeErr)
+	}
+}
+
+func TestReloaderRejectsInvalidConfig(t *testing.T) {
+	t.Setenv("ARK_API_KEY", "")
+	t.Setenv("MAX_TEXT_LENGTH", "")
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+	}
+
+	write("upstream:\n  api_key: key\nlimits:\n  max_text_length: 100\n")
+	opts := config.Options{File: path}
+	cfg, err := config.LoadWithOptions(opts)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	applied := 0
+	r := newReloader(opts, cfg, func(old, next *config.Config) { applied++ })
+
+	// Invalid value: rejected, previous config stays live
+	write("upstream:\n  api_key: key\nlimits:\n  max_text_length: -5\n")
+	if err := r.Reload("test"); err == nil {
+		t.Fatal("Expected invalid reload to fail")
+	}
+	if applied != 0 || r.Current().MaxTextLength != 100 {
+		t.Errorf("Expected previous config to stay live, applied=%d max=%d", applied, r.Current().MaxTextLength)
+	}
+	if r.LastError() == nil {
+		t.Error("Expected the rejection to be recorded")
+	}
+
+	// Valid value: applied
+	write("upstream:\n  api_key: key\nlimits:\n  max_text_length: 200\n")
+	if err := r.Reload("test"); err != nil {
+		t.Fatalf("Expected reload to succeed: %v", err)
+	}
+	if applied != 1 || r.Current().MaxTextLength != 200 {
+		t.Errorf("Expected new config to be applied, applied=%d max=%d", applied, r.Current().MaxTextLength)
+	}
+	if err := r.LastError(); err != nil {
+		t.Errorf("Expected the rejection to be cleared, got %v", err)
+	}
+}
+
+func TestOfflineStackEndToEnd(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ARK_API_KEY", "")
+	t.Setenv("PROVIDERS", "offline")
+	t.Setenv("JOBS_DIR", t.TempDir())
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Expected offline config to load without ARK_API_KEY, got %v", err)
+	}
+	app, err := newApplication(cfg, config.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(app.handler)
+	defer server.Close()
+	defer func() {
+		for _, c := range app.closers {
+			c.fn(context.Background())
+		}
+	}()
+
+	// The UI is served alongside the API
+	resp, err := http.Get(server.URL + "/")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected index page, got %v %v", resp, err)
+	}
+	resp.Body.Close()
+
+	translate := func() map[string]interface{} {
+		resp, err := http.Post(server.URL+"/api/translate", "application/json",
+			strings.NewReader(`{"text":"Hello","source":"en","target":"zh"}`))
+		if err != nil {
+			t.Fatalf("Translate request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var body map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&body)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %v", resp.StatusCode, body)
+		}
+		return body
+	}
+
+	body := translate()
+	if body["text"] != "你好" || body["provider"] != "offline" || body["cached"] != false {
+		t.Errorf("Unexpected translation response: %v", body)
+	}
+	if body := translate(); body["cached"] != true {
+		t.Errorf("Expected the second request to be served from cache, got %v", body)
+	}
+
+	// Long texts can be translated as a background job
+	resp, err = http.Post(server.URL+"/api/jobs", "application/json",
+		strings.NewReader(`{"text":"Hello\n\nThank you","source":"en","target":"zh"}`))
+	if err != nil {
+		t.Fatalf("Job request failed: %v", err)
+	}
+	var submitted struct {
+		Job struct {
+			ID string `json:"id"`
+		} `json:"job"`
+	}
+	json.NewDecoder(resp.Body).Decode(&submitted)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted || submitted.Job.ID == "" {
+		t.Fatalf("Expected 202 with a job id, got %d %+v", resp.StatusCode, submitted)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err = http.Get(server.URL + "/api/jobs/" + submitted.Job.ID + "/result")
+		if err != nil {
+			t.Fatalf("Result request failed: %v", err)
+		}
+		if resp.StatusCode == http.StatusOK || time.Now().After(deadline) {
+			break
+		}
+		resp.Body.Close()
+		time.Sleep(10 * time.Millisecond)
+	}
+	result, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(result) != "你好\n\n谢谢" {
+		t.Errorf("Expected the translated job result, got %d %q", resp.StatusCode, result)
+	}
+
+	resp, err = http.Get(server.URL + "/readyz")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected offline stack to be ready, got %v %v", resp, err)
+	}
+	resp.Body.Close()
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ARK_API_KEY", "")
+	t.Setenv("PROVIDERS", "offline")
+	t.Setenv("JOBS_DIR", t.TempDir())
+	t.Setenv("RATE_LIMIT_RPM", "1")
+	t.Setenv("RATE_LIMIT_BURST", "2")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Building the application twice must not register its metrics twice
+	var app *application
+	for range 2 {
+		if app, err = newApplication(cfg, config.Options{}); err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range app.closers {
+			defer c.fn(context.Background())
+		}
+	}
+	server := httptest.NewServer(app.handler)
+	defer server.Close()
+
+	// Two translations fill the burst and the third is rejected
+	for range 3 {
+		resp, err := http.Post(server.URL+"/api/translate", "application/json",
+			strings.NewReader(`{"text":"Hello","source":"en","target":"zh"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	scrape := string(body)
+	for _, series := range []string{
+		`translator_http_requests_total{method="POST",route="/api/translate",status="200"}`,
+		`translator_http_requests_total{method="POST",route="/api/translate",status="429"}`,
+		"translator_cache_size 1",
+		"translator_cache_hits_total",
+		"translator_rate_limit_rejections_total",
+	} {
+		if !strings.Contains(scrape, series) {
+			t.Errorf("Expected %s in the scrape", series)
+		}
+	}
+}
+
+func TestRunPOOffline(t *testing.T) {
+	t.Setenv("ARK_API_KEY", "")
+	t.Setenv("PROVIDERS", "offline")
+	t.Setenv("LOG_LEVEL", "error")
+
+	dir := t.TempDir()
+	input := filepath.Join(dir, "messages.pot")
+	output := filepath.Join(dir, "zh.po")
+	pot := "msgid \"\"\nmsgstr \"\"\n\"Content-Type: text/plain; charset=UTF-8\\n\"\n\nmsgid \"Hello\"\nmsgstr \"\"\n"
+	if err := os.WriteFile(input, []byte(pot), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stderr strings.Builder
+	if err := runPO([]string{"-t", "zh", "-s", "en", "-o", output, input}, nil, nil, &stderr); err != nil {
+		t.Fatalf("runPO failed: %v", err)
+	}
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), "#, fuzzy\nmsgid \"Hello\"\nmsgstr \"你好\"") {
+		t.Errorf("Unexpected catalog:\n%s", got)
+	}
+	if stderr.String() != "translated 1 entries, skipped 0\n" {
+		t.Errorf("Unexpected summary %q", stderr.String())
+	}
+}
+
+func TestRunTranslateOffline(t *testing.T) {
+	t.Setenv("ARK_API_KEY", "")
+	t.Setenv("PROVIDERS", "offline")
+	t.Setenv("LOG_LEVEL", "error")
+
+	// stdin to stdout, as in a pipeline
+	var stdout, stderr strings.Builder
+	if err := runTranslate([]string{"-s", "en", "-t", "zh"}, strings.NewReader("Hello\n\nThank you"), &stdout, &stderr); err != nil {
+		t.Fatalf("runTranslate failed: %v", err)
+	}
+	if stdout.String() != "你好\n\n谢谢" {
+		t.Errorf("Unexpected translation %q", stdout.String())
+	}
+
+	// Documents on stdin are named by -format
+	stdout.Reset()
+	srt := "1\n00:00:01,000 --> 00:00:02,000\nHello\n"
+	if err := runTranslate([]string{"-s", "en", "-t", "zh", "-format", "srt"}, strings.NewReader(srt), &stdout, &stderr); err != nil {
+		t.Fatalf("runTranslate failed: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "00:00:01,000 --> 00:00:02,000\n你好") {
+		t.Errorf("Unexpected subtitles %q", stdout.String())
+	}
+
+	// Several files go to a directory, named after the target language
+	dir := t.TempDir()
+	inputs := []string{filepath.Join(dir, "notes.md"), filepath.Join(dir, "movie.srt")}
+	os.WriteFile(inputs[0], []byte("Thank you\n"), 0o644)
+	os.WriteFile(inputs[1], []byte(srt), 0o644)
+	out := filepath.Join(dir, "out")
+	if err := runTranslate(append([]string{"-s", "en", "-t", "zh", "-d", out}, inputs...), nil, nil, &stderr); err != nil {
+		t.Fatalf("runTranslate failed: %v", err)
+	}
+	for name, want := range map[string]string{"notes.zh.md": "谢谢", "movie.zh.srt": "你好"} {
+		if got, err := os.ReadFile(filepath.Join(out, name)); err != nil || !strings.Contains(string(got), want) {
+			t.Errorf("%s = %q, %v", name, got, err)
+		}
+	}
+
+	if err := runTranslate(append([]string{"-t", "zh"}, inputs...), nil, nil, &stderr); err == nil {
+		t.Error("Expected several files without -d to be rejected")
+	}
+}
+
+func TestRunBatchOffline(t *testing.T) {
+	t.Setenv("ARK_API_KEY", "")
+	t.Setenv("PROVIDERS", "offline")
+	t.Setenv("LOG_LEVEL", "error")
+
+	root := t.TempDir()
+	for path, content := range map[string]string{
+		"docs/guide.md":        "Hello\n",
+		"docs/locale/en.json":  `{"greeting": "Thank you"}`,
+		"docs/logo.png":        "not text",
+		".git/COMMIT_EDITMSG":  "Hello",
+		"docs/.drafts/todo.md": "Hello",
+	} {
+		path = filepath.Join(root, path)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		os.WriteFile(path, []byte(content), 0o644)
+	}
+	out := filepath.Join(root, "out")
+
+	var stderr strings.Builder
+	for range 2 {
+		stderr.Reset()
+		if err := runBatch([]string{"-s", "en", "-t", "zh", "-d", out, root}, &stderr); err != nil {
+			t.Fatalf("runBatch failed: %v\n%s", err, stderr.String())
+		}
+		// The output directory is not picked up again on the second run
+		if !strings.HasSuffix(stderr.String(), "translated 2 of 2 files\n") {
+			t.Errorf("Unexpected summary %q", stderr.String())
+		}
+	}
+	for name, want := range map[string]string{"docs/guide.zh.md": "你好", "docs/locale/en.zh.json": "谢谢"} {
+		if got, err := os.ReadFile(filepath.Join(out, name)); err != nil || !strings.Contains(string(got), want) {
+			t.Errorf("%s = %q, %v", name, got, err)
+		}
+	}
+}
+
+func TestBatchFilesSeveralRoots(t *testing.T) {
+	base := t.TempDir()
+	for _, path := range []string{"site/guide.md", "blog/guide.md", "a/docs/intro.md", "b/docs/intro.md"} {
+		path = filepath.Join(base, path)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		os.WriteFile(path, []byte("Hello\n"), 0o644)
+	}
+	include := func(string) bool { return true }
+	out := filepath.Join(base, "out")
+
+	// Each directory's files go under its name
+	files, err := batchFiles([]string{filepath.Join(base, "site"), filepath.Join(base, "blog")}, out, include)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rels []string
+	for _, f := range files {
+		rels = append(rels, f.rel)
+	}
+	if strings.Join(rels, ",") != filepath.Join("site", "guide.md")+","+filepath.Join("blog", "guide.md") {
+		t.Errorf("Unexpected relative paths %q", rels)
+	}
+
+	// Directories with the same name would overwrite each other
+	_, err = batchFiles([]string{filepath.Join(base, "a", "docs"), filepath.Join(base, "b", "docs")}, out, include)
+	if err == nil || !strings.Contains(err.Error(), "both be written") {
+		t.Errorf("Expected colliding outputs to be rejected, got %v", err)
+	}
+}
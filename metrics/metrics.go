@@ -0,0 +1,176 @@
+package metrics
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "translator"
+
+var (
+	// HTTPRequests counts handled HTTP requests per route, method and status
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Total number of HTTP requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+
+	// HTTPDuration records HTTP request latency per route, method and status
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	// UpstreamDuration records latency of translation API calls
+	UpstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_request_duration_seconds",
+		Help:      "Latency of upstream translation API calls by outcome.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2, 5, 10, 20, 30},
+	}, []string{"outcome"})
+
+	// UpstreamErrors counts failed upstream calls per error class
+	UpstreamErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_errors_total",
+		Help:      "Failed upstream translation API calls by error class.",
+	}, []string{"class"})
+
+	// UpstreamTokens counts tokens reported by the upstream API
+	UpstreamTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_tokens_total",
+		Help:      "Tokens consumed by upstream translation calls by type.",
+	}, []string{"type"})
+
+	// ProviderRequests counts calls routed to each provider by outcome
+	ProviderRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "provider_requests_total",
+		Help:      "Translation calls per provider by outcome (success, error, skipped).",
+	}, []string{"provider", "outcome"})
+
+	// FallbackResponses counts translations served by a fallback provider
+	FallbackResponses = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "fallback_responses_total",
+		Help:      "Translations served by a provider other than the primary one.",
+	})
+
+	// BreakerState reports each circuit breaker's state
+	// (0 closed, 1 half-open, 2 open)
+	BreakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "circuit_breaker_state",
+		Help:      "Circuit breaker state per provider: 0 closed, 1 half-open, 2 open.",
+	}, []string{"provider"})
+
+	// BreakerTransitions counts circuit breaker state changes
+	BreakerTransitions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "circuit_breaker_transitions_total",
+		Help:      "Circuit breaker state changes per provider by new state.",
+	}, []string{"provider", "state"})
+
+	// CacheHits counts translation cache hits
+	CacheHits = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_hits_total",
+		Help:      "Total number of translation cache hits.",
+	})
+
+	// CacheMisses counts translation cache misses
+	CacheMisses = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_misses_total",
+		Help:      "Total number of translation cache misses.",
+	})
+
+	// CacheEvictions counts entries removed from the cache by reason
+	CacheEvictions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_evictions_total",
+		Help:      "Entries removed from the translation cache by reason.",
+	}, []string{"reason"})
+
+	// TranslationChunks records how many chunks each translation was split into
+	TranslationChunks = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "translation_chunks",
+		Help:      "Number of chunks per translation request.",
+		Buckets:   []float64{1, 2, 3, 5, 8, 13, 21},
+	})
+
+	// JobsFinished counts background jobs by final status
+	JobsFinished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jobs_finished_total",
+		Help:      "Background translation jobs finished by status (succeeded, failed, cancelled).",
+	}, []string{"status"})
+
+	// WebhookDeliveries counts webhook delivery attempts by outcome
+	WebhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Webhook delivery attempts by outcome (success, failure).",
+	}, []string{"outcome"})
+
+	// RateLimitRejections counts requests rejected by the rate limiter
+	RateLimitRejections = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_rejections_total",
+		Help:      "Total number of requests rejected by the rate limiter.",
+	})
+)
+
+// cacheSize holds the size function of the current cache. The gauge is
+// registered once and reads it, so building the application again, as
+// tests do, replaces the function instead of registering a duplicate.
+var cacheSize atomic.Pointer[func() int]
+
+var _ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Name:      "cache_size",
+	Help:      "Current number of entries in the translation cache.",
+}, func() float64 {
+	if size := cacheSize.Load(); size != nil {
+		return float64((*size)())
+	}
+	return 0
+})
+
+// RegisterCacheSize exposes the current cache size through the given
+// function, replacing any function registered before
+func RegisterCacheSize(size func() int) {
+	cacheSize.Store(&size)
+}
+
+// Middleware records request count and latency for every route
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+
+		HTTPRequests.WithLabelValues(route, c.Request.Method, status).Inc()
+		HTTPDuration.WithLabelValues(route, c.Request.Method, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler serves the Prometheus exposition format
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
@@ -0,0 +1,197 @@
+// Package placeholder finds the parts of a UI string that must survive
+// translation unchanged: printf verbs, {name} style arguments, markup tags
+// and ICU MessageFormat syntax.
+package placeholder
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Segment is a run of text, either translatable or a placeholder
+type Segment struct {
+	Text        string
+	Placeholder bool
+}
+
+// printfVerb matches printf-style verbs such as %s, %d, %1$s, %.2f, %%
+// and Python's %(name)s
+var printfVerb = regexp.MustCompile(`%(?:\d+\$|\(\w+\))?[-+#0]*\d*(?:\.\d+)?(?:ll|l|h)?[sdfiuxXoeEgGcpqv@%]`)
+
+// markupTag matches an HTML or XML start, end or empty-element tag
+var markupTag = regexp.MustCompile(`^</?[A-Za-z][\w:.-]*(?:\s[^<>]*)?/?>`)
+
+// icuComplex matches the head of an ICU plural, select or selectordinal
+// argument, e.g. "count, plural,"
+var icuComplex = regexp.MustCompile(`^\s*[\w.]+\s*,\s*(plural|select|selectordinal)\s*,`)
+
+// Split breaks text into translatable and placeholder segments. The
+// messages inside ICU plural and select arguments stay translatable; their
+// keywords, selectors and the # number sign are placeholders. Joining the
+// segments' Text reproduces the input.
+func Split(text string) []Segment {
+	var s splitter
+	s.split(text, false)
+	s.flush()
+	return s.segments
+}
+
+// Mask replaces every placeholder in text with a numbered token such as
+// {0}, which translators leave alone, and returns the placeholders in
+// token order. The last result is false when text has no letters outside
+// its placeholders, so there is nothing to translate.
+func Mask(text string) (string, []string, bool) {
+	var (
+		b            strings.Builder
+		placeholders []string
+		translatable bool
+	)
+	for _, seg := range Split(text) {
+		if seg.Placeholder {
+			b.WriteString("{" + strconv.Itoa(len(placeholders)) + "}")
+			placeholders = append(placeholders, seg.Text)
+			continue
+		}
+		b.WriteString(seg.Text)
+		if strings.IndexFunc(seg.Text, unicode.IsLetter) >= 0 {
+			translatable = true
+		}
+	}
+	return b.String(), placeholders, translatable
+}
+
+var maskToken = regexp.MustCompile(`\{(\d+)\}`)
+
+// Unmask restores the placeholders of a masked text after translation. It
+// reports false unless every token appears exactly once.
+func Unmask(text string, placeholders []string) (string, bool) {
+	seen := make([]bool, len(placeholders))
+	ok := true
+	restored := maskToken.ReplaceAllStringFunc(text, func(token string) string {
+		i, err := strconv.Atoi(token[1 : len(token)-1])
+		if err != nil || i >= len(placeholders) || seen[i] {
+			ok = false
+			return token
+		}
+		seen[i] = true
+		return placeholders[i]
+	})
+	for _, s := range seen {
+		ok = ok && s
+	}
+	return restored, ok
+}
+
+type splitter struct {
+	segments []Segment
+	literal  strings.Builder
+}
+
+func (s *splitter) flush() {
+	if s.literal.Len() > 0 {
+		s.segments = append(s.segments, Segment{Text: s.literal.String()})
+		s.literal.Reset()
+	}
+}
+
+func (s *splitter) placeholder(text string) {
+	s.flush()
+	if n := len(s.segments); n > 0 && s.segments[n-1].Placeholder {
+		s.segments[n-1].Text += text
+		return
+	}
+	s.segments = append(s.segments, Segment{Text: text, Placeholder: true})
+}
+
+// split scans text; inPlural enables the # number sign of plural messages
+func (s *splitter) split(text string, inPlural bool) {
+	for i := 0; i < len(text); {
+		switch c := text[i]; {
+		case c == '{':
+			end := matchingBrace(text, i)
+			if end < 0 {
+				s.literal.WriteString(text[i:])
+				return
+			}
+			s.argument(text[i : end+1])
+			i = end + 1
+		case c == '%':
+			if loc := printfVerb.FindStringIndex(text[i:]); loc != nil && loc[0] == 0 {
+				s.placeholder(text[i : i+loc[1]])
+				i += loc[1]
+				continue
+			}
+			s.literal.WriteByte(c)
+			i++
+		case c == '<':
+			if loc := markupTag.FindStringIndex(text[i:]); loc != nil {
+				s.placeholder(text[i : i+loc[1]])
+				i += loc[1]
+				continue
+			}
+			s.literal.WriteByte(c)
+			i++
+		case c == '#' && inPlural:
+			s.placeholder("#")
+			i++
+		default:
+			s.literal.WriteByte(c)
+			i++
+		}
+	}
+}
+
+// argument handles one balanced {...} group
+func (s *splitter) argument(arg string) {
+	inner := arg[1 : len(arg)-1]
+
+	// {{mustache}} style and plain {name} / {0} / {n, number} arguments
+	head := icuComplex.FindStringSubmatch(inner)
+	if head == nil {
+		s.placeholder(arg)
+		return
+	}
+
+	// ICU plural/select: keep "{name, plural, selector {" as placeholders
+	// and recurse into each message
+	s.placeholder("{" + head[0])
+	rest := inner[len(head[0]):]
+	inPlural := head[1] != "select"
+	for len(rest) > 0 {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			s.placeholder(rest)
+			break
+		}
+		end := matchingBrace(rest, open)
+		if end < 0 {
+			s.placeholder(rest)
+			break
+		}
+		s.placeholder(rest[:open+1])
+		s.split(rest[open+1:end], inPlural)
+		s.flush()
+		s.placeholder("}")
+		rest = rest[end+1:]
+	}
+	s.placeholder("}")
+}
+
+// matchingBrace returns the index of the brace closing text[open], or -1
+func matchingBrace(text string, open int) int {
+	depth := 0
+	for i := open; i < len(text); i++ {
+		switch text[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,65 @@
+package placeholder
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		text         string
+		placeholders []string
+	}{
+		{"Hello {name}!", []string{"{name}"}},
+		{"%1$s has %d files (100%%)", []string{"%1$s", "%d", "%%"}},
+		{"Hi {{user}}", []string{"{{user}}"}},
+		{"{count, plural, one {# file} other {# files}}", []string{"{count, plural, one {#", "} other {#", "}}"}},
+		{"{gender, select, male {He} other {They}} left", []string{"{gender, select, male {", "} other {", "}}"}},
+		{"50% off", nil},
+		{"Click <b>here</b> or <br/>", []string{"<b>", "</b>", "<br/>"}},
+		{"a < b and c > d", nil},
+		{"unbalanced {brace", nil},
+	}
+
+	for _, tt := range tests {
+		segments := Split(tt.text)
+
+		var joined strings.Builder
+		var got []string
+		for _, seg := range segments {
+			joined.WriteString(seg.Text)
+			if seg.Placeholder {
+				got = append(got, seg.Text)
+			}
+		}
+		if joined.String() != tt.text {
+			t.Errorf("Split(%q) does not round-trip: %q", tt.text, joined.String())
+		}
+		if strings.Join(got, "|") != strings.Join(tt.placeholders, "|") {
+			t.Errorf("Split(%q) placeholders = %q, want %q", tt.text, got, tt.placeholders)
+		}
+	}
+}
+
+func TestMaskUnmask(t *testing.T) {
+	masked, placeholders, translatable := Mask("Hello %(user)s, you have {count} new %s")
+	if masked != "Hello {0}, you have {1} new {2}" || !translatable {
+		t.Fatalf("Mask = %q, %v", masked, translatable)
+	}
+
+	// Translators may reorder placeholders
+	got, ok := Unmask("{1} nouveaux {2} pour {0}", placeholders)
+	if !ok || got != "{count} nouveaux %s pour %(user)s" {
+		t.Errorf("Unmask = %q, %v", got, ok)
+	}
+
+	for _, broken := range []string{"{0} {1}", "{0} {1} {2} {2}", "{0} {1} {7}"} {
+		if _, ok := Unmask(broken, placeholders); ok {
+			t.Errorf("Unmask(%q) succeeded, want failure", broken)
+		}
+	}
+
+	if _, _, translatable := Mask("%s: %d"); translatable {
+		t.Error("Mask reported placeholders-only text as translatable")
+	}
+}
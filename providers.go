@@ -0,0 +1,76 @@
+package main
+
+import (
+	"github.com/LouisLau-art/go-translator/api"
+	"github.com/LouisLau-art/go-translator/breaker"
+	"github.com/LouisLau-art/go-translator/config"
+)
+
+// providers holds one client per provider. Every PROVIDERS entry of the
+// same provider shares its client, so runtime settings apply to all of them.
+type providers struct {
+	doubao  *api.DoubaoClient
+	openai  *api.OpenAIClient
+	offline *api.OfflineClient
+}
+
+func newProviders(cfg *config.Config) *providers {
+	return &providers{
+		doubao: api.NewDoubaoClient(cfg.APIKey, cfg.APIURL,
+			api.WithMaxRetries(cfg.UpstreamMaxRetries),
+			api.WithModel(cfg.Model, cfg.ExtraOptions)),
+		openai:  api.NewOpenAIClient(openAISettings(cfg)),
+		offline: api.NewOfflineClient(),
+	}
+}
+
+// update applies settings that can change without a restart
+func (p *providers) update(cfg *config.Config) {
+	p.doubao.UpdateSettings(api.Settings{
+		APIKey:     cfg.APIKey,
+		APIURL:     cfg.APIURL,
+		MaxRetries: cfg.UpstreamMaxRetries,
+		Model:      cfg.Model,
+		Extra:      cfg.ExtraOptions,
+	})
+	p.openai.UpdateSettings(openAISettings(cfg))
+}
+
+func openAISettings(cfg *config.Config) api.OpenAISettings {
+	return api.OpenAISettings{
+		APIKey:     cfg.OpenAIAPIKey,
+		BaseURL:    cfg.OpenAIBaseURL,
+		Model:      cfg.OpenAIModel,
+		MaxRetries: cfg.UpstreamMaxRetries,
+		Extra:      cfg.OpenAIExtraOptions,
+	}
+}
+
+// router builds the fallback chain configured in PROVIDERS
+func (p *providers) router(cfg *config.Config) *api.Router {
+	var routes []api.Route
+	for _, spec := range cfg.ProviderChain() {
+		var translator api.Translator
+		switch spec.Name {
+		case "doubao":
+			translator = p.doubao
+		case "openai":
+			translator = p.openai
+		case "offline":
+			translator = p.offline
+		default:
+			// Rejected by config validation
+			continue
+		}
+		routes = append(routes, api.Route{
+			Name:       spec.String(),
+			Translator: translator,
+			Model:      spec.Model,
+		})
+	}
+	return api.NewRouter(routes, api.WithBreaker(breaker.Settings{
+		FailureThreshold: cfg.BreakerFailureThreshold,
+		Cooldown:         cfg.BreakerCooldown,
+		HalfOpenProbes:   cfg.BreakerHalfOpenProbes,
+	}))
+}
@@ -0,0 +1,38 @@
+package pseudo
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestLocalize(t *testing.T) {
+	tests := []struct {
+		text      string
+		expansion int
+		want      string
+	}{
+		{"Save", 0, "[Šáṽé]"},
+		{"Save", 50, "[Šáṽé ~]"},
+		{"Hello {name}, you have %d messages", 0, "[Ĥéļļö {name}, ýöü ĥáṽé %d ɱéššáĝéš]"},
+		{"{count, plural, one {# file} other {# files}}", 0, "[{count, plural, one {# ƒíļé} other {# ƒíļéš}}]"},
+		{"Line one\n\nLine two", 0, "[Ļíñé öñé]\n\n[Ļíñé ţŵö]"},
+	}
+
+	for _, tt := range tests {
+		if got := Localize(tt.text, tt.expansion); got != tt.want {
+			t.Errorf("Localize(%q, %d) = %q, want %q", tt.text, tt.expansion, got, tt.want)
+		}
+	}
+}
+
+func TestLocalizeExpandsLength(t *testing.T) {
+	text := "The quick brown fox jumps over the lazy dog"
+	got := Localize(text, 30)
+
+	// Brackets excluded, the result is at least 30% longer
+	body := strings.TrimSuffix(strings.TrimPrefix(got, "["), "]")
+	if min := utf8.RuneCountInString(text) * 130 / 100; utf8.RuneCountInString(body) < min {
+		t.Errorf("Expected at least %d characters, got %d: %q", min, utf8.RuneCountInString(body), got)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/LouisLau-art/go-translator/config"
+)
+
+// configPollInterval is how often the config file is checked for changes
+const configPollInterval = 2 * time.Second
+
+// reloader re-reads the configuration on SIGHUP or config file change and
+// hands valid results to apply. Invalid configurations are rejected and
+// the previous one stays live; the rejection is kept for readiness until a
+// later reload succeeds.
+type reloader struct {
+	opts  config.Options
+	apply func(old, next *config.Config)
+
+	// mu serializes reloads; current is published once a reload has been
+	// applied, so readers never wait for one
+	mu      sync.Mutex
+	current atomic.Pointer[config.Config]
+	lastErr atomic.Pointer[error]
+}
+
+func newReloader(opts config.Options, current *config.Config, apply func(old, next *config.Config)) *reloader {
+	r := &reloader{opts: opts, apply: apply}
+	r.current.Store(current)
+	return r
+}
+
+// Current returns the configuration that is currently live
+func (r *reloader) Current() *config.Config {
+	return r.current.Load()
+}
+
+// LastError returns why the last reload was rejected, or nil if it was
+// applied
+func (r *reloader) LastError() error {
+	if err := r.lastErr.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+// Reload loads and validates the configuration and applies it
+func (r *reloader) Reload(reason string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := config.LoadWithOptions(r.opts)
+	if err != nil {
+		slog.Error("configuration reload rejected, keeping previous configuration",
+			slog.String("trigger", reason), slog.Any("error", err))
+		r.lastErr.Store(&err)
+		return err
+	}
+
+	r.apply(r.current.Load(), next)
+	r.current.Store(next)
+	r.lastErr.Store(nil)
+	slog.Info("configuration reloaded", slog.String("trigger", reason))
+	return nil
+}
+
+// Run reloads on SIGHUP and, if a config file is in use, whenever it
+// changes. It returns when ctx is cancelled.
+func (r *reloader) Run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	if path := r.opts.ConfigFile(); path != "" {
+		go config.WatchFile(ctx, path, configPollInterval, func() {
+			_ = r.Reload("file change")
+		})
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			_ = r.Reload("SIGHUP")
+		}
+	}
+}
@@ -8,10 +8,13 @@ function TranslatorApp() {
         targetLang: 'zh',
         languages: {},
         autoTranslate: true,
+        htmlMode: false,
         fontSize: 16,
         loading: false,
         error: '',
         cached: false,
+        detectedSource: '',
+        detectedConfidence: 0,
         copyBtnText: '📋 复制',
         history: [],
         debounceTimer: null,
@@ -80,6 +83,7 @@ function TranslatorApp() {
             this.loading = true;
             this.error = '';
             this.cached = false;
+            this.detectedSource = '';
 
             try {
                 const response = await fetch('/api/translate', {
@@ -91,6 +95,7 @@ function TranslatorApp() {
                         text: this.inputText,
                         source: this.sourceLang,
                         target: this.targetLang,
+                        format: this.htmlMode ? 'html' : undefined,
                     }),
                 });
 
@@ -99,6 +104,8 @@ function TranslatorApp() {
                 if (data.success) {
                     this.outputText = data.text;
                     this.cached = data.cached || false;
+                    this.detectedSource = data.detected_source || '';
+                    this.detectedConfidence = data.confidence || 0;
                     
                     // 保存到历史
                     this.saveToHistory();
@@ -120,9 +127,21 @@ function TranslatorApp() {
             }
         },
 
+        // 自动检测结果说明，如 "检测到: 英语 (95%)"
+        detectedLabel() {
+            const name = this.languages[this.detectedSource] || this.detectedSource;
+            return `检测到: ${name} (${Math.round(this.detectedConfidence * 100)}%)`;
+        },
+
+        // 源语言列表（伪本地化只能作为目标语言）
+        sourceLanguages() {
+            const { pseudo, ...rest } = this.languages;
+            return rest;
+        },
+
         // 交换语言
         swapLanguages() {
-            if (this.sourceLang && this.targetLang) {
+            if (this.sourceLang && this.targetLang && this.targetLang !== 'pseudo') {
                 [this.sourceLang, this.targetLang] = [this.targetLang, this.sourceLang];
                 if (this.inputText && this.outputText) {
                     [this.inputText, this.outputText] = [this.outputText, this.inputText];
@@ -235,6 +254,7 @@ function TranslatorApp() {
                 history: this.history,
                 fontSize: this.fontSize,
                 autoTranslate: this.autoTranslate,
+                htmlMode: this.htmlMode,
                 sourceLang: this.sourceLang,
                 targetLang: this.targetLang,
             };
@@ -250,6 +270,7 @@ function TranslatorApp() {
                     this.history = data.history || [];
                     this.fontSize = data.fontSize || 16;
                     this.autoTranslate = data.autoTranslate !== false;
+                    this.htmlMode = data.htmlMode === true;
                     this.sourceLang = data.sourceLang || '';
                     this.targetLang = data.targetLang || 'zh';
                 } catch (error) {
@@ -264,6 +285,15 @@ function TranslatorApp() {
                 return '<div class="placeholder">翻译结果将显示在这里</div>';
             }
             
+            // HTML 模式：显示译文源码，便于复制
+            if (this.htmlMode) {
+                const escaped = this.outputText
+                    .replace(/&/g, '&amp;')
+                    .replace(/</g, '&lt;')
+                    .replace(/>/g, '&gt;');
+                return '<pre>' + escaped + '</pre>';
+            }
+            
             // 使用 marked 渲染 Markdown
             if (window.marked) {
                 return marked.parse(this.outputText);
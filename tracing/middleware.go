@@ -0,0 +1,53 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// unmatchedRoute names requests that matched no route
+const unmatchedRoute = "unmatched"
+
+// Middleware extracts incoming trace context and wraps each request in a
+// server span named after the matched route
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		// Unmatched paths share one name so scanners cannot flood the
+		// backend with distinct span names
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+
+		ctx, span := Start(ctx, fmt.Sprintf("%s %s", c.Request.Method, route),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Request.Method),
+				attribute.String("http.route", route),
+			))
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+// Inject writes the trace context of ctx into outgoing request headers
+func Inject(req *http.Request) {
+	otel.GetTextMapPropagator().Inject(req.Context(), propagation.HeaderCarrier(req.Header))
+}
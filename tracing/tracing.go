@@ -0,0 +1,104 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName identifies spans created by this application
+const InstrumentationName = "github.com/LouisLau-art/go-translator"
+
+// ServiceName is reported as service.name on every span
+const ServiceName = "doubao-translator"
+
+// Options controls which exporter is used and how spans are sampled
+type Options struct {
+	Exporter    string // none, stdout or otlp
+	Endpoint    string // OTLP/HTTP endpoint URL, e.g. http://localhost:4318
+	SampleRatio float64
+}
+
+// Setup configures the global tracer provider and propagator.
+// The returned function flushes and shuts down the provider.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	exporter, err := newExporter(ctx, opts, os.Stdout)
+	if err != nil {
+		return nil, err
+	}
+	if exporter == nil {
+		otel.SetTextMapPropagator(newPropagator())
+		return func(context.Context) error { return nil }, nil
+	}
+
+	tp := Install(exporter, opts.SampleRatio)
+	return tp.Shutdown, nil
+}
+
+// Install registers a tracer provider backed by exporter as the global
+// provider. Tests pass a tracetest.InMemoryExporter here.
+func Install(exporter sdktrace.SpanExporter, sampleRatio float64) *sdktrace.TracerProvider {
+	res := resource.NewSchemaless(attribute.String("service.name", ServiceName))
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(newPropagator())
+	return tp
+}
+
+// Tracer returns the application tracer from the global provider
+func Tracer() trace.Tracer {
+	return otel.Tracer(InstrumentationName)
+}
+
+// Start starts a span using the application tracer
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, opts...)
+}
+
+// RecordError marks span as failed with err
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+func newExporter(ctx context.Context, opts Options, stdout io.Writer) (sdktrace.SpanExporter, error) {
+	switch strings.ToLower(opts.Exporter) {
+	case "", "none":
+		return nil, nil
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithWriter(stdout), stdouttrace.WithPrettyPrint())
+	case "otlp":
+		var clientOpts []otlptracehttp.Option
+		if opts.Endpoint != "" {
+			clientOpts = append(clientOpts, otlptracehttp.WithEndpointURL(opts.Endpoint))
+		}
+		return otlptracehttp.New(ctx, clientOpts...)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q (expected none, stdout or otlp)", opts.Exporter)
+	}
+}
+
+func newPropagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+}
@@ -0,0 +1,92 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMiddlewareContinuesIncomingTrace(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := Install(exporter, 1.0)
+	defer tp.Shutdown(context.Background())
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+
+	var outgoing http.Header
+	r.GET("/api/ping", func(c *gin.Context) {
+		req, _ := http.NewRequestWithContext(c.Request.Context(), http.MethodGet, "http://upstream", nil)
+		Inject(req)
+		outgoing = req.Header
+		c.Status(http.StatusNoContent)
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/api/ping", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	if err := tp.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("Failed to flush spans: %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(spans))
+	}
+
+	span := spans[0]
+	if span.Name != "GET /api/ping" {
+		t.Errorf("Expected span name 'GET /api/ping', got '%s'", span.Name)
+	}
+	if span.SpanKind != trace.SpanKindServer {
+		t.Errorf("Expected server span, got %v", span.SpanKind)
+	}
+	if got := span.SpanContext.TraceID().String(); got != traceID {
+		t.Errorf("Expected trace ID %s to be continued, got %s", traceID, got)
+	}
+
+	if tp := outgoing.Get("traceparent"); tp == "" {
+		t.Error("Expected traceparent to be injected into outgoing request")
+	}
+}
+
+func TestMiddlewareNamesUnmatchedRoutes(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := Install(exporter, 1.0)
+	defer tp.Shutdown(context.Background())
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+
+	for _, path := range []string{"/wp-admin", "/.env"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	if err := tp.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("Failed to flush spans: %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	for _, span := range spans {
+		if span.Name != "GET unmatched" {
+			t.Errorf("Expected span name 'GET unmatched', got '%s'", span.Name)
+		}
+	}
+}
+
+func TestSetupRejectsUnknownExporter(t *testing.T) {
+	if _, err := Setup(context.Background(), Options{Exporter: "zipkin"}); err == nil {
+		t.Error("Expected error for unknown exporter")
+	}
+}
@@ -0,0 +1,286 @@
+// Package webhook delivers signed event payloads to callback URLs. Each
+// payload is signed with HMAC-SHA256 over "<timestamp>.<body>" so
+// receivers can check that it came from this server and is recent, and
+// failed deliveries are retried with exponential backoff.
+//
+// Callback URLs come from clients, so the sender refuses to reach
+// loopback, private, link-local and unspecified addresses unless their
+// host is allowed explicitly. Addresses are checked when a URL is
+// validated and again on every connection, so a host that later resolves
+// elsewhere cannot slip through.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/LouisLau-art/go-translator/metrics"
+)
+
+// Headers set on every delivery
+const (
+	// SignatureHeader carries "sha256=" and the hex HMAC-SHA256 of the
+	// timestamp, a dot and the body
+	SignatureHeader = "X-Webhook-Signature"
+	// TimestampHeader carries the Unix time the payload was signed at
+	TimestampHeader = "X-Webhook-Timestamp"
+	// EventHeader names the event, such as "job.succeeded"
+	EventHeader = "X-Webhook-Event"
+	// IDHeader identifies the event; retries of an event share it, so
+	// receivers can drop duplicates
+	IDHeader = "X-Webhook-ID"
+)
+
+// Settings configures a Sender
+type Settings struct {
+	// Secret is the HMAC key payloads are signed with
+	Secret string
+	// MaxAttempts is how many times a delivery is tried in total
+	MaxAttempts int
+	// Backoff is the wait before the first retry; it doubles each retry
+	Backoff time.Duration
+	// Timeout bounds each attempt
+	Timeout time.Duration
+	// AllowedHosts are callback hosts that may resolve to internal
+	// addresses, such as a CMS on the private network
+	AllowedHosts []string
+}
+
+// DefaultSettings returns the settings used when none are configured
+func DefaultSettings() Settings {
+	return Settings{MaxAttempts: 5, Backoff: 2 * time.Second, Timeout: 10 * time.Second}
+}
+
+// Event is a payload to deliver
+type Event struct {
+	// ID identifies the event across retries
+	ID string
+	// Name is the kind of event, sent in EventHeader
+	Name string
+	// Body is the JSON payload
+	Body []byte
+}
+
+// Attempt records one try at delivering an event
+type Attempt struct {
+	Number     int       `json:"attempt"`
+	Event      string    `json:"event"`
+	Time       time.Time `json:"time"`
+	DurationMS int64     `json:"duration_ms"`
+	// StatusCode is the receiver's response status; 0 when none arrived
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Success    bool   `json:"success"`
+}
+
+// Sender signs and delivers events
+type Sender struct {
+	settings Settings
+	client   *http.Client
+}
+
+// ErrForbiddenAddress is returned for callbacks to internal addresses
+var ErrForbiddenAddress = errors.New("callback address is not allowed")
+
+// allowedKey marks the context of a request to an allowed host
+type allowedKey struct{}
+
+// New creates a sender. Its client neither follows redirects nor uses a
+// proxy, and only connects to public addresses or allowed hosts.
+func New(settings Settings) *Sender {
+	if settings.MaxAttempts < 1 {
+		settings.MaxAttempts = 1
+	}
+	dialer := &net.Dialer{Timeout: settings.Timeout, ControlContext: dialControl}
+	client := &http.Client{
+		Timeout:   settings.Timeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: settings.Timeout},
+		// A redirect would lead the request somewhere the URL was not
+		// checked for
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	return &Sender{settings: settings, client: client}
+}
+
+// ValidateURL checks that a callback URL is an absolute http(s) URL whose
+// host is allowed or resolves only to public addresses
+func (s *Sender) ValidateURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("scheme must be http or https, got %q", u.Scheme)
+	}
+	host := u.Hostname()
+	if host == "" {
+		return errors.New("missing host")
+	}
+	if s.allowed(host) {
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", host, err)
+	}
+	for _, addr := range addrs {
+		if forbidden(addr.IP) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrForbiddenAddress, host, addr.IP)
+		}
+	}
+	return nil
+}
+
+// allowed reports whether host is one of the allowed hosts
+func (s *Sender) allowed(host string) bool {
+	return slices.ContainsFunc(s.settings.AllowedHosts, func(h string) bool {
+		return strings.EqualFold(h, host)
+	})
+}
+
+// dialControl refuses connections to internal addresses unless the
+// request is for an allowed host. It sees the address actually dialled,
+// after name resolution.
+func dialControl(ctx context.Context, _, address string, _ syscall.RawConn) error {
+	if allowed, _ := ctx.Value(allowedKey{}).(bool); allowed {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || forbidden(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+	return nil
+}
+
+// forbidden reports whether ip is an internal address
+func forbidden(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast()
+}
+
+// Sign returns the signature of body signed at timestamp, as sent in
+// SignatureHeader
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature headers of a delivery against its body. It
+// rejects payloads signed more than tolerance ago; a tolerance of 0 skips
+// that check.
+func Verify(secret string, header http.Header, body []byte, tolerance time.Duration) error {
+	timestamp, err := strconv.ParseInt(header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s header", TimestampHeader)
+	}
+	if tolerance > 0 && time.Since(time.Unix(timestamp, 0)).Abs() > tolerance {
+		return errors.New("timestamp outside tolerance")
+	}
+	if !hmac.Equal([]byte(header.Get(SignatureHeader)), []byte(Sign(secret, timestamp, body))) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// Send delivers event to target, retrying failures with backoff until the
+// receiver answers 2xx, the attempts are spent or ctx is done. Each
+// attempt is passed to record. Client errors other than 408 and 429,
+// redirects and forbidden addresses are not retried.
+func (s *Sender) Send(ctx context.Context, target string, event Event, record func(Attempt)) error {
+	var err error
+	for n := 1; ; n++ {
+		attempt, reqErr := s.attempt(ctx, n, target, event)
+		outcome := "success"
+		if !attempt.Success {
+			outcome = "failure"
+		}
+		metrics.WebhookDeliveries.WithLabelValues(outcome).Inc()
+		if record != nil {
+			record(attempt)
+		}
+		if attempt.Success {
+			return nil
+		}
+		err = fmt.Errorf("attempt %d: %s", n, attempt.Error)
+		if n >= s.settings.MaxAttempts || !retryable(attempt.StatusCode) || errors.Is(reqErr, ErrForbiddenAddress) {
+			return err
+		}
+
+		select {
+		case <-time.After(s.settings.Backoff << (n - 1)):
+		case <-ctx.Done():
+			return errors.Join(err, context.Cause(ctx))
+		}
+	}
+}
+
+// attempt makes one signed request, also returning the error of a request
+// that got no response
+func (s *Sender) attempt(ctx context.Context, n int, target string, event Event) (Attempt, error) {
+	start := time.Now()
+	attempt := Attempt{Number: n, Event: event.Name, Time: start}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(event.Body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return finished(attempt, start), err
+	}
+	if s.allowed(req.URL.Hostname()) {
+		req = req.WithContext(context.WithValue(ctx, allowedKey{}, true))
+	}
+	timestamp := start.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-translator-webhook")
+	req.Header.Set(EventHeader, event.Name)
+	req.Header.Set(IDHeader, event.ID)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(s.settings.Secret, timestamp, event.Body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		attempt.Error = err.Error()
+		return finished(attempt, start), err
+	}
+	defer resp.Body.Close()
+	// A little of the body explains most rejections
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+	attempt.StatusCode = resp.StatusCode
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		attempt.Success = true
+	} else {
+		attempt.Error = strings.TrimSpace(fmt.Sprintf("HTTP %d %s", resp.StatusCode, snippet))
+	}
+	return finished(attempt, start), nil
+}
+
+// finished sets how long an attempt took
+func finished(attempt Attempt, start time.Time) Attempt {
+	attempt.DurationMS = time.Since(start).Milliseconds()
+	return attempt
+}
+
+// retryable reports whether a delivery that got status is worth retrying;
+// status is 0 when the request itself failed
+func retryable(status int) bool {
+	return status == 0 || status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500
+}
@@ -0,0 +1,160 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const secret = "s3cret"
+
+// receiver answers each delivery with the next status in statuses, after
+// checking its signature, and counts the deliveries
+func receiver(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		body, _ := io.ReadAll(r.Body)
+		if err := Verify(secret, r.Header, body, time.Minute); err != nil {
+			t.Errorf("delivery %d: %v", n, err)
+		}
+		if r.Header.Get(EventHeader) != "job.succeeded" || r.Header.Get(IDHeader) != "job-1" {
+			t.Errorf("delivery %d headers = %v", n, r.Header)
+		}
+		w.WriteHeader(statuses[min(n, len(statuses))-1])
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+// newSender returns a sender allowed to reach the local test receivers
+func newSender() *Sender {
+	return New(Settings{Secret: secret, MaxAttempts: 3, Backoff: time.Millisecond, AllowedHosts: []string{"127.0.0.1"}})
+}
+
+var event = Event{ID: "job-1", Name: "job.succeeded", Body: []byte(`{"status":"succeeded"}`)}
+
+func TestSendRetriesUntilDelivered(t *testing.T) {
+	srv, calls := receiver(t, 503, 500, 204)
+	var log []Attempt
+	if err := newSender().Send(context.Background(), srv.URL, event, func(a Attempt) { log = append(log, a) }); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 3 || len(log) != 3 {
+		t.Fatalf("calls = %d, log = %+v", calls.Load(), log)
+	}
+	if log[0].Success || log[0].StatusCode != 503 || log[0].Error == "" || log[0].Number != 1 {
+		t.Errorf("first attempt = %+v", log[0])
+	}
+	if !log[2].Success || log[2].StatusCode != 204 || log[2].Event != "job.succeeded" {
+		t.Errorf("last attempt = %+v", log[2])
+	}
+}
+
+func TestSendGivesUp(t *testing.T) {
+	srv, calls := receiver(t, 500)
+	err := newSender().Send(context.Background(), srv.URL, event, nil)
+	if err == nil || calls.Load() != 3 {
+		t.Errorf("err = %v after %d calls, want failure after 3", err, calls.Load())
+	}
+
+	// Client errors are the receiver refusing the payload; retrying will
+	// not help
+	srv, calls = receiver(t, 400)
+	if err := newSender().Send(context.Background(), srv.URL, event, nil); err == nil || calls.Load() != 1 {
+		t.Errorf("err = %v after %d calls, want failure after 1", err, calls.Load())
+	}
+}
+
+func TestSendStopsWhenCancelled(t *testing.T) {
+	srv, _ := receiver(t, 503)
+	ctx, cancel := context.WithCancel(context.Background())
+	s := New(Settings{Secret: secret, MaxAttempts: 5, Backoff: time.Hour, AllowedHosts: []string{"127.0.0.1"}})
+	done := make(chan error, 1)
+	go func() { done <- s.Send(ctx, srv.URL, event, func(Attempt) { cancel() }) }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("expected an error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Send did not return after cancellation")
+	}
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	now := time.Now().Unix()
+	header := http.Header{}
+	header.Set(TimestampHeader, strconv.FormatInt(now, 10))
+	header.Set(SignatureHeader, Sign(secret, now, []byte("body")))
+
+	if err := Verify(secret, header, []byte("body"), time.Minute); err != nil {
+		t.Errorf("valid signature rejected: %v", err)
+	}
+	if err := Verify(secret, header, []byte("b0dy"), time.Minute); err == nil {
+		t.Error("tampered body accepted")
+	}
+	if err := Verify("other", header, []byte("body"), time.Minute); err == nil {
+		t.Error("wrong secret accepted")
+	}
+
+	old := now - 3600
+	header.Set(TimestampHeader, strconv.FormatInt(old, 10))
+	header.Set(SignatureHeader, Sign(secret, old, []byte("body")))
+	if err := Verify(secret, header, []byte("body"), time.Minute); err == nil {
+		t.Error("stale timestamp accepted")
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	s := New(Settings{AllowedHosts: []string{"cms.internal"}})
+	for raw, ok := range map[string]bool{
+		"https://93.184.215.14/hooks/translator":  true,
+		"https://cms.internal/hook":               true,
+		"http://127.0.0.1:8080/cb":                false,
+		"http://localhost/cb":                     false,
+		"http://10.1.2.3/cb":                      false,
+		"http://192.168.0.10/cb":                  false,
+		"http://169.254.169.254/latest/meta-data": false,
+		"http://[::1]/cb":                         false,
+		"http://0.0.0.0/cb":                       false,
+		"ftp://cms.example/":                      false,
+		"/relative":                               false,
+		"https://":                                false,
+	} {
+		if err := s.ValidateURL(context.Background(), raw); (err == nil) != ok {
+			t.Errorf("ValidateURL(%q) = %v", raw, err)
+		}
+	}
+}
+
+func TestSendRefusesInternalAddresses(t *testing.T) {
+	// A URL that passed validation may resolve elsewhere by the time it is
+	// delivered; the connection itself is refused
+	srv, calls := receiver(t, 204)
+	s := New(Settings{Secret: secret, MaxAttempts: 3, Backoff: time.Millisecond})
+	var log []Attempt
+	err := s.Send(context.Background(), srv.URL, event, func(a Attempt) { log = append(log, a) })
+	if !errors.Is(err, ErrForbiddenAddress) && (len(log) == 0 || !strings.Contains(log[0].Error, ErrForbiddenAddress.Error())) {
+		t.Errorf("err = %v, log = %+v", err, log)
+	}
+	if calls.Load() != 0 || len(log) != 1 {
+		t.Errorf("%d calls and %d attempts, want none and one", calls.Load(), len(log))
+	}
+
+	// Redirects are not followed
+	target, calls := receiver(t, 204)
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer redirect.Close()
+	if err := newSender().Send(context.Background(), redirect.URL, event, nil); err == nil || calls.Load() != 0 {
+		t.Errorf("err = %v after %d calls, want failure without following", err, calls.Load())
+	}
+}